@@ -0,0 +1,365 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+Общая реализация in memory кэша для микросервисов save (models.OrderGet) и query (models.OrderPost).
+Заменяет CacheOrderGet и CacheOrderPost, которые отличались только типом значения.
+
+1) Структура entry – объект, который помещаем в кэш:
+	1.1) key и value – ключ и само значение;
+	1.2) created – дата создания (сохранения в кэш);
+	1.3) expiration – истечение срока хранения элемента (UnixNano, 0 – без срока хранения);
+	1.4) size – примерный размер объекта в байтах (Options.SizeOf), считается только при ограничении по памяти;
+	1.5) служебные поля политики вытеснения (файл policy.go).
+2) Структура Options – параметры кэша:
+	2.1) DefaultTTL – срок хранения данных в кэше по умолчанию;
+	2.2) CleanupInterval – интервал, с которым «сборщик» мусора удаляет объекты с истекшим сроком хранения;
+	2.3) MaxEntries – максимальное количество объектов (0 – без ограничения);
+	2.4) MaxBytes – примерный объём памяти под объекты в байтах (0 – без ограничения).
+	Размер объекта считает функция SizeOf, по умолчанию – SizeJSON;
+	2.5) Policy – какой объект вытесняется при превышении MaxEntries или MaxBytes:
+	PolicyLRU (по умолчанию) – тот, к которому дольше всего не обращались,
+	PolicyLFU – тот, к которому обращались реже всего (при равенстве – дольше всего не обращались);
+	2.6) OnEvict – функция, которая вызывается для каждого вытесненного объекта с причиной вытеснения (EvictReason):
+	истек срок хранения (EvictExpired), превышено количество объектов (EvictEntries) или объём памяти (EvictBytes).
+	Вызывается без блокировки кэша, поэтому может обращаться к нему. Для Delete и Close не вызывается;
+	2.7) Shards – количество сегментов со своей блокировкой (файл shard.go; 0 или 1 – один сегмент, как раньше).
+	MaxEntries и MaxBytes делятся между сегментами поровну, вытеснение действует внутри сегмента;
+	2.8) Hash – хеш ключа для выбора сегмента (по умолчанию hashKey);
+	2.9) SnapshotPath, SnapshotInterval и OnSnapshot – снимки кэша на диске (файл snapshot.go).
+3) Структура Cache[K, V] – сам кэш с ключами типа K и значениями типа V. Состоит из:
+	3.1) opts – параметры кэша (Options);
+	3.2) shards – сегменты: объекты, порядок их вытеснения, объём и счётчики под блокировкой сегмента (sync.Mutex).
+	Get тоже изменяет сегмент (порядок вытеснения), поэтому RWMutex не используется;
+	3.3) snapshotMu – очередь записи снимков в файл (функция SaveFile).
+4) Конструкторы:
+	4.1) New – кэш без ограничений по количеству и памяти (срок хранения по умолчанию и интервал «сборщика» мусора);
+	4.2) NewWithOptions – кэш с параметрами Options. Если CleanupInterval > 0, запускает «сборщик» мусора (функция janitor):
+	он проверяет сегменты по очереди, каждый – раз в CleanupInterval, и держит блокировку только одного сегмента;
+	если заданы SnapshotPath и SnapshotInterval – периодическую запись снимков (функция snapshotter).
+	Неизвестная политика вытеснения – ошибка настройки, поэтому вызывает panic; для проверки настроек – функция ParsePolicy.
+5) Функция Set – добавляет в кэш новое значение, принимая в качестве параметров ключ, значение и срок хранения ttl:
+DefaultTTL (0) – срок хранения по умолчанию, NoExpiration – без срока хранения.
+Если после добавления будут превышены ограничения сегмента – заранее вытесняет объекты сегмента согласно политике.
+Объект больше доли MaxBytes одного сегмента в кэш не помещается (вытесняется сразу с причиной EvictBytes), прежнее значение по этому ключу удаляется.
+6) Функция Get – принимает в качестве аргумента ключ и возвращает значение и признак «найдено».
+Объект с истекшим сроком хранения не выдаётся и удаляется, даже если «сборщик» мусора его ещё не удалил.
+7) Функция Delete – удаляет объект по ключу. Возвращает false, если объекта не было.
+8) Функция GetOrLoad – возвращает значение из кэша, а если его нет – вызывает функцию load, сохраняет результат
+на время ttl и возвращает его. Ошибка load возвращается как есть и в кэш не сохраняется.
+Функция load вызывается без блокировки кэша, поэтому может быть долгой (например, запрос в БД).
+9) Функция Len – количество объектов во всех сегментах (включая объекты с истекшим сроком, ещё не удалённые «сборщиком»).
+10) Функция DeleteExpired – удаляет объекты с истекшим сроком хранения и возвращает их количество.
+11) Функция Stats – текущее количество и объём объектов, количество сегментов, ограничения, попадания и промахи Get,
+счётчики вытеснений по причинам (сумма по сегментам).
+12) Функция Close – вызывается при остановке микросервиса: останавливает «сборщик» мусора, записывает последний снимок
+(если задан SnapshotPath) и удаляет все объекты (Flush).
+Возвращает количество удалённых объектов. Повторный вызов безопасен; кэш остаётся пригодным для записи.
+13) Функция SizeJSON – размер объекта по умолчанию: длина ключа и значения в JSON-нотации.
+Это приближение: в памяти Go структура занимает сопоставимый объём, но не равный ему.
+14) Функции Peek, DeleteFunc и Flush – просмотр и очистка кэша для администрирования (файл inspect.go).
+*/
+
+const (
+	DefaultTTL   time.Duration = 0
+	NoExpiration time.Duration = -1
+)
+
+type EvictReason int
+
+const (
+	EvictExpired EvictReason = iota + 1
+	EvictEntries
+	EvictBytes
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictEntries:
+		return "max_entries"
+	case EvictBytes:
+		return "max_bytes"
+	}
+	return fmt.Sprintf("EvictReason(%d)", int(r))
+}
+
+type Options[K comparable, V any] struct {
+	DefaultTTL      time.Duration
+	CleanupInterval time.Duration
+	MaxEntries      int
+	MaxBytes        int64
+	Policy          Policy
+	SizeOf          func(key K, value V) int64
+	OnEvict         func(key K, value V, reason EvictReason)
+	Shards          int
+	Hash            func(key K) uint64
+
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	OnSnapshot       func(entries int, err error)
+}
+
+type Stats struct {
+	Policy         Policy `json:"policy"`
+	Shards         int    `json:"shards"`
+	Entries        int    `json:"entries"`
+	Bytes          int64  `json:"bytes"`
+	MaxEntries     int    `json:"max_entries"`
+	MaxBytes       int64  `json:"max_bytes"`
+	Hits           uint64 `json:"hits"`
+	Misses         uint64 `json:"misses"`
+	EvictedExpired uint64 `json:"evicted_expired"`
+	EvictedEntries uint64 `json:"evicted_max_entries"`
+	EvictedBytes   uint64 `json:"evicted_max_bytes"`
+}
+
+// Evictions – общее количество вытесненных объектов.
+func (s Stats) Evictions() uint64 {
+	return s.EvictedExpired + s.EvictedEntries + s.EvictedBytes
+}
+
+type entry[K comparable, V any] struct {
+	key        K
+	value      V
+	created    time.Time
+	expiration int64
+	size       int64
+
+	policyState
+}
+
+func (e *entry[K, V]) expired(now int64) bool {
+	return e.expiration > 0 && now > e.expiration
+}
+
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+type Cache[K comparable, V any] struct {
+	opts   Options[K, V]
+	shards []*shard[K, V]
+
+	snapshotMu sync.Mutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// now подменяется в тестах.
+	now func() time.Time
+}
+
+func New[K comparable, V any](defaultTTL, cleanupInterval time.Duration) *Cache[K, V] {
+	return NewWithOptions(Options[K, V]{
+		DefaultTTL:      defaultTTL,
+		CleanupInterval: cleanupInterval,
+	})
+}
+
+func NewWithOptions[K comparable, V any](opts Options[K, V]) *Cache[K, V] {
+
+	policy, err := ParsePolicy(string(opts.Policy))
+	if err != nil {
+		panic(err)
+	}
+	opts.Policy = policy
+	if opts.MaxBytes > 0 && opts.SizeOf == nil {
+		opts.SizeOf = SizeJSON[K, V]
+	}
+	if opts.Shards < 1 {
+		opts.Shards = 1
+	}
+	if opts.Hash == nil {
+		opts.Hash = hashKey[K]
+	}
+
+	c := &Cache[K, V]{
+		opts: opts,
+		stop: make(chan struct{}),
+		now:  time.Now,
+	}
+	c.shards = c.newShards()
+	if opts.CleanupInterval > 0 {
+		go c.janitor()
+	}
+	if opts.SnapshotPath != "" && opts.SnapshotInterval > 0 {
+		go c.snapshotter()
+	}
+	return c
+}
+
+// newShards создаёт сегменты и делит между ними MaxEntries и MaxBytes (с округлением вверх).
+func (c *Cache[K, V]) newShards() []*shard[K, V] {
+	n := c.opts.Shards
+	maxEntries := (c.opts.MaxEntries + n - 1) / n
+	maxBytes := (c.opts.MaxBytes + int64(n) - 1) / int64(n)
+
+	shards := make([]*shard[K, V], n)
+	for i := range shards {
+		shards[i] = newShard[K, V](c.opts.Policy, maxEntries, maxBytes)
+	}
+	return shards
+}
+
+func (c *Cache[K, V]) shard(key K) *shard[K, V] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[c.opts.Hash(key)%uint64(len(c.shards))]
+}
+
+func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) {
+
+	now := c.now()
+	if ttl == DefaultTTL {
+		ttl = c.opts.DefaultTTL
+	}
+	var expiration int64
+	if ttl > 0 {
+		expiration = now.Add(ttl).UnixNano()
+	}
+	c.set(key, value, now, expiration, now.UnixNano())
+}
+
+// set добавляет объект с заданными датой создания и сроком хранения (UnixNano). Используется Set и Restore.
+func (c *Cache[K, V]) set(key K, value V, created time.Time, expiration, now int64) {
+
+	e := &entry[K, V]{
+		key:        key,
+		value:      value,
+		created:    created,
+		expiration: expiration,
+	}
+	if c.opts.SizeOf != nil {
+		e.size = c.opts.SizeOf(key, value)
+	}
+	c.notify(c.shard(key).set(e, now))
+}
+
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	value, ok, evicted := c.shard(key).get(key, c.now().UnixNano())
+	c.notify(evicted)
+	return value, ok
+}
+
+func (c *Cache[K, V]) Delete(key K) bool {
+	return c.shard(key).delete(key)
+}
+
+func (c *Cache[K, V]) GetOrLoad(key K, ttl time.Duration, load func(K) (V, error)) (V, error) {
+
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	value, err := load(key)
+	if err != nil {
+		return value, err
+	}
+	c.Set(key, value, ttl)
+	return value, nil
+}
+
+func (c *Cache[K, V]) Len() (n int) {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += len(s.items)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+func (c *Cache[K, V]) DeleteExpired() (removed int) {
+	for _, s := range c.shards {
+		removed += c.deleteExpired(s)
+	}
+	return removed
+}
+
+// deleteExpired удаляет объекты с истекшим сроком хранения из одного сегмента.
+func (c *Cache[K, V]) deleteExpired(s *shard[K, V]) int {
+	evicted := s.deleteExpired(c.now().UnixNano())
+	c.notify(evicted)
+	return len(evicted)
+}
+
+func (c *Cache[K, V]) Stats() Stats {
+
+	stats := Stats{
+		Policy:     c.opts.Policy,
+		Shards:     len(c.shards),
+		MaxEntries: c.opts.MaxEntries,
+		MaxBytes:   c.opts.MaxBytes,
+	}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		stats.Entries += len(s.items)
+		stats.Bytes += s.bytes
+		stats.Hits += s.stats.Hits
+		stats.Misses += s.stats.Misses
+		stats.EvictedExpired += s.stats.EvictedExpired
+		stats.EvictedEntries += s.stats.EvictedEntries
+		stats.EvictedBytes += s.stats.EvictedBytes
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+func (c *Cache[K, V]) Close() (removed int) {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		if c.opts.SnapshotPath != "" {
+			c.saveSnapshot()
+		}
+	})
+	return c.Flush()
+}
+
+func SizeJSON[K comparable, V any](key K, value V) int64 {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return int64(len(fmt.Sprint(key)))
+	}
+	return int64(len(fmt.Sprint(key)) + len(data))
+}
+
+// notify вызывает OnEvict для вытесненных объектов. Вызывается без блокировки.
+func (c *Cache[K, V]) notify(evicted []eviction[K, V]) {
+	if c.opts.OnEvict == nil {
+		return
+	}
+	for _, ev := range evicted {
+		c.opts.OnEvict(ev.key, ev.value, ev.reason)
+	}
+}
+
+// janitor проверяет сегменты по очереди: за CleanupInterval – каждый сегмент по одному разу,
+// поэтому блокировка держится только на время проверки одного сегмента.
+func (c *Cache[K, V]) janitor() {
+
+	interval := c.opts.CleanupInterval / time.Duration(len(c.shards))
+	if interval <= 0 {
+		interval = c.opts.CleanupInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for next := 0; ; next = (next + 1) % len(c.shards) {
+		select {
+		case <-ticker.C:
+			c.deleteExpired(c.shards[next])
+		case <-c.stop:
+			return
+		}
+	}
+}
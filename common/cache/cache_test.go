@@ -0,0 +1,435 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+Тестирование кэша:
+1) Set/Get/Delete, срок хранения по умолчанию, собственный срок хранения и NoExpiration (время подменяется – поле now);
+2) Delete не блокирует кэш (ранее CacheOrderGet и CacheOrderPost дважды вызывали Lock);
+3) GetOrLoad: загрузка только при промахе, ошибка не сохраняется;
+4) «Сборщик» мусора удаляет объекты с истекшим сроком, Close останавливает его и очищает кэш;
+5) Одновременный доступ из нескольких горутин (запускать с -race);
+6) Вытеснение по MaxEntries (LRU и LFU) и MaxBytes, OnEvict с причиной вытеснения, счётчики Stats, ParsePolicy.
+Бенчмарки: Set, Get и смешанная нагрузка из нескольких горутин, Set с вытеснением (LRU и LFU).
+*/
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestCache(defaultTTL time.Duration) (*Cache[string, int], *clock) {
+	clk := &clock{now: time.Unix(1000, 0)}
+	c := New[string, int](defaultTTL, 0)
+	c.now = clk.Now
+	return c, clk
+}
+
+func TestSetGetTTL(t *testing.T) {
+	c, clk := newTestCache(time.Minute)
+
+	c.Set("default", 1, DefaultTTL)
+	c.Set("short", 2, time.Second)
+	c.Set("forever", 3, NoExpiration)
+
+	for key, want := range map[string]int{"default": 1, "short": 2, "forever": 3} {
+		if got, ok := c.Get(key); !ok || got != want {
+			t.Fatalf("Get(%q) = %d, %v; want %d, true", key, got, ok, want)
+		}
+	}
+
+	clk.Add(2 * time.Second)
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("expired item returned")
+	}
+	if _, ok := c.Get("default"); !ok {
+		t.Fatal("item with default TTL expired too early")
+	}
+
+	clk.Add(time.Hour)
+	if _, ok := c.Get("default"); ok {
+		t.Fatal("item with default TTL did not expire")
+	}
+	if got, ok := c.Get("forever"); !ok || got != 3 {
+		t.Fatal("item without expiration expired")
+	}
+
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("missing item returned")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c, _ := newTestCache(time.Minute)
+	c.Set("a", 1, DefaultTTL)
+
+	done := make(chan bool)
+	go func() {
+		done <- c.Delete("a")
+	}()
+
+	select {
+	case deleted := <-done:
+		if !deleted {
+			t.Fatal("Delete returned false for existing key")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Delete deadlocked")
+	}
+
+	if c.Delete("a") {
+		t.Fatal("Delete returned true for missing key")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("deleted item returned")
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	c, clk := newTestCache(time.Minute)
+
+	var calls int
+	load := func(key string) (int, error) {
+		calls++
+		return strconv.Atoi(key)
+	}
+
+	for i := 0; i < 3; i++ {
+		got, err := c.GetOrLoad("42", DefaultTTL, load)
+		if err != nil || got != 42 {
+			t.Fatalf("GetOrLoad = %d, %v; want 42, nil", got, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("load called %d times, want 1", calls)
+	}
+
+	clk.Add(2 * time.Minute)
+	if _, err := c.GetOrLoad("42", DefaultTTL, load); err != nil || calls != 2 {
+		t.Fatalf("expired item was not reloaded: calls = %d, err = %v", calls, err)
+	}
+
+	errLoad := errors.New("load failed")
+	_, err := c.GetOrLoad("x", DefaultTTL, func(string) (int, error) { return 0, errLoad })
+	if !errors.Is(err, errLoad) {
+		t.Fatalf("GetOrLoad error = %v, want %v", err, errLoad)
+	}
+	if _, ok := c.Get("x"); ok {
+		t.Fatal("failed load was cached")
+	}
+}
+
+func TestDeleteExpiredAndJanitor(t *testing.T) {
+	c, clk := newTestCache(time.Second)
+	c.Set("a", 1, DefaultTTL)
+	c.Set("b", 2, NoExpiration)
+
+	clk.Add(2 * time.Second)
+	if removed := c.DeleteExpired(); removed != 1 {
+		t.Fatalf("DeleteExpired = %d, want 1", removed)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Len = %d, want 1", c.Len())
+	}
+
+	j := New[string, int](time.Millisecond, 5*time.Millisecond)
+	defer j.Close()
+	j.Set("a", 1, DefaultTTL)
+
+	deadline := time.Now().Add(time.Second)
+	for j.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("janitor did not remove expired item")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClose(t *testing.T) {
+	c := New[string, int](time.Minute, time.Millisecond)
+	c.Set("a", 1, DefaultTTL)
+	c.Set("b", 2, DefaultTTL)
+
+	if removed := c.Close(); removed != 2 {
+		t.Fatalf("Close = %d, want 2", removed)
+	}
+	if removed := c.Close(); removed != 0 {
+		t.Fatalf("second Close = %d, want 0", removed)
+	}
+
+	c.Set("c", 3, DefaultTTL)
+	if got, ok := c.Get("c"); !ok || got != 3 {
+		t.Fatal("cache is not usable after Close")
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	c := New[int, int](time.Minute, time.Millisecond)
+	defer c.Close()
+
+	var loads int64
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (g*1000 + i) % 128
+				switch i % 4 {
+				case 0:
+					c.Set(key, i, DefaultTTL)
+				case 1:
+					c.Get(key)
+				case 2:
+					c.Delete(key)
+				default:
+					c.GetOrLoad(key, time.Millisecond, func(k int) (int, error) {
+						atomic.AddInt64(&loads, 1)
+						return k, nil
+					})
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if c.Len() > 128 {
+		t.Fatalf("Len = %d, want <= 128", c.Len())
+	}
+}
+
+func TestEvictLRU(t *testing.T) {
+	c := NewWithOptions(Options[string, int]{MaxEntries: 2})
+
+	c.Set("a", 1, DefaultTTL)
+	c.Set("b", 2, DefaultTTL)
+	c.Get("a")
+	c.Set("c", 3, DefaultTTL)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used item was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("item %q evicted", key)
+		}
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.EvictedEntries != 1 || stats.Policy != PolicyLRU {
+		t.Fatalf("Stats = %+v", stats)
+	}
+}
+
+func TestEvictLFU(t *testing.T) {
+	c := NewWithOptions(Options[string, int]{MaxEntries: 3, Policy: PolicyLFU})
+
+	c.Set("a", 1, DefaultTTL)
+	c.Set("b", 2, DefaultTTL)
+	c.Set("c", 3, DefaultTTL)
+	for i := 0; i < 3; i++ {
+		c.Get("a")
+		c.Get("c")
+	}
+	c.Get("b")
+
+	// b – реже всех, хотя к нему обращались последним.
+	c.Set("d", 4, DefaultTTL)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least frequently used item was not evicted")
+	}
+
+	// При равном количестве обращений вытесняется тот, к которому дольше не обращались.
+	c.Set("e", 5, DefaultTTL)
+	if _, ok := c.Get("d"); ok {
+		t.Fatal("older item with equal frequency was not evicted")
+	}
+	for _, key := range []string{"a", "c", "e"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("item %q evicted", key)
+		}
+	}
+}
+
+func TestEvictBytes(t *testing.T) {
+	var evicted []string
+	c := NewWithOptions(Options[string, string]{
+		MaxBytes: 10,
+		SizeOf:   func(key, value string) int64 { return int64(len(value)) },
+		OnEvict: func(key, value string, reason EvictReason) {
+			evicted = append(evicted, key+":"+reason.String())
+		},
+	})
+
+	c.Set("a", "aaaa", DefaultTTL)
+	c.Set("b", "bbbb", DefaultTTL)
+	c.Set("c", "cccc", DefaultTTL)
+	if stats := c.Stats(); stats.Bytes != 8 || stats.Entries != 2 {
+		t.Fatalf("Stats = %+v, want 8 bytes in 2 entries", stats)
+	}
+
+	// Замена значения учитывает новый размер.
+	c.Set("c", "cc", DefaultTTL)
+	if stats := c.Stats(); stats.Bytes != 6 {
+		t.Fatalf("Bytes = %d, want 6", stats.Bytes)
+	}
+
+	c.Set("big", "0123456789ab", DefaultTTL)
+	if _, ok := c.Get("big"); ok {
+		t.Fatal("item larger than MaxBytes was cached")
+	}
+
+	want := []string{"a:max_bytes", "big:max_bytes"}
+	if strings.Join(evicted, ",") != strings.Join(want, ",") {
+		t.Fatalf("evicted = %v, want %v", evicted, want)
+	}
+	if stats := c.Stats(); stats.EvictedBytes != 2 || stats.Evictions() != 2 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+
+	c.Delete("b")
+	c.Close()
+	if stats := c.Stats(); stats.Bytes != 0 || len(evicted) != 2 {
+		t.Fatalf("Delete/Close: Bytes = %d, evicted = %v", stats.Bytes, evicted)
+	}
+}
+
+func TestOnEvictExpired(t *testing.T) {
+	clk := &clock{now: time.Unix(1000, 0)}
+	reasons := make(map[string]EvictReason)
+	var c *Cache[string, int]
+	c = NewWithOptions(Options[string, int]{
+		DefaultTTL: time.Second,
+		MaxEntries: 2,
+		OnEvict: func(key string, _ int, reason EvictReason) {
+			reasons[key] = reason
+			// Вызывается без блокировки: обращение к кэшу не приводит к deadlock.
+			c.Len()
+		},
+	})
+	c.now = clk.Now
+
+	c.Set("a", 1, DefaultTTL)
+	c.Set("b", 2, NoExpiration)
+	c.Set("c", 3, NoExpiration)
+	clk.Add(2 * time.Second)
+	c.Get("b")
+	c.Set("d", 4, NoExpiration)
+
+	// a вытеснен из-за MaxEntries, пока был жив, c – дольше всех без обращения.
+	if reasons["a"] != EvictEntries || reasons["c"] != EvictEntries {
+		t.Fatalf("reasons = %v", reasons)
+	}
+
+	c.Delete("b")
+	c.Set("e", 5, time.Second)
+	clk.Add(2 * time.Second)
+	if removed := c.DeleteExpired(); removed != 1 || reasons["e"] != EvictExpired {
+		t.Fatalf("DeleteExpired = %d, reasons = %v", removed, reasons)
+	}
+	if _, ok := reasons["b"]; ok {
+		t.Fatal("OnEvict called for Delete")
+	}
+
+	stats := c.Stats()
+	if stats.EvictedExpired != 1 || stats.EvictedEntries != 2 || stats.Hits != 1 || stats.Entries != 1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for in, want := range map[string]Policy{"": PolicyLRU, "lru": PolicyLRU, "LFU": PolicyLFU} {
+		if got, err := ParsePolicy(in); err != nil || got != want {
+			t.Fatalf("ParsePolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParsePolicy("fifo"); err == nil {
+		t.Fatal("ParsePolicy accepted unknown policy")
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	c := New[string, int](time.Minute, 0)
+	keys := benchKeys(1024)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Set(keys[i%len(keys)], i, DefaultTTL)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	c := New[string, int](time.Minute, 0)
+	keys := benchKeys(1024)
+	for i, key := range keys {
+		c.Set(key, i, DefaultTTL)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkParallelMixed(b *testing.B) {
+	c := New[string, int](time.Minute, 0)
+	keys := benchKeys(1024)
+	for i, key := range keys {
+		c.Set(key, i, DefaultTTL)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%10 == 0 {
+				c.Set(key, i, DefaultTTL)
+			} else {
+				c.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkSetEvict(b *testing.B) {
+	for _, policy := range []Policy{PolicyLRU, PolicyLFU} {
+		b.Run(string(policy), func(b *testing.B) {
+			c := NewWithOptions(Options[string, int]{MaxEntries: 512, Policy: policy})
+			keys := benchKeys(1024)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.Set(keys[i%len(keys)], i, DefaultTTL)
+				c.Get(keys[(i*7)%len(keys)])
+			}
+		})
+	}
+}
+
+func benchKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "order-" + strconv.Itoa(i)
+	}
+	return keys
+}
@@ -0,0 +1,67 @@
+package cache
+
+import "time"
+
+/*
+Функции для администрирования кэша (пакет my.service.common/cacheadmin): просмотр объекта и очистка без остановки кэша.
+
+1) Структура EntryInfo – служебные сведения об объекте: дата создания, истечение срока хранения (нулевое время – без срока)
+и примерный размер (если задано ограничение MaxBytes или функция SizeOf).
+2) Функция Peek – возвращает значение и EntryInfo по ключу, не изменяя кэш: не считается попаданием или промахом,
+не меняет порядок вытеснения и не удаляет объект с истекшим сроком (для него возвращает false).
+3) Функция DeleteFunc – удаляет объекты, для ключей которых fn возвращает true, и возвращает их количество.
+Функция fn вызывается под блокировкой сегмента, поэтому должна быть быстрой и не обращаться к кэшу.
+OnEvict для удалённых объектов не вызывается (как и для Delete).
+4) Функция Flush – удаляет все объекты и возвращает их количество. В отличие от Close не останавливает «сборщик» мусора
+и не записывает снимок. Счётчики Stats сохраняются.
+*/
+
+type EntryInfo struct {
+	Created time.Time
+	Expires time.Time
+	Size    int64
+}
+
+func (c *Cache[K, V]) Peek(key K) (value V, info EntryInfo, ok bool) {
+	now := c.now().UnixNano()
+
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, found := s.items[key]
+	if !found || e.expired(now) {
+		return value, info, false
+	}
+	info = EntryInfo{Created: e.created, Size: e.size}
+	if e.expiration > 0 {
+		info.Expires = time.Unix(0, e.expiration)
+	}
+	return e.value, info, true
+}
+
+func (c *Cache[K, V]) DeleteFunc(fn func(key K) bool) (removed int) {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for key, e := range s.items {
+			if fn(key) {
+				s.remove(e)
+				removed++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return removed
+}
+
+func (c *Cache[K, V]) Flush() (removed int) {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		removed += len(s.items)
+		s.items = make(map[K]*entry[K, V])
+		s.policy = newPolicy[K, V](c.opts.Policy)
+		s.bytes = 0
+		s.mu.Unlock()
+	}
+	return removed
+}
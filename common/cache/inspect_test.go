@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Тестирование функций администрирования:
+1) Peek возвращает сроки объекта и не влияет на счётчики и порядок вытеснения, объект с истекшим сроком не выдаёт;
+2) DeleteFunc удаляет объекты по условию на ключ во всех сегментах;
+3) Flush очищает кэш, сохраняя счётчики, кэш остаётся пригодным для записи.
+*/
+
+func TestPeek(t *testing.T) {
+	c, clk := newTestCache(time.Minute)
+	c.opts.MaxEntries = 2
+	c.shards = c.newShards()
+
+	c.Set("a", 1, DefaultTTL)
+	c.Set("b", 2, NoExpiration)
+
+	value, info, ok := c.Peek("a")
+	if !ok || value != 1 {
+		t.Fatalf("Peek(a) = %d, %v; want 1, true", value, ok)
+	}
+	if !info.Created.Equal(clk.Now()) || !info.Expires.Equal(clk.Now().Add(time.Minute)) {
+		t.Fatalf("Peek(a) info = %+v", info)
+	}
+	if _, info, _ := c.Peek("b"); !info.Expires.IsZero() {
+		t.Fatalf("Peek(b) Expires = %s, want zero", info.Expires)
+	}
+	if stats := c.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Fatalf("Peek changed Stats: %+v", stats)
+	}
+
+	// Peek не обновляет порядок LRU: вытесняется a.
+	c.Set("c", 3, DefaultTTL)
+	if _, _, ok := c.Peek("a"); ok {
+		t.Fatal("Peek touched the entry")
+	}
+
+	clk.Add(2 * time.Minute)
+	if _, _, ok := c.Peek("c"); ok {
+		t.Fatal("Peek returned expired entry")
+	}
+}
+
+func TestDeleteFuncAndFlush(t *testing.T) {
+	c := NewWithOptions(Options[string, int]{Shards: 4})
+	for i, key := range benchKeys(100) {
+		c.Set(key, i, DefaultTTL)
+		c.Set("other-"+key, i, DefaultTTL)
+	}
+	c.Get("order-1")
+
+	removed := c.DeleteFunc(func(key string) bool { return strings.HasPrefix(key, "other-") })
+	if removed != 100 || c.Len() != 100 {
+		t.Fatalf("DeleteFunc = %d, Len = %d; want 100 and 100", removed, c.Len())
+	}
+
+	if removed := c.Flush(); removed != 100 || c.Len() != 0 {
+		t.Fatalf("Flush = %d, Len = %d; want 100 and 0", removed, c.Len())
+	}
+	if stats := c.Stats(); stats.Hits != 1 {
+		t.Fatalf("Flush reset Stats: %+v", stats)
+	}
+	c.Set("a", 1, DefaultTTL)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("cache is not usable after Flush")
+	}
+}
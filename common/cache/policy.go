@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"strings"
+)
+
+/*
+Политики вытеснения объектов из кэша (поле Policy структуры Options).
+
+1) Функция ParsePolicy – проверяет название политики из настроек микросервиса: lru, lfu или пустая строка (lru).
+2) Интерфейс policy – порядок вытеснения объектов: add (новый объект), touch (обращение к объекту через Get),
+remove (удаление объекта), victim (объект, который будет вытеснен следующим, nil – кэш пуст) и walk (обход объектов:
+в LRU – от давно использованных к недавним, чтобы при восстановлении снимка порядок вытеснения сохранился; в LFU – в порядке кучи).
+Все функции вызываются под блокировкой сегмента кэша (shard) и выполняются за O(1) (LRU) или O(log n) (LFU).
+3) Структура policyState – служебные поля объекта кэша (entry) для обеих политик.
+4) Структура lruPolicy – двусвязный список: недавно использованные объекты в начале, вытесняется последний.
+5) Структура lfuPolicy – двоичная куча по количеству обращений, при равенстве – по времени последнего обращения
+(порядковый номер tick). Новый объект начинает с одного обращения, поэтому вытесняется раньше «популярных».
+*/
+
+type Policy string
+
+const (
+	PolicyLRU Policy = "lru"
+	PolicyLFU Policy = "lfu"
+)
+
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(strings.ToLower(s)) {
+	case "", PolicyLRU:
+		return PolicyLRU, nil
+	case PolicyLFU:
+		return PolicyLFU, nil
+	}
+	return "", fmt.Errorf("cache: неизвестная политика вытеснения %q (lru или lfu)", s)
+}
+
+type policy[K comparable, V any] interface {
+	add(e *entry[K, V])
+	touch(e *entry[K, V])
+	remove(e *entry[K, V])
+	victim() *entry[K, V]
+	walk(fn func(e *entry[K, V]))
+}
+
+type policyState struct {
+	elem  *list.Element
+	hits  uint64
+	tick  uint64
+	index int
+}
+
+func newPolicy[K comparable, V any](p Policy) policy[K, V] {
+	if p == PolicyLFU {
+		return &lfuPolicy[K, V]{}
+	}
+	return &lruPolicy[K, V]{order: list.New()}
+}
+
+type lruPolicy[K comparable, V any] struct {
+	order *list.List
+}
+
+func (p *lruPolicy[K, V]) add(e *entry[K, V]) {
+	e.elem = p.order.PushFront(e)
+}
+
+func (p *lruPolicy[K, V]) touch(e *entry[K, V]) {
+	p.order.MoveToFront(e.elem)
+}
+
+func (p *lruPolicy[K, V]) remove(e *entry[K, V]) {
+	p.order.Remove(e.elem)
+	e.elem = nil
+}
+
+func (p *lruPolicy[K, V]) victim() *entry[K, V] {
+	back := p.order.Back()
+	if back == nil {
+		return nil
+	}
+	return back.Value.(*entry[K, V])
+}
+
+func (p *lruPolicy[K, V]) walk(fn func(e *entry[K, V])) {
+	for el := p.order.Back(); el != nil; el = el.Prev() {
+		fn(el.Value.(*entry[K, V]))
+	}
+}
+
+type lfuPolicy[K comparable, V any] struct {
+	entries lfuHeap[K, V]
+	tick    uint64
+}
+
+func (p *lfuPolicy[K, V]) add(e *entry[K, V]) {
+	p.tick++
+	e.hits, e.tick = 1, p.tick
+	heap.Push(&p.entries, e)
+}
+
+func (p *lfuPolicy[K, V]) touch(e *entry[K, V]) {
+	p.tick++
+	e.hits, e.tick = e.hits+1, p.tick
+	heap.Fix(&p.entries, e.index)
+}
+
+func (p *lfuPolicy[K, V]) remove(e *entry[K, V]) {
+	heap.Remove(&p.entries, e.index)
+}
+
+func (p *lfuPolicy[K, V]) victim() *entry[K, V] {
+	if len(p.entries) == 0 {
+		return nil
+	}
+	return p.entries[0]
+}
+
+func (p *lfuPolicy[K, V]) walk(fn func(e *entry[K, V])) {
+	for _, e := range p.entries {
+		fn(e)
+	}
+}
+
+type lfuHeap[K comparable, V any] []*entry[K, V]
+
+func (h lfuHeap[K, V]) Len() int { return len(h) }
+
+func (h lfuHeap[K, V]) Less(i, j int) bool {
+	if h[i].hits != h[j].hits {
+		return h[i].hits < h[j].hits
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K, V]) Push(x any) {
+	e := x.(*entry[K, V])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K, V]) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	e.index = -1
+	return e
+}
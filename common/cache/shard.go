@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+)
+
+/*
+Сегменты (shards) кэша. Раньше весь кэш защищала одна блокировка, и при большом количестве одновременных запросов
+(HTTP-запросы к show → query) горутины ждали друг друга, а «сборщик» мусора держал её, пока проверял все объекты.
+
+1) Структура shard – сегмент кэша со своей блокировкой mu, объектами items, порядком вытеснения policy, объёмом bytes,
+счётчиками stats и ограничениями maxEntries и maxBytes (доля общих ограничений Options, округлённая вверх).
+Политика вытеснения действует внутри сегмента: вытесняется давно использованный (LRU) или редко используемый (LFU)
+объект своего сегмента, а не всего кэша. При равномерном хешировании ключей это почти не отличается от общей очереди.
+2) Функции shard (set, get, delete, deleteExpired, remove, evict) вызываются функциями Cache для сегмента ключа
+и возвращают вытесненные объекты: OnEvict вызывается уже без блокировки сегмента (функция Cache.notify).
+3) Функция hashKey – FNV-1a для строковых и целочисленных ключей без выделения памяти; для остальных типов –
+FNV-1a от fmt.Sprint(key) (медленнее, для таких ключей лучше задать Options.Hash).
+*/
+
+type shard[K comparable, V any] struct {
+	mu         sync.Mutex
+	items      map[K]*entry[K, V]
+	policy     policy[K, V]
+	bytes      int64
+	stats      Stats
+	maxEntries int
+	maxBytes   int64
+}
+
+func newShard[K comparable, V any](p Policy, maxEntries int, maxBytes int64) *shard[K, V] {
+	return &shard[K, V]{
+		items:      make(map[K]*entry[K, V]),
+		policy:     newPolicy[K, V](p),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+	}
+}
+
+func (s *shard[K, V]) set(e *entry[K, V], now int64) (evicted []eviction[K, V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, found := s.items[e.key]; found {
+		s.remove(old)
+	}
+	if s.maxBytes > 0 && e.size > s.maxBytes {
+		s.stats.EvictedBytes++
+		return append(evicted, eviction[K, V]{e.key, e.value, EvictBytes})
+	}
+	evicted = s.evict(now, e.size, evicted)
+	s.items[e.key] = e
+	s.bytes += e.size
+	s.policy.add(e)
+	return evicted
+}
+
+func (s *shard[K, V]) get(key K, now int64) (value V, ok bool, evicted []eviction[K, V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, found := s.items[key]
+	if !found {
+		s.stats.Misses++
+		return value, false, nil
+	}
+	if e.expired(now) {
+		s.stats.Misses++
+		s.stats.EvictedExpired++
+		s.remove(e)
+		return value, false, []eviction[K, V]{{e.key, e.value, EvictExpired}}
+	}
+	s.stats.Hits++
+	s.policy.touch(e)
+	return e.value, true, nil
+}
+
+func (s *shard[K, V]) delete(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, found := s.items[key]
+	if !found {
+		return false
+	}
+	s.remove(e)
+	return true
+}
+
+func (s *shard[K, V]) deleteExpired(now int64) (evicted []eviction[K, V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.items {
+		if e.expired(now) {
+			s.remove(e)
+			s.stats.EvictedExpired++
+			evicted = append(evicted, eviction[K, V]{e.key, e.value, EvictExpired})
+		}
+	}
+	return evicted
+}
+
+// remove удаляет объект из items и из порядка вытеснения. Вызывается под блокировкой.
+func (s *shard[K, V]) remove(e *entry[K, V]) {
+	delete(s.items, e.key)
+	s.policy.remove(e)
+	s.bytes -= e.size
+}
+
+// evict освобождает место под новый объект размером size: вытесняет объекты, пока после добавления
+// не будут превышены ограничения сегмента. Вызывается под блокировкой до добавления объекта,
+// поэтому новый объект (в LFU – с наименьшим количеством обращений) не вытесняет сам себя.
+// Объект с истекшим сроком вытесняется с причиной EvictExpired, даже если выбран из-за ограничения.
+func (s *shard[K, V]) evict(now, size int64, evicted []eviction[K, V]) []eviction[K, V] {
+	for {
+		var reason EvictReason
+		switch {
+		case s.maxEntries > 0 && len(s.items) >= s.maxEntries:
+			reason = EvictEntries
+		case s.maxBytes > 0 && s.bytes+size > s.maxBytes:
+			reason = EvictBytes
+		default:
+			return evicted
+		}
+
+		e := s.policy.victim()
+		if e == nil {
+			return evicted
+		}
+		if e.expired(now) {
+			reason = EvictExpired
+		}
+		s.remove(e)
+		switch reason {
+		case EvictExpired:
+			s.stats.EvictedExpired++
+		case EvictEntries:
+			s.stats.EvictedEntries++
+		case EvictBytes:
+			s.stats.EvictedBytes++
+		}
+		evicted = append(evicted, eviction[K, V]{e.key, e.value, reason})
+	}
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+func hashKey[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return hashUint(uint64(k))
+	case int64:
+		return hashUint(uint64(k))
+	case uint64:
+		return hashUint(k)
+	}
+	return hashString(fmt.Sprint(key))
+}
+
+func hashString(s string) uint64 {
+	h := uint64(fnvOffset)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+	return h
+}
+
+func hashUint(v uint64) uint64 {
+	h := uint64(fnvOffset)
+	for i := 0; i < 8; i++ {
+		h ^= v & 0xff
+		h *= fnvPrime
+		v >>= 8
+	}
+	return h
+}
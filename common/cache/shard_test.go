@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+Тестирование сегментов кэша:
+1) Объекты распределяются по сегментам и находятся по ключу, Stats суммирует сегменты;
+2) MaxEntries делится между сегментами, общий размер кэша не превышает ограничение с учётом округления;
+3) «Сборщик» мусора обходит все сегменты;
+4) hashKey распределяет ключи вида order-N равномерно.
+Бенчмарк BenchmarkShards сравнивает кэш до разделения на сегменты (baseline=rwmutex-map: одна map под одной sync.RWMutex)
+с новым кэшем с одним и несколькими сегментами при одновременных Get/Set из многих горутин и работающем «сборщике» мусора. Кроме ns/op выводит задержку одной операции
+p50, p99 и p999 (наносекунды): go test -bench Shards -cpu 1,8,32 ./cache/.
+*/
+
+func TestShards(t *testing.T) {
+	c := NewWithOptions(Options[string, int]{DefaultTTL: time.Minute, Shards: 8})
+	keys := benchKeys(1000)
+	for i, key := range keys {
+		c.Set(key, i, DefaultTTL)
+	}
+	for i, key := range keys {
+		if got, ok := c.Get(key); !ok || got != i {
+			t.Fatalf("Get(%q) = %d, %v; want %d, true", key, got, ok, i)
+		}
+	}
+	c.Get("missing")
+
+	stats := c.Stats()
+	if stats.Shards != 8 || stats.Entries != 1000 || stats.Hits != 1000 || stats.Misses != 1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	for i, s := range c.shards {
+		if len(s.items) == 0 {
+			t.Fatalf("shard %d is empty", i)
+		}
+	}
+	if !c.Delete(keys[0]) || c.Len() != 999 {
+		t.Fatalf("Delete: Len = %d, want 999", c.Len())
+	}
+}
+
+func TestShardsMaxEntries(t *testing.T) {
+	c := NewWithOptions(Options[string, int]{MaxEntries: 100, Shards: 8})
+	for i, key := range benchKeys(1000) {
+		c.Set(key, i, DefaultTTL)
+	}
+
+	// 100 / 8 = 12.5 → 13 объектов на сегмент.
+	stats := c.Stats()
+	if stats.Entries > 8*13 || stats.Entries < 90 {
+		t.Fatalf("Entries = %d, want about 100", stats.Entries)
+	}
+	if stats.EvictedEntries != uint64(1000-stats.Entries) {
+		t.Fatalf("EvictedEntries = %d, want %d", stats.EvictedEntries, 1000-stats.Entries)
+	}
+}
+
+func TestShardsJanitor(t *testing.T) {
+	c := NewWithOptions(Options[string, int]{DefaultTTL: time.Millisecond, CleanupInterval: 8 * time.Millisecond, Shards: 4})
+	defer c.Close()
+	for i, key := range benchKeys(100) {
+		c.Set(key, i, DefaultTTL)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for c.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor left %d expired items", c.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHashKeySpread(t *testing.T) {
+	const shards = 16
+	var counts [shards]int
+	for _, key := range benchKeys(16000) {
+		counts[hashKey(key)%shards]++
+	}
+	for i, n := range counts {
+		if n < 800 || n > 1200 {
+			t.Fatalf("shard %d got %d of 16000 keys, want about 1000", i, n)
+		}
+	}
+	if hashKey(42) == hashKey(43) {
+		t.Fatal("int keys collide")
+	}
+}
+
+func BenchmarkShards(b *testing.B) {
+	keys := benchKeys(100000)
+
+	b.Run("baseline=rwmutex-map", func(b *testing.B) {
+		m := newRWMapCache(10 * time.Millisecond)
+		defer m.close()
+		for i, key := range keys {
+			m.set(key, i, time.Minute)
+		}
+		benchMixed(b, keys, func(key string) { m.get(key) }, func(key string, i int) { m.set(key, i, time.Minute) })
+	})
+
+	for _, shards := range []int{1, 16, 64} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			c := NewWithOptions(Options[string, int]{
+				DefaultTTL:      time.Minute,
+				CleanupInterval: 10 * time.Millisecond,
+				MaxEntries:      len(keys),
+				Shards:          shards,
+			})
+			defer c.Close()
+			for i, key := range keys {
+				c.Set(key, i, DefaultTTL)
+			}
+			benchMixed(b, keys, func(key string) { c.Get(key) }, func(key string, i int) { c.Set(key, i, DefaultTTL) })
+		})
+	}
+}
+
+// benchMixed выполняет Get и Set (каждая десятая операция) из многих горутин и выводит задержки p50, p99 и p999.
+func benchMixed(b *testing.B, keys []string, get func(key string), set func(key string, i int)) {
+	var mu sync.Mutex
+	var latencies []time.Duration
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		local := make([]time.Duration, 0, 1024)
+		i := 0
+		for pb.Next() {
+			key := keys[(i*7919)%len(keys)]
+			start := time.Now()
+			if i%10 == 0 {
+				set(key, i)
+			} else {
+				get(key)
+			}
+			local = append(local, time.Since(start))
+			i++
+		}
+		mu.Lock()
+		latencies = append(latencies, local...)
+		mu.Unlock()
+	})
+	b.StopTimer()
+
+	reportPercentiles(b, latencies)
+}
+
+// rwMapCache – кэш до разделения на сегменты в простейшем виде: одна map под одной sync.RWMutex,
+// Get – под блокировкой чтения, Set и «сборщик» мусора – под блокировкой записи. Используется только как база сравнения.
+type rwMapCache struct {
+	mu    sync.RWMutex
+	items map[string]rwMapItem
+	stop  chan struct{}
+}
+
+type rwMapItem struct {
+	value      int
+	expiration int64
+}
+
+func newRWMapCache(cleanup time.Duration) *rwMapCache {
+	m := &rwMapCache{items: make(map[string]rwMapItem), stop: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(cleanup)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				now := time.Now().UnixNano()
+				m.mu.Lock()
+				for key, item := range m.items {
+					if now > item.expiration {
+						delete(m.items, key)
+					}
+				}
+				m.mu.Unlock()
+			}
+		}
+	}()
+	return m
+}
+
+func (m *rwMapCache) set(key string, value int, ttl time.Duration) {
+	m.mu.Lock()
+	m.items[key] = rwMapItem{value: value, expiration: time.Now().Add(ttl).UnixNano()}
+	m.mu.Unlock()
+}
+
+func (m *rwMapCache) get(key string) (int, bool) {
+	m.mu.RLock()
+	item, ok := m.items[key]
+	m.mu.RUnlock()
+	if !ok || time.Now().UnixNano() > item.expiration {
+		return 0, false
+	}
+	return item.value, true
+}
+
+func (m *rwMapCache) close() {
+	close(m.stop)
+}
+
+func reportPercentiles(b *testing.B, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	for _, p := range []struct {
+		unit string
+		q    float64
+	}{{"p50-ns", 0.50}, {"p99-ns", 0.99}, {"p999-ns", 0.999}} {
+		b.ReportMetric(float64(latencies[int(p.q*float64(len(latencies)-1))]), p.unit)
+	}
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+Снимок кэша на диске: после перезапуска (в том числе аварийного) кэш восстанавливается из файла.
+Для save это важно: кэш – источник повторного сохранения заказа, если запись в Postgres не удалась.
+
+1) Формат снимка – JSON Lines (одна JSON-запись в строке), версия snapshotVersion:
+	1.1) Первая строка – заголовок snapshotHeader: формат, версия, дата создания и количество объектов;
+	1.2) Далее – по одной строке snapshotEntry на объект: ключ, значение, дата создания и срок хранения (UnixNano, 0 – без срока).
+	В LRU объекты идут от давно использованных к недавним, поэтому после восстановления порядок вытеснения сохраняется;
+	1.3) Последняя строка – snapshotTrailer: количество объектов и контрольная сумма CRC-32C всех предыдущих строк.
+	Ключ и значение должны кодироваться в JSON (модели заказов – кодируются).
+2) Функция Snapshot записывает снимок в io.Writer и возвращает количество объектов. Объекты копируются под блокировкой
+сегмента (по очереди), а кодируются уже без неё, поэтому запись снимка не останавливает Get и Set.
+3) Функция Restore читает снимок из io.Reader целиком, проверяет заголовок, количество объектов и контрольную сумму
+и только после этого добавляет объекты в кэш: повреждённый или недописанный снимок не загружается частично
+(ошибка ErrSnapshotCorrupt). Объекты с истекшим сроком хранения пропускаются. Возвращает количество восстановленных
+и пропущенных объектов. Ограничения MaxEntries и MaxBytes действуют как при Set.
+4) Функция SaveFile атомарно записывает снимок в файл: во временный файл в том же каталоге, fsync, rename.
+После сбоя на диске остаётся либо прежний, либо новый снимок целиком. Одновременные вызовы выполняются по очереди.
+5) Функция LoadFile восстанавливает кэш из файла (п. 3). Если файла нет – это не ошибка (первый запуск).
+6) Периодические снимки (поля Options):
+	6.1) SnapshotPath – путь к файлу снимка (пусто – снимки не записываются);
+	6.2) SnapshotInterval – интервал записи снимков (функция snapshotter). Если 0 – снимок записывается только при Close;
+	6.3) OnSnapshot – функция, которая вызывается после каждой записи снимка с количеством объектов и ошибкой (для логов).
+Функция Close перед очисткой кэша записывает последний снимок.
+7) Функция Range вызывает fn для каждого объекта с неистекшим сроком хранения (копия под блокировкой, fn – без неё),
+пока fn возвращает true. Используется, например, для повторного сохранения восстановленных заказов.
+*/
+
+const (
+	snapshotFormat  = "my.service.common/cache"
+	snapshotVersion = 1
+)
+
+var ErrSnapshotCorrupt = errors.New("cache: снимок повреждён")
+
+var snapshotTable = crc32.MakeTable(crc32.Castagnoli)
+
+type snapshotHeader struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Entries int       `json:"entries"`
+}
+
+type snapshotEntry[K comparable, V any] struct {
+	Key        K     `json:"k"`
+	Value      V     `json:"v"`
+	Created    int64 `json:"c"`
+	Expiration int64 `json:"e,omitempty"`
+}
+
+type snapshotTrailer struct {
+	Entries  int    `json:"entries"`
+	Checksum uint32 `json:"crc32c"`
+}
+
+func (c *Cache[K, V]) Snapshot(w io.Writer) (n int, err error) {
+
+	entries := c.snapshotEntries()
+
+	bw := bufio.NewWriter(w)
+	sum := crc32.New(snapshotTable)
+	out := io.MultiWriter(bw, sum)
+
+	header := snapshotHeader{
+		Format:  snapshotFormat,
+		Version: snapshotVersion,
+		Created: c.now(),
+		Entries: len(entries),
+	}
+	if err := writeLine(out, header); err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if err := writeLine(out, e); err != nil {
+			return 0, err
+		}
+	}
+	if err := writeLine(bw, snapshotTrailer{Entries: len(entries), Checksum: sum.Sum32()}); err != nil {
+		return 0, err
+	}
+	return len(entries), bw.Flush()
+}
+
+func (c *Cache[K, V]) Restore(r io.Reader) (restored, expired int, err error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	body, trailerLine, err := splitTrailer(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	var trailer snapshotTrailer
+	if err := json.Unmarshal(trailerLine, &trailer); err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if crc32.Checksum(body, snapshotTable) != trailer.Checksum {
+		return 0, 0, fmt.Errorf("%w: контрольная сумма не совпадает", ErrSnapshotCorrupt)
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(body, []byte("\n")), []byte("\n"))
+	var header snapshotHeader
+	if err := json.Unmarshal(lines[0], &header); err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if header.Format != snapshotFormat || header.Version != snapshotVersion {
+		return 0, 0, fmt.Errorf("cache: неподдерживаемый снимок %s версии %d", header.Format, header.Version)
+	}
+	lines = lines[1:]
+	if len(lines) != header.Entries || len(lines) != trailer.Entries {
+		return 0, 0, fmt.Errorf("%w: объектов %d, ожидалось %d", ErrSnapshotCorrupt, len(lines), header.Entries)
+	}
+
+	entries := make([]snapshotEntry[K, V], len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal(line, &entries[i]); err != nil {
+			return 0, 0, fmt.Errorf("%w: объект %d: %v", ErrSnapshotCorrupt, i+1, err)
+		}
+	}
+
+	now := c.now().UnixNano()
+	for _, e := range entries {
+		if e.Expiration > 0 && now > e.Expiration {
+			expired++
+			continue
+		}
+		c.set(e.Key, e.Value, time.Unix(0, e.Created), e.Expiration, now)
+		restored++
+	}
+	return restored, expired, nil
+}
+
+func (c *Cache[K, V]) SaveFile(path string) (n int, err error) {
+
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if n, err = c.Snapshot(tmp); err != nil {
+		return 0, err
+	}
+	if err = tmp.Sync(); err != nil {
+		return 0, err
+	}
+	if err = tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+
+	// fsync каталога – чтобы rename пережил сбой питания. Не везде поддерживается, поэтому ошибка не важна.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return n, nil
+}
+
+func (c *Cache[K, V]) LoadFile(path string) (restored, expired int, err error) {
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	return c.Restore(f)
+}
+
+func (c *Cache[K, V]) Range(fn func(key K, value V) bool) {
+	for _, e := range c.snapshotEntries() {
+		if !fn(e.Key, e.Value) {
+			return
+		}
+	}
+}
+
+// snapshotEntries копирует объекты с неистекшим сроком хранения в порядке обхода политики вытеснения.
+// Сегменты копируются по очереди, поэтому снимок не останавливает весь кэш.
+func (c *Cache[K, V]) snapshotEntries() []snapshotEntry[K, V] {
+
+	now := c.now().UnixNano()
+
+	var entries []snapshotEntry[K, V]
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.policy.walk(func(e *entry[K, V]) {
+			if e.expired(now) {
+				return
+			}
+			entries = append(entries, snapshotEntry[K, V]{
+				Key:        e.key,
+				Value:      e.value,
+				Created:    e.created.UnixNano(),
+				Expiration: e.expiration,
+			})
+		})
+		s.mu.Unlock()
+	}
+	return entries
+}
+
+func (c *Cache[K, V]) saveSnapshot() {
+	n, err := c.SaveFile(c.opts.SnapshotPath)
+	if c.opts.OnSnapshot != nil {
+		c.opts.OnSnapshot(n, err)
+	}
+}
+
+func (c *Cache[K, V]) snapshotter() {
+
+	ticker := time.NewTicker(c.opts.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.saveSnapshot()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func writeLine(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// splitTrailer отделяет последнюю строку снимка (snapshotTrailer) от остальных.
+func splitTrailer(data []byte) (body, trailer []byte, err error) {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	i := bytes.LastIndexByte(data, '\n')
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%w: нет заголовка или итоговой строки", ErrSnapshotCorrupt)
+	}
+	return data[:i+1], data[i+1:], nil
+}
@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*
+Тестирование снимков кэша:
+1) Snapshot/Restore сохраняют значения, сроки хранения и порядок вытеснения LRU;
+2) Объекты, срок хранения которых истек к моменту восстановления, пропускаются;
+3) Повреждённый или недописанный снимок не загружается даже частично (ErrSnapshotCorrupt);
+4) SaveFile/LoadFile: файл заменяется целиком, временные файлы не остаются, отсутствующий файл – не ошибка;
+5) Close записывает последний снимок, если задан SnapshotPath.
+*/
+
+func TestSnapshotRestore(t *testing.T) {
+	src, clk := newTestCache(time.Minute)
+	src.Set("a", 1, DefaultTTL)
+	src.Set("b", 2, NoExpiration)
+	src.Set("c", 3, DefaultTTL)
+	src.Get("a") // порядок LRU: b, c, a
+
+	var buf bytes.Buffer
+	n, err := src.Snapshot(&buf)
+	if err != nil || n != 3 {
+		t.Fatalf("Snapshot = %d, %v; want 3, nil", n, err)
+	}
+
+	dst := NewWithOptions(Options[string, int]{MaxEntries: 3})
+	dst.now = clk.Now
+	restored, expired, err := dst.Restore(&buf)
+	if err != nil || restored != 3 || expired != 0 {
+		t.Fatalf("Restore = %d, %d, %v; want 3, 0, nil", restored, expired, err)
+	}
+	for key, want := range map[string]int{"a": 1, "b": 2, "c": 3} {
+		if got, ok := dst.Get(key); !ok || got != want {
+			t.Fatalf("Get(%q) = %d, %v; want %d, true", key, got, ok, want)
+		}
+	}
+
+	// Сроки хранения восстановлены: через минуту остаётся только объект без срока.
+	clk.Add(time.Minute + time.Second)
+	for key, want := range map[string]bool{"a": false, "b": true, "c": false} {
+		if _, ok := dst.Get(key); ok != want {
+			t.Fatalf("Get(%q) after TTL found = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestSnapshotLRUOrder(t *testing.T) {
+	src, clk := newTestCache(NoExpiration)
+	for _, key := range []string{"a", "b", "c"} {
+		src.Set(key, 0, DefaultTTL)
+	}
+	src.Get("a")
+
+	var buf bytes.Buffer
+	if _, err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := NewWithOptions(Options[string, int]{MaxEntries: 3})
+	dst.now = clk.Now
+	if _, _, err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Давнее всего использовался b – он и вытесняется первым.
+	dst.Set("d", 0, DefaultTTL)
+	if _, ok := dst.Get("b"); ok {
+		t.Fatal("b survived eviction after restore")
+	}
+}
+
+func TestRestoreSkipsExpired(t *testing.T) {
+	src, clk := newTestCache(time.Minute)
+	src.Set("short", 1, time.Second)
+	src.Set("long", 2, DefaultTTL)
+
+	var buf bytes.Buffer
+	if _, err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Add(2 * time.Second)
+	dst, _ := newTestCache(time.Minute)
+	dst.now = clk.Now
+	restored, expired, err := dst.Restore(&buf)
+	if err != nil || restored != 1 || expired != 1 {
+		t.Fatalf("Restore = %d, %d, %v; want 1, 1, nil", restored, expired, err)
+	}
+	if _, ok := dst.Get("short"); ok {
+		t.Fatal("expired entry restored")
+	}
+}
+
+func TestRestoreCorrupt(t *testing.T) {
+	src, _ := newTestCache(NoExpiration)
+	src.Set("a", 1, DefaultTTL)
+	src.Set("b", 2, DefaultTTL)
+
+	var buf bytes.Buffer
+	if _, err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	changed := bytes.Replace(data, []byte(`"v":2`), []byte(`"v":7`), 1)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	truncated := bytes.Join(lines[:len(lines)-2], nil) // без итоговой строки
+
+	for name, snapshot := range map[string][]byte{
+		"changed":   changed,
+		"truncated": truncated,
+		"empty":     nil,
+	} {
+		dst, _ := newTestCache(NoExpiration)
+		_, _, err := dst.Restore(bytes.NewReader(snapshot))
+		if !errors.Is(err, ErrSnapshotCorrupt) {
+			t.Fatalf("%s: err = %v, want %v", name, err, ErrSnapshotCorrupt)
+		}
+		if dst.Len() != 0 {
+			t.Fatalf("%s: %d entries restored from corrupt snapshot", name, dst.Len())
+		}
+	}
+}
+
+func TestSaveLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.snapshot")
+
+	dst, _ := newTestCache(NoExpiration)
+	if restored, expired, err := dst.LoadFile(path); err != nil || restored != 0 || expired != 0 {
+		t.Fatalf("LoadFile(missing) = %d, %d, %v; want 0, 0, nil", restored, expired, err)
+	}
+
+	src, _ := newTestCache(NoExpiration)
+	src.Set("a", 1, DefaultTTL)
+	if _, err := src.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	src.Set("b", 2, DefaultTTL)
+	if n, err := src.SaveFile(path); err != nil || n != 2 {
+		t.Fatalf("SaveFile = %d, %v; want 2, nil", n, err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("%d files in snapshot dir, want 1 (temporary files left)", len(files))
+	}
+
+	if restored, _, err := dst.LoadFile(path); err != nil || restored != 2 {
+		t.Fatalf("LoadFile = %d, %v; want 2, nil", restored, err)
+	}
+}
+
+func TestCloseWritesSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	var entries int
+	c := NewWithOptions(Options[string, int]{
+		SnapshotPath: path,
+		OnSnapshot: func(n int, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+			entries = n
+		},
+	})
+	c.Set("a", 1, DefaultTTL)
+	c.Close()
+	c.Close() // повторный Close не перезаписывает снимок пустым кэшем
+
+	if entries != 1 {
+		t.Fatalf("OnSnapshot entries = %d, want 1", entries)
+	}
+	dst, _ := newTestCache(NoExpiration)
+	if restored, _, err := dst.LoadFile(path); err != nil || restored != 1 {
+		t.Fatalf("LoadFile = %d, %v; want 1, nil", restored, err)
+	}
+}
@@ -0,0 +1,199 @@
+package cacheadmin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"my.service.common/cache"
+)
+
+/*
+Администрирование кэшей микросервисов (save, query) без доступа к поду: статистика, просмотр объекта по ключу,
+удаление объекта или объектов по префиксу ключа и полная очистка. Доступно через HTTP (файл http.go)
+и через запросы NATS (файл nats.go); обе части выполняют одни и те же операции (функция Do) и требуют токен.
+
+1) Интерфейс Target – кэш со строковыми ключами, которым можно управлять. Функция Wrap превращает
+*cache.Cache[string, V] в Target (значение возвращается как есть и кодируется в JSON при ответе).
+2) Структура Request – операция (Op) над кэшем Cache:
+	2.1) OpStats – статистика (cache.Stats) указанного кэша или всех кэшей, если Cache пустой;
+	2.2) OpInspect – значение, дата создания, истечение срока хранения и размер объекта Key (без изменения счётчиков кэша);
+	2.3) OpPurge – удаление объекта Key;
+	2.4) OpPurgePrefix – удаление объектов, ключ которых начинается с Prefix (пустой префикс – ошибка, для этого есть OpFlush);
+	2.5) OpFlush – удаление всех объектов.
+	Token – токен доступа (для NATS; в HTTP передаётся в заголовке Authorization: Bearer).
+3) Структура Response – ответ: имя экземпляра микросервиса (Instance), статистика, объект (Entry) или количество
+удалённых объектов (Removed), ошибка (Error).
+4) Структура Admin – набор кэшей по именам (функция Add), токен и имя экземпляра. Конструктор New.
+Пустой токен не допускается: без токена любой, у кого есть доступ к сети или NATS, мог бы очистить кэш.
+5) Функция Do выполняет Request без проверки токена (её выполняют http.go и nats.go функцией authorized).
+Ошибки: ErrUnknownCache, ErrUnknownOp, ErrBadRequest, ErrNotFound.
+6) Функция authorized сравнивает токен за постоянное время (crypto/subtle).
+*/
+
+const (
+	OpStats       = "stats"
+	OpInspect     = "inspect"
+	OpPurge       = "purge"
+	OpPurgePrefix = "purge_prefix"
+	OpFlush       = "flush"
+)
+
+var (
+	ErrUnauthorized = errors.New("cacheadmin: неверный токен")
+	ErrUnknownCache = errors.New("cacheadmin: неизвестный кэш")
+	ErrUnknownOp    = errors.New("cacheadmin: неизвестная операция")
+	ErrBadRequest   = errors.New("cacheadmin: неверный запрос")
+	ErrNotFound     = errors.New("cacheadmin: объект не найден")
+)
+
+type Target interface {
+	Stats() cache.Stats
+	Inspect(key string) (value any, info cache.EntryInfo, ok bool)
+	Purge(key string) bool
+	PurgePrefix(prefix string) int
+	Flush() int
+}
+
+type wrapped[V any] struct {
+	c *cache.Cache[string, V]
+}
+
+func Wrap[V any](c *cache.Cache[string, V]) Target {
+	return wrapped[V]{c}
+}
+
+func (w wrapped[V]) Stats() cache.Stats { return w.c.Stats() }
+
+func (w wrapped[V]) Inspect(key string) (any, cache.EntryInfo, bool) {
+	return w.c.Peek(key)
+}
+
+func (w wrapped[V]) Purge(key string) bool { return w.c.Delete(key) }
+
+func (w wrapped[V]) PurgePrefix(prefix string) int {
+	return w.c.DeleteFunc(func(key string) bool { return strings.HasPrefix(key, prefix) })
+}
+
+func (w wrapped[V]) Flush() int { return w.c.Flush() }
+
+type Request struct {
+	Token  string `json:"token,omitempty"`
+	Op     string `json:"op"`
+	Cache  string `json:"cache,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+type Entry struct {
+	Cache   string     `json:"cache"`
+	Key     string     `json:"key"`
+	Value   any        `json:"value"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"`
+	TTL     string     `json:"ttl,omitempty"`
+	Size    int64      `json:"size,omitempty"`
+}
+
+type Response struct {
+	Instance string                 `json:"instance,omitempty"`
+	Stats    map[string]cache.Stats `json:"stats,omitempty"`
+	Entry    *Entry                 `json:"entry,omitempty"`
+	Removed  *int                   `json:"removed,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+type Admin struct {
+	token    string
+	instance string
+	caches   map[string]Target
+}
+
+func New(token, instance string) (*Admin, error) {
+	if token == "" {
+		return nil, fmt.Errorf("cacheadmin: не задан токен доступа")
+	}
+	return &Admin{
+		token:    token,
+		instance: instance,
+		caches:   make(map[string]Target),
+	}, nil
+}
+
+// Add регистрирует кэш под именем name. Вызывается до Handler и Subscribe.
+func (a *Admin) Add(name string, target Target) {
+	a.caches[name] = target
+}
+
+func (a *Admin) Do(req Request) (resp Response, err error) {
+
+	resp.Instance = a.instance
+
+	if req.Op == OpStats && req.Cache == "" {
+		resp.Stats = make(map[string]cache.Stats, len(a.caches))
+		for _, name := range a.names() {
+			resp.Stats[name] = a.caches[name].Stats()
+		}
+		return resp, nil
+	}
+
+	target, found := a.caches[req.Cache]
+	if !found {
+		return resp, fmt.Errorf("%w: %q (есть: %s)", ErrUnknownCache, req.Cache, strings.Join(a.names(), ", "))
+	}
+
+	switch req.Op {
+	case OpStats:
+		resp.Stats = map[string]cache.Stats{req.Cache: target.Stats()}
+	case OpInspect:
+		if req.Key == "" {
+			return resp, fmt.Errorf("%w: не задан key", ErrBadRequest)
+		}
+		value, info, ok := target.Inspect(req.Key)
+		if !ok {
+			return resp, fmt.Errorf("%w: %s", ErrNotFound, req.Key)
+		}
+		resp.Entry = &Entry{Cache: req.Cache, Key: req.Key, Value: value, Created: info.Created, Size: info.Size}
+		if !info.Expires.IsZero() {
+			resp.Entry.Expires = &info.Expires
+			resp.Entry.TTL = time.Until(info.Expires).Round(time.Second).String()
+		}
+	case OpPurge:
+		if req.Key == "" {
+			return resp, fmt.Errorf("%w: не задан key", ErrBadRequest)
+		}
+		removed := 0
+		if target.Purge(req.Key) {
+			removed = 1
+		}
+		resp.Removed = &removed
+	case OpPurgePrefix:
+		if req.Prefix == "" {
+			return resp, fmt.Errorf("%w: не задан prefix (для очистки всего кэша – %s)", ErrBadRequest, OpFlush)
+		}
+		removed := target.PurgePrefix(req.Prefix)
+		resp.Removed = &removed
+	case OpFlush:
+		removed := target.Flush()
+		resp.Removed = &removed
+	default:
+		return resp, fmt.Errorf("%w: %q", ErrUnknownOp, req.Op)
+	}
+	return resp, nil
+}
+
+func (a *Admin) authorized(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+func (a *Admin) names() []string {
+	names := make([]string, 0, len(a.caches))
+	for name := range a.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
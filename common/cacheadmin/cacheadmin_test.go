@@ -0,0 +1,167 @@
+package cacheadmin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"my.service.common/cache"
+)
+
+/*
+Тестирование администрирования кэшей (без сервера NATS – запросы NATS проверяются функцией handle):
+1) Без токена или с неверным токеном – 401 (HTTP) и ошибка в ответе (NATS), кэш не меняется;
+2) Маршруты HTTP: статистика, просмотр объекта, удаление объекта, по префиксу и очистка кэша, коды ошибок;
+3) New не принимает пустой токен;
+4) Start: без admin_addr и admin_subject ничего не запускает, без токена – ошибка, HTTP-сервер останавливается
+функцией остановки.
+*/
+
+const testToken = "secret"
+
+func newTestAdmin(t *testing.T) (*Admin, *cache.Cache[string, int]) {
+	t.Helper()
+	c := cache.New[string, int](time.Minute, 0)
+	for i, key := range []string{"order-1", "order-2", "other-1"} {
+		c.Set(key, i+1, cache.DefaultTTL)
+	}
+	a, err := New(testToken, "pod-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Add("orders", Wrap(c))
+	return a, c
+}
+
+func doHTTP(t *testing.T, a *Admin, method, target, token string) (int, Response) {
+	t.Helper()
+	r := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, r)
+
+	var resp Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: %v (%s)", method, target, err, w.Body)
+	}
+	return w.Code, resp
+}
+
+func TestHTTPUnauthorized(t *testing.T) {
+	a, c := newTestAdmin(t)
+	for _, token := range []string{"", "wrong"} {
+		code, resp := doHTTP(t, a, http.MethodDelete, "/admin/cache/orders", token)
+		if code != http.StatusUnauthorized || resp.Error == "" {
+			t.Fatalf("token %q: code = %d, resp = %+v", token, code, resp)
+		}
+	}
+	if c.Len() != 3 {
+		t.Fatal("unauthorized request changed the cache")
+	}
+}
+
+func TestHTTPRoutes(t *testing.T) {
+	a, c := newTestAdmin(t)
+
+	code, resp := doHTTP(t, a, http.MethodGet, "/admin/cache", testToken)
+	if code != http.StatusOK || resp.Stats["orders"].Entries != 3 || resp.Instance != "pod-1" {
+		t.Fatalf("stats: code = %d, resp = %+v", code, resp)
+	}
+
+	code, resp = doHTTP(t, a, http.MethodGet, "/admin/cache/orders/order-2", testToken)
+	if code != http.StatusOK || resp.Entry == nil || resp.Entry.Value != float64(2) || resp.Entry.Expires == nil {
+		t.Fatalf("inspect: code = %d, resp = %+v", code, resp)
+	}
+	if stats := c.Stats(); stats.Hits != 0 {
+		t.Fatal("inspect counted as a cache hit")
+	}
+
+	for _, tc := range []struct {
+		method, target string
+		code, removed  int
+	}{
+		{http.MethodDelete, "/admin/cache/orders/order-1", http.StatusOK, 1},
+		{http.MethodDelete, "/admin/cache/orders/order-1", http.StatusOK, 0},
+		{http.MethodDelete, "/admin/cache/orders?prefix=other-", http.StatusOK, 1},
+		{http.MethodDelete, "/admin/cache/orders", http.StatusOK, 1},
+	} {
+		code, resp := doHTTP(t, a, tc.method, tc.target, testToken)
+		if code != tc.code || resp.Removed == nil || *resp.Removed != tc.removed {
+			t.Fatalf("%s %s: code = %d, resp = %+v; want %d, removed %d", tc.method, tc.target, code, resp, tc.code, tc.removed)
+		}
+	}
+	if c.Len() != 0 {
+		t.Fatalf("Len = %d after flush, want 0", c.Len())
+	}
+
+	for _, tc := range []struct {
+		method, target string
+		code           int
+	}{
+		{http.MethodGet, "/admin/cache/missing", http.StatusNotFound},
+		{http.MethodGet, "/admin/cache/orders/order-1", http.StatusNotFound},
+		{http.MethodDelete, "/admin/cache/orders?prefix=", http.StatusBadRequest},
+		{http.MethodDelete, "/admin/cache", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/admin/cache/orders", http.StatusMethodNotAllowed},
+	} {
+		if code, _ := doHTTP(t, a, tc.method, tc.target, testToken); code != tc.code {
+			t.Fatalf("%s %s: code = %d, want %d", tc.method, tc.target, code, tc.code)
+		}
+	}
+}
+
+func TestNATSHandle(t *testing.T) {
+	a, c := newTestAdmin(t)
+
+	resp := a.handle([]byte(`{"op":"flush","cache":"orders"}`))
+	if resp.Error == "" || c.Len() != 3 {
+		t.Fatalf("request without token: %+v, Len = %d", resp, c.Len())
+	}
+
+	resp = a.handle([]byte(`{"token":"secret","op":"purge_prefix","cache":"orders","prefix":"order-"}`))
+	if resp.Error != "" || resp.Removed == nil || *resp.Removed != 2 {
+		t.Fatalf("purge_prefix: %+v", resp)
+	}
+
+	resp = a.handle([]byte(`{"token":"secret","op":"rename","cache":"orders"}`))
+	if resp.Error == "" {
+		t.Fatal("unknown op accepted")
+	}
+	if resp := a.handle([]byte(`not json`)); resp.Error == "" {
+		t.Fatal("malformed request accepted")
+	}
+}
+
+func TestNewRequiresToken(t *testing.T) {
+	if _, err := New("", "pod-1"); err == nil {
+		t.Fatal("New accepted empty token")
+	}
+}
+
+func TestStart(t *testing.T) {
+	shutdown, err := Start(nil, Options{Token: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Start(nil, Options{Addr: "127.0.0.1:0"}); err == nil {
+		t.Fatal("Start accepted empty token")
+	}
+
+	c := cache.New[string, int](time.Minute, 0)
+	shutdown, err = Start(nil, Options{Addr: "127.0.0.1:0", Token: testToken, Caches: map[string]Target{"orders": Wrap(c)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package cacheadmin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+/*
+HTTP-интерфейс администрирования кэшей. Все запросы – с заголовком Authorization: Bearer <токен>, ответ – Response в JSON.
+
+1) Функция Handler возвращает http.Handler с маршрутами (префикс PathPrefix):
+	1.1) GET    /admin/cache                     – статистика всех кэшей (OpStats);
+	1.2) GET    /admin/cache/{cache}             – статистика одного кэша;
+	1.3) DELETE /admin/cache/{cache}             – очистка кэша (OpFlush), с параметром ?prefix=... – удаление по префиксу (OpPurgePrefix);
+	1.4) GET    /admin/cache/{cache}/{key}       – объект по ключу (OpInspect);
+	1.5) DELETE /admin/cache/{cache}/{key}       – удаление объекта (OpPurge).
+2) Функция httpStatus – код ответа для ошибки Do: 400 (неверный запрос), 401 (токен), 404 (кэш или объект не найден),
+405 (метод не поддерживается маршрутом).
+*/
+
+const PathPrefix = "/admin/cache"
+
+var errMethod = errors.New("cacheadmin: метод не поддерживается")
+
+func (a *Admin) Handler() http.Handler {
+	return http.HandlerFunc(a.serveHTTP)
+}
+
+func (a *Admin) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !a.authorized(token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		a.writeHTTP(w, Response{Instance: a.instance}, ErrUnauthorized)
+		return
+	}
+
+	req, err := httpRequest(r)
+	if err != nil {
+		a.writeHTTP(w, Response{Instance: a.instance}, err)
+		return
+	}
+	resp, err := a.Do(req)
+	a.writeHTTP(w, resp, err)
+}
+
+func httpRequest(r *http.Request) (req Request, err error) {
+
+	path := strings.TrimPrefix(r.URL.Path, PathPrefix)
+	if path == r.URL.Path || (path != "" && path[0] != '/') {
+		return req, ErrNotFound
+	}
+	path = strings.Trim(path, "/")
+
+	var key string
+	req.Cache, key, _ = strings.Cut(path, "/")
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		req.Op = OpStats
+	case r.Method == http.MethodGet:
+		req.Op, req.Key = OpInspect, key
+	case r.Method == http.MethodDelete && req.Cache == "":
+		return req, errMethod
+	case r.Method == http.MethodDelete && key != "":
+		req.Op, req.Key = OpPurge, key
+	case r.Method == http.MethodDelete && r.URL.Query().Has("prefix"):
+		req.Op, req.Prefix = OpPurgePrefix, r.URL.Query().Get("prefix")
+	case r.Method == http.MethodDelete:
+		req.Op = OpFlush
+	default:
+		return req, errMethod
+	}
+	return req, nil
+}
+
+func (a *Admin) writeHTTP(w http.ResponseWriter, resp Response, err error) {
+	status := http.StatusOK
+	if err != nil {
+		status = httpStatus(err)
+		resp.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrUnknownCache), errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errMethod):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, ErrBadRequest), errors.Is(err, ErrUnknownOp):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
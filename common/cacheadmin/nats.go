@@ -0,0 +1,71 @@
+package cacheadmin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+/*
+Интерфейс администрирования кэшей через запросы NATS (nats request): запрос – Request в JSON (с полем token),
+ответ – Response в JSON в inbox запроса.
+
+1) Функция Subscribe подписывается на subject и на subject.<instance>:
+	1.1) subject – общий для всех экземпляров микросервиса: отвечают все, nats request получает первый ответ
+	(удобно для статистики любого пода; операции очистки лучше отправлять конкретному экземпляру);
+	1.2) subject.<instance> – только этот экземпляр (имя экземпляра – в поле instance каждого ответа).
+	Подписки закрываются вместе с соединением (Drain при остановке микросервиса).
+2) Функция handleMsg разбирает запрос, проверяет токен (authorized), выполняет Do и отправляет ответ.
+Запрос без inbox (Publish вместо Request) выполняется без ответа.
+*/
+
+func (a *Admin) Subscribe(nc *nats.Conn, subject string) ([]*nats.Subscription, error) {
+
+	subjects := []string{subject}
+	if a.instance != "" {
+		subjects = append(subjects, subject+"."+a.instance)
+	}
+
+	subs := make([]*nats.Subscription, 0, len(subjects))
+	for _, subj := range subjects {
+		sub, err := nc.Subscribe(subj, a.handleMsg)
+		if err != nil {
+			for _, s := range subs {
+				s.Unsubscribe()
+			}
+			return nil, fmt.Errorf("cacheadmin: подписка на %s: %w", subj, err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (a *Admin) handleMsg(m *nats.Msg) {
+	resp := a.handle(m.Data)
+	if m.Reply == "" {
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(Response{Instance: a.instance, Error: err.Error()})
+	}
+	m.Respond(data)
+}
+
+// handle выполняет запрос из тела сообщения NATS.
+func (a *Admin) handle(data []byte) Response {
+
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return Response{Instance: a.instance, Error: fmt.Errorf("%w: %v", ErrBadRequest, err).Error()}
+	}
+	if !a.authorized(req.Token) {
+		return Response{Instance: a.instance, Error: ErrUnauthorized.Error()}
+	}
+	resp, err := a.Do(req)
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
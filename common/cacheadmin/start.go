@@ -0,0 +1,93 @@
+package cacheadmin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+/*
+Запуск администрирования кэшей микросервиса (общий для save и query): микросервис передаёт только свои кэши и настройки.
+
+1) Структура Options – настройки запуска:
+	1.1) Addr – адрес отдельного HTTP-сервера (admin_addr), Subject – subject запросов NATS (admin_subject).
+	Пустые – соответствующая часть выключена, обе пустые – администрирование выключено;
+	1.2) Token – токен доступа (admin_token), Instance – имя экземпляра (пусто – имя хоста, т.е. имя пода);
+	1.3) Caches – кэши по именам (имя – поле cache запроса и часть пути HTTP);
+	1.4) InfoLog и ErrorLog – логи микросервиса (nil – сообщения не пишутся).
+2) Функция Start создаёт Admin (New, Add) и:
+	2.1) Если задан Subject – подписывается на запросы NATS (Subscribe: subject и subject.<instance>),
+	подписки закрываются вместе с соединением (Drain при остановке микросервиса);
+	2.2) Если задан Addr – запускает HTTP-сервер (маршруты PathPrefix). Ошибка сервера пишется в ErrorLog
+	и не останавливает микросервис: его основная работа важнее администрирования.
+Возвращает функцию остановки HTTP-сервера (вызывается в main при остановке); если сервер не запущен –
+функция остановки ничего не делает.
+*/
+
+type Options struct {
+	Addr     string
+	Subject  string
+	Token    string
+	Instance string
+	Caches   map[string]Target
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+func Start(nc *nats.Conn, opts Options) (shutdown func(ctx context.Context) error, err error) {
+
+	shutdown = func(context.Context) error { return nil }
+	if opts.Addr == "" && opts.Subject == "" {
+		return shutdown, nil
+	}
+
+	discard := log.New(io.Discard, "", 0)
+	if opts.InfoLog == nil {
+		opts.InfoLog = discard
+	}
+	if opts.ErrorLog == nil {
+		opts.ErrorLog = discard
+	}
+	if opts.Instance == "" {
+		opts.Instance, _ = os.Hostname()
+	}
+
+	admin, err := New(opts.Token, opts.Instance)
+	if err != nil {
+		return shutdown, err
+	}
+	for name, target := range opts.Caches {
+		admin.Add(name, target)
+	}
+
+	if opts.Subject != "" {
+		if _, err := admin.Subscribe(nc, opts.Subject); err != nil {
+			return shutdown, err
+		}
+		opts.InfoLog.Printf("Администрирование кэшей: NATS %s и %s.%s", opts.Subject, opts.Subject, opts.Instance)
+	}
+
+	if opts.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(PathPrefix, admin.Handler())
+		mux.Handle(PathPrefix+"/", admin.Handler())
+		srv := &http.Server{
+			Addr:     opts.Addr,
+			ErrorLog: opts.ErrorLog,
+			Handler:  mux,
+		}
+		go func() {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				opts.ErrorLog.Printf("Администрирование кэшей: %v", err)
+			}
+		}()
+		opts.InfoLog.Printf("Администрирование кэшей: HTTP %s%s", opts.Addr, PathPrefix)
+		shutdown = srv.Shutdown
+	}
+	return shutdown, nil
+}
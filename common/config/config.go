@@ -0,0 +1,341 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+/*
+Общий загрузчик настроек для микросервисов save, query, show и publisher.
+Настройки каждого микросервиса – это структура, поля которой размечены тегами:
+1) json – ключ в файле настроек (формат JSON). Неизвестные ключи игнорируются, поэтому один файл
+можно использовать для всех микросервисов;
+2) env – имя переменной окружения;
+3) flag – имя флага командной строки;
+4) usage – описание флага;
+5) required:"true" – поле не может быть пустым (нулевым);
+6) secret:"true" – значение не выводится функциями MustLoad и Print (например, пароль в DSN).
+Поддерживаемые типы полей: string, int, bool, float64, time.Duration и []string (через запятую).
+
+Функция Load принимает в качестве аргументов указатель на структуру с настройками (заполненную значениями
+по умолчанию), имя программы и аргументы командной строки. Порядок загрузки – каждый следующий источник
+переопределяет предыдущий:
+1) Значения по умолчанию;
+2) Файл настроек: путь из флага -config или переменной окружения CONFIG_FILE;
+3) Переменные окружения;
+4) Флаги командной строки.
+После загрузки проверяет обязательные поля и, если структура реализует интерфейс Validator, вызывает Validate.
+Возвращает аргументы, оставшиеся после флагов (например, команду и её аргументы), и ошибку (при наличии).
+
+Функция MustLoad – обёртка над Load для функций main: выводит итоговые настройки в виде таблицы
+(имя, значение и источник: default, file, env или flag), а при ошибке – выводит её и завершает программу.
+Функция Print выводит текущие значения настроек (без источников).
+*/
+
+type Validator interface {
+	Validate() error
+}
+
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+type field struct {
+	name     string
+	value    reflect.Value
+	jsonKey  string
+	env      string
+	flag     string
+	usage    string
+	required bool
+	secret   bool
+	source   string
+}
+
+func Load(cfg interface{}, program string, args []string) (rest []string, err error) {
+	_, rest, err = load(cfg, program, args)
+	return rest, err
+}
+
+func MustLoad(cfg interface{}, out io.Writer) (rest []string) {
+	fields, rest, err := load(cfg, os.Args[0], os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "Ошибка настроек: %v\n", err)
+		os.Exit(2)
+	}
+	printFields(out, fields)
+	return rest
+}
+
+func Print(out io.Writer, cfg interface{}) {
+	fields, err := collectFields(cfg)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return
+	}
+	printFields(out, fields)
+}
+
+func load(cfg interface{}, program string, args []string) ([]*field, []string, error) {
+
+	fields, err := collectFields(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fs := flag.NewFlagSet(program, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "Путь к файлу настроек (JSON)")
+	for _, f := range fields {
+		if f.flag != "" {
+			fs.Var(&fieldValue{f}, f.flag, f.usage)
+		}
+	}
+
+	// Флаги разбираются дважды: сначала только для того, чтобы узнать путь к файлу настроек.
+	probe := flag.NewFlagSet(program, flag.ContinueOnError)
+	probe.SetOutput(io.Discard)
+	probeFile := probe.String("config", *configFile, "")
+	for _, f := range fields {
+		if f.flag != "" {
+			probe.Var(&discardValue{isBool: f.value.Kind() == reflect.Bool}, f.flag, "")
+		}
+	}
+	_ = probe.Parse(args)
+
+	if *probeFile != "" {
+		if err := loadFile(*probeFile, fields); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if value, ok := os.LookupEnv(f.env); ok {
+			if err := setValue(f.value, value); err != nil {
+				return nil, nil, fmt.Errorf("переменная окружения %s: %w", f.env, err)
+			}
+			f.source = sourceEnv
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		if v, ok := fl.Value.(*fieldValue); ok {
+			v.source = sourceFlag
+		}
+	})
+
+	var missing []string
+	for _, f := range fields {
+		if f.required && f.value.IsZero() {
+			missing = append(missing, f.name)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, nil, fmt.Errorf("не заданы обязательные настройки: %s", strings.Join(missing, ", "))
+	}
+
+	if v, ok := cfg.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return fields, fs.Args(), nil
+}
+
+func printFields(out io.Writer, fields []*field) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Настройки:")
+	for _, f := range fields {
+		value := formatValue(f.value)
+		if f.secret && value != "" {
+			value = "***"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t(%s)\n", f.name, value, f.source)
+	}
+	w.Flush()
+}
+
+func collectFields(cfg interface{}) ([]*field, error) {
+
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: ожидался указатель на структуру, получен %T", cfg)
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	var fields []*field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		jsonKey := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if jsonKey == "-" {
+			continue
+		}
+		if jsonKey == "" {
+			jsonKey = sf.Name
+		}
+		fields = append(fields, &field{
+			name:     jsonKey,
+			value:    v.Field(i),
+			jsonKey:  jsonKey,
+			env:      sf.Tag.Get("env"),
+			flag:     sf.Tag.Get("flag"),
+			usage:    sf.Tag.Get("usage"),
+			required: sf.Tag.Get("required") == "true",
+			secret:   sf.Tag.Get("secret") == "true",
+			source:   sourceDefault,
+		})
+	}
+	return fields, nil
+}
+
+func loadFile(path string, fields []*field) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("файл настроек %s: %w", path, err)
+	}
+
+	for _, f := range fields {
+		value, ok := raw[f.jsonKey]
+		if !ok {
+			continue
+		}
+		if err := setJSON(f.value, value); err != nil {
+			return fmt.Errorf("файл настроек %s, ключ %s: %w", path, f.jsonKey, err)
+		}
+		f.source = sourceFile
+	}
+	return nil
+}
+
+// setJSON разбирает значение из файла: длительности можно указывать строкой ("5m") или числом наносекунд.
+func setJSON(v reflect.Value, raw json.RawMessage) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil && v.Kind() != reflect.Slice {
+		return setValue(v, s)
+	}
+	return json.Unmarshal(raw, v.Addr().Interface())
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("неподдерживаемый тип %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+type fieldValue struct {
+	*field
+}
+
+func (f *fieldValue) String() string {
+	if f == nil || f.field == nil {
+		return ""
+	}
+	if f.secret {
+		return "***"
+	}
+	return formatValue(f.value)
+}
+
+func (f *fieldValue) Set(s string) error {
+	return setValue(f.value, s)
+}
+
+func (f *fieldValue) IsBoolFlag() bool {
+	return f.value.Kind() == reflect.Bool
+}
+
+type discardValue struct {
+	isBool bool
+}
+
+func (d *discardValue) String() string   { return "" }
+func (d *discardValue) Set(string) error { return nil }
+func (d *discardValue) IsBoolFlag() bool { return d != nil && d.isBool }
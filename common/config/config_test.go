@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Тестирование загрузчика настроек:
+1) Порядок источников: значение по умолчанию < файл < переменная окружения < флаг;
+2) Обязательные поля и функция Validate;
+3) Вывод настроек: секретные значения не выводятся.
+*/
+
+type testConfig struct {
+	DSN     string        `json:"dsn" env:"TEST_CONFIG_DSN" flag:"dsn" required:"true" secret:"true"`
+	URL     string        `json:"url" env:"TEST_CONFIG_URL" flag:"url"`
+	Subject string        `json:"subject" env:"TEST_CONFIG_SUBJECT" flag:"subject"`
+	Timeout time.Duration `json:"timeout" env:"TEST_CONFIG_TIMEOUT" flag:"timeout"`
+	Batch   int           `json:"batch" flag:"batch"`
+	Verbose bool          `json:"verbose" flag:"verbose"`
+	Servers []string      `json:"servers" env:"TEST_CONFIG_SERVERS"`
+}
+
+func (c *testConfig) Validate() error {
+	if c.Batch < 0 {
+		return errors.New("batch < 0")
+	}
+	return nil
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `{"dsn": "file-dsn", "url": "file-url", "subject": "file-subject", "timeout": "3s", "unknown": 1}`)
+	setenv(t, "TEST_CONFIG_URL", "env-url")
+	setenv(t, "TEST_CONFIG_SUBJECT", "env-subject")
+	setenv(t, "TEST_CONFIG_SERVERS", "a, b,,c")
+
+	cfg := testConfig{URL: "default-url", Subject: "default-subject", Timeout: time.Second, Batch: 16}
+	rest, err := Load(&cfg, "test", []string{"-config", path, "-subject", "flag-subject", "-verbose", "list", "-n", "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := testConfig{
+		DSN:     "file-dsn",
+		URL:     "env-url",
+		Subject: "flag-subject",
+		Timeout: 3 * time.Second,
+		Batch:   16,
+		Verbose: true,
+		Servers: []string{"a", "b", "c"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got %+v, want %+v", cfg, want)
+	}
+	if !reflect.DeepEqual(rest, []string{"list", "-n", "5"}) {
+		t.Fatalf("unexpected rest args: %v", rest)
+	}
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	setenv(t, "CONFIG_FILE", writeFile(t, `{"dsn": "file-dsn", "batch": 4}`))
+
+	var cfg testConfig
+	if _, err := Load(&cfg, "test", nil); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DSN != "file-dsn" || cfg.Batch != 4 {
+		t.Fatalf("config file was not applied: %+v", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"required", nil, nil, "dsn"},
+		{"validate", nil, []string{"-dsn", "x", "-batch", "-1"}, "batch < 0"},
+		{"bad env", map[string]string{"TEST_CONFIG_TIMEOUT": "soon"}, []string{"-dsn", "x"}, "TEST_CONFIG_TIMEOUT"},
+		{"bad flag", nil, []string{"-dsn", "x", "-batch", "many"}, "batch"},
+		{"missing file", nil, []string{"-config", "/nonexistent/config.json"}, "config.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				setenv(t, k, v)
+			}
+			var cfg testConfig
+			_, err := Load(&cfg, "test", tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	cfg := testConfig{DSN: "password=postgres", URL: "demo.nats.io", Timeout: 5 * time.Second}
+
+	var out bytes.Buffer
+	Print(&out, &cfg)
+
+	if strings.Contains(out.String(), "password") {
+		t.Fatalf("secret value printed:\n%s", out.String())
+	}
+	for _, want := range []string{"dsn", "***", "demo.nats.io", "5s"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output:\n%s", want, out.String())
+		}
+	}
+}
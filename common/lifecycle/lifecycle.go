@@ -0,0 +1,61 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+/*
+Общие функции управления жизненным циклом микросервисов (save, query, show).
+
+1) Функция SignalContext возвращает контекст, который отменяется при получении SIGINT или SIGTERM
+(Kubernetes отправляет SIGTERM при остановке пода), и функцию stop для освобождения ресурсов.
+После вызова stop повторный сигнал обрабатывается по умолчанию – завершает процесс сразу.
+Порядок остановки каждого микросервиса:
+	1.1) Прекращает приём новой работы (запросы Fetch, подписки NATS, новые HTTP-соединения);
+	1.2) Завершает уже начатую работу: сохранение заказов в БД, ответы на запросы, HTTP-запросы;
+	1.3) Освобождает соединения (NATS – Drain, БД – Close) и очищает кэши.
+
+2) Функция Watchdog принимает в качестве аргументов контекст из п. 1, максимальное время остановки
+и функцию onTimeout. Если после отмены контекста процесс не завершился за указанное время – вызывает onTimeout
+(как правило, логирует ошибку и завершает процесс с ненулевым кодом). Если timeout <= 0 – ничего не делает.
+
+3) Функции MarkReady и MarkNotReady – готовность микросервиса для Kubernetes (readinessProbe: exec cat <файл>).
+MarkReady создаёт файл path, когда микросервис готов обрабатывать запросы (например, после прогрева кэша),
+MarkNotReady удаляет его в начале остановки. Если path пустой – ничего не делают.
+*/
+
+func SignalContext() (ctx context.Context, stop context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+func Watchdog(ctx context.Context, timeout time.Duration, onTimeout func()) {
+	if timeout <= 0 {
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		time.Sleep(timeout)
+		onTimeout()
+	}()
+}
+
+func MarkReady(path string) error {
+	if path == "" {
+		return nil
+	}
+	return os.WriteFile(path, []byte("ready\n"), 0o644)
+}
+
+func MarkNotReady(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package natsconn
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+/*
+Долгоживущее соединение с NATS – одно на каждый микросервис (save, query, show, publisher).
+Соединение создаётся при запуске в функции main и передаётся всем частям приложения, которым нужен NATS,
+поэтому запросы больше не тратят время на установку TCP (и TLS) соединения.
+
+1) Структура Options – параметры соединения:
+	1.1) URL и Name – адрес сервера и имя соединения (видно в мониторинге NATS);
+	1.2) ReconnectWait и MaxReconnects – пауза между попытками переподключения и их количество (-1 – без ограничения);
+	1.3) ReconnectBufSize – размер буфера (в байтах) для публикаций во время переподключения:
+	сообщения, опубликованные без соединения, отправляются после переподключения. Если буфер заполнен – Publish возвращает ошибку;
+	1.4) DrainTimeout – максимальное время Drain при закрытии соединения;
+	1.5) InfoLog и ErrorLog – логи приложения: в них записываются отключения, переподключения и асинхронные ошибки.
+
+2) Функция Connect принимает в качестве аргумента Options и возвращает соединение типа Conn.
+Если сервер недоступен при запуске – соединение не считается ошибкой, а переподключается в фоне
+(RetryOnFailedConnect), микросервис при этом запускается. Состояние соединения – функция Health.
+
+3) Структура Conn встраивает *nats.Conn, поэтому все функции nats (Publish, Request, Subscribe, JetStream)
+вызываются напрямую. Дополнительно:
+	3.1) Функция Health возвращает состояние соединения типа Health: состояние (connected, reconnecting,
+	disconnected, draining, closed), время последнего изменения состояния, количество отключений и переподключений,
+	адрес сервера и последнюю ошибку;
+	3.2) Функция Healthy – true, если соединение установлено;
+	3.3) Функция Drain закрывает соединение через Drain (подписки перестают получать сообщения,
+	уже полученные – обрабатываются, буфер публикаций отправляется) и ждёт закрытия, но не дольше DrainTimeout.
+
+4) Функция DrainSubscriptions останавливает только указанные подписки через Drain и ждёт, пока обработчики
+получат все уже доставленные сообщения (но не дольше ctx), – соединение остаётся открытым. Нужна, если обработчик
+передаёт сообщение в другие горутины (пул обработчиков): после DrainSubscriptions вызывающий код дожидается их
+ответов и только затем закрывает соединение (Drain).
+*/
+
+const (
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateDisconnected = "disconnected"
+	StateDraining     = "draining"
+	StateClosed       = "closed"
+)
+
+type Options struct {
+	URL              string
+	Name             string
+	ReconnectWait    time.Duration
+	MaxReconnects    int
+	ReconnectBufSize int
+	DrainTimeout     time.Duration
+	InfoLog          *log.Logger
+	ErrorLog         *log.Logger
+}
+
+type Health struct {
+	State       string    `json:"state"`
+	Since       time.Time `json:"since"`
+	URL         string    `json:"url"`
+	Disconnects uint64    `json:"disconnects"`
+	Reconnects  uint64    `json:"reconnects"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+type Conn struct {
+	*nats.Conn
+	opts   Options
+	closed chan struct{}
+
+	mu     sync.RWMutex
+	health Health
+}
+
+func Connect(opts Options) (*Conn, error) {
+
+	if opts.InfoLog == nil || opts.ErrorLog == nil {
+		return nil, fmt.Errorf("natsconn: не заданы InfoLog и ErrorLog")
+	}
+	if opts.ReconnectWait <= 0 {
+		opts.ReconnectWait = nats.DefaultReconnectWait
+	}
+	if opts.MaxReconnects == 0 {
+		opts.MaxReconnects = -1
+	}
+	if opts.ReconnectBufSize == 0 {
+		opts.ReconnectBufSize = nats.DefaultReconnectBufSize
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = nats.DefaultDrainTimeout
+	}
+
+	c := &Conn{
+		opts:   opts,
+		closed: make(chan struct{}),
+		health: Health{Since: time.Now(), URL: opts.URL},
+	}
+
+	nc, err := nats.Connect(opts.URL,
+		nats.Name(opts.Name),
+		nats.RetryOnFailedConnect(true),
+		nats.ReconnectWait(opts.ReconnectWait),
+		nats.MaxReconnects(opts.MaxReconnects),
+		nats.ReconnectBufSize(opts.ReconnectBufSize),
+		nats.DrainTimeout(opts.DrainTimeout),
+		nats.DisconnectErrHandler(c.onDisconnect),
+		nats.ReconnectHandler(c.onReconnect),
+		nats.ClosedHandler(c.onClosed),
+		nats.ErrorHandler(c.onError),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.Conn = nc
+
+	if nc.IsConnected() {
+		c.changed(nc.ConnectedUrl(), nil)
+		opts.InfoLog.Printf("NATS %s: соединение установлено (%s)", opts.Name, nc.ConnectedUrl())
+	} else {
+		opts.ErrorLog.Printf("NATS %s: сервер %s недоступен, подключение продолжается в фоне", opts.Name, opts.URL)
+	}
+	return c, nil
+}
+
+func (c *Conn) Health() Health {
+	c.mu.RLock()
+	health := c.health
+	c.mu.RUnlock()
+
+	switch c.Conn.Status() {
+	case nats.CONNECTED:
+		health.State = StateConnected
+	case nats.RECONNECTING, nats.CONNECTING:
+		health.State = StateReconnecting
+	case nats.DRAINING_SUBS, nats.DRAINING_PUBS:
+		health.State = StateDraining
+	case nats.CLOSED:
+		health.State = StateClosed
+	default:
+		health.State = StateDisconnected
+	}
+	return health
+}
+
+func (c *Conn) Healthy() bool {
+	return c.Conn != nil && c.Conn.IsConnected()
+}
+
+func (c *Conn) Drain() error {
+
+	if err := c.Conn.Drain(); err != nil {
+		if err == nats.ErrConnectionClosed {
+			return nil
+		}
+		// Во время переподключения nats.go закрывает соединение сразу, без Drain.
+		return fmt.Errorf("NATS %s: соединение закрыто без Drain: %w", c.opts.Name, err)
+	}
+
+	select {
+	case <-c.closed:
+		return nil
+	case <-time.After(c.opts.DrainTimeout + time.Second):
+		c.Conn.Close()
+		return nats.ErrDrainTimeout
+	}
+}
+
+func DrainSubscriptions(ctx context.Context, subs ...*nats.Subscription) error {
+
+	for _, sub := range subs {
+		if sub == nil {
+			continue
+		}
+		if err := sub.Drain(); err != nil && err != nats.ErrBadSubscription && err != nats.ErrConnectionClosed {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for _, sub := range subs {
+		for sub != nil && sub.IsValid() {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	}
+	return nil
+}
+
+// changed запоминает время изменения состояния, адрес сервера и ошибку (если есть).
+func (c *Conn) changed(url string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.health.Since = time.Now()
+	if url != "" {
+		c.health.URL = url
+	}
+	if err != nil {
+		c.health.LastError = err.Error()
+	}
+}
+
+func (c *Conn) onDisconnect(nc *nats.Conn, err error) {
+	if nc.IsClosed() || nc.IsDraining() {
+		return
+	}
+	c.changed("", err)
+	c.mu.Lock()
+	c.health.Disconnects++
+	c.mu.Unlock()
+	c.opts.ErrorLog.Printf("NATS %s: соединение потеряно: %v. Переподключение...", c.opts.Name, err)
+}
+
+// При RetryOnFailedConnect ReconnectHandler вызывается и при первом успешном подключении.
+func (c *Conn) onReconnect(nc *nats.Conn) {
+	c.changed(nc.ConnectedUrl(), nil)
+	c.mu.Lock()
+	first := c.health.Disconnects == 0
+	if !first {
+		c.health.Reconnects++
+	}
+	c.mu.Unlock()
+
+	if first {
+		c.opts.InfoLog.Printf("NATS %s: соединение установлено (%s)", c.opts.Name, nc.ConnectedUrl())
+		return
+	}
+	c.opts.InfoLog.Printf("NATS %s: переподключено к %s", c.opts.Name, nc.ConnectedUrl())
+}
+
+func (c *Conn) onClosed(nc *nats.Conn) {
+	c.changed("", nc.LastError())
+	c.opts.InfoLog.Printf("NATS %s: соединение закрыто", c.opts.Name)
+	close(c.closed)
+}
+
+func (c *Conn) onError(nc *nats.Conn, sub *nats.Subscription, err error) {
+	c.mu.Lock()
+	c.health.LastError = err.Error()
+	c.mu.Unlock()
+
+	if sub != nil {
+		c.opts.ErrorLog.Printf("NATS %s: подписка %s: %v", c.opts.Name, sub.Subject, err)
+		return
+	}
+	c.opts.ErrorLog.Printf("NATS %s: %v", c.opts.Name, err)
+}
@@ -0,0 +1,183 @@
+package peercache
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+/*
+Обнаружение экземпляров группы через NATS – альтернатива статическому списку экземпляров в настройках.
+
+1) Функция Discover подписывается на <subject>.announce и <subject>.leave и публикует имя этого экземпляра
+в <subject>.announce сразу и затем каждые interval. Экземпляр, от которого не было объявлений дольше 3 × interval,
+удаляется из группы (остановился аварийно или потерял соединение). При каждом изменении состава вызывает onChange
+с именами всех известных экземпляров (включая свой) – обычно это Group.SetPeers.
+Объявление – JSON announcement. Первое объявление просит остальные экземпляры ответить своими объявлениями сразу (hello),
+поэтому новый экземпляр узнаёт группу без ожидания interval.
+2) Функция Stop публикует <subject>.leave (остальные экземпляры сразу перестают отправлять ему запросы),
+отписывается и останавливает объявления. Вызывается при остановке микросервиса до Drain.
+3) Структура membership – состав группы без NATS (проверяется тестами): seen – получено объявление,
+leave – экземпляр остановился, prune – удаление экземпляров без объявлений дольше ttl, list – имена по алфавиту.
+Функции seen, leave и prune возвращают true, если состав изменился.
+*/
+
+type announcement struct {
+	Name  string `json:"name"`
+	Hello bool   `json:"hello,omitempty"`
+}
+
+type Discovery struct {
+	nc       *nats.Conn
+	subject  string
+	self     string
+	interval time.Duration
+	onChange func(peers []string)
+
+	mu      sync.Mutex
+	members *membership
+
+	subs     []*nats.Subscription
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func Discover(nc *nats.Conn, subject, self string, interval time.Duration, onChange func(peers []string)) (*Discovery, error) {
+
+	if err := ValidName(self); err != nil {
+		return nil, err
+	}
+	d := &Discovery{
+		nc:       nc,
+		subject:  subject,
+		self:     self,
+		interval: interval,
+		onChange: onChange,
+		members:  newMembership(self, 3*interval),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	announce, err := nc.Subscribe(subject+".announce", d.handleAnnounce)
+	if err != nil {
+		return nil, err
+	}
+	leave, err := nc.Subscribe(subject+".leave", d.handleLeave)
+	if err != nil {
+		announce.Unsubscribe()
+		return nil, err
+	}
+	d.subs = []*nats.Subscription{announce, leave}
+
+	d.announce(true)
+	go d.run()
+	return d, nil
+}
+
+func (d *Discovery) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		<-d.done
+		d.nc.Publish(d.subject+".leave", []byte(d.self))
+		for _, sub := range d.subs {
+			sub.Unsubscribe()
+		}
+	})
+}
+
+func (d *Discovery) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			d.announce(false)
+			d.update(func(m *membership) bool { return m.prune(now) })
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// announce публикует объявление: имя экземпляра и признак hello (просьба ответить объявлением).
+func (d *Discovery) announce(hello bool) {
+	data, _ := json.Marshal(announcement{Name: d.self, Hello: hello})
+	d.nc.Publish(d.subject+".announce", data)
+}
+
+func (d *Discovery) handleAnnounce(m *nats.Msg) {
+	var a announcement
+	if err := json.Unmarshal(m.Data, &a); err != nil || a.Name == d.self || ValidName(a.Name) != nil {
+		return
+	}
+	d.update(func(ms *membership) bool { return ms.seen(a.Name, time.Now()) })
+	if a.Hello {
+		d.announce(false)
+	}
+}
+
+func (d *Discovery) handleLeave(m *nats.Msg) {
+	peer := string(m.Data)
+	d.update(func(ms *membership) bool { return ms.leave(peer) })
+}
+
+// update изменяет состав группы и вызывает onChange, если он изменился. onChange вызывается под блокировкой,
+// чтобы изменения из разных горутин (подписки и объявления по таймеру) применялись по порядку.
+func (d *Discovery) update(fn func(m *membership) bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if fn(d.members) {
+		d.onChange(d.members.list())
+	}
+}
+
+type membership struct {
+	self     string
+	ttl      time.Duration
+	lastSeen map[string]time.Time
+}
+
+func newMembership(self string, ttl time.Duration) *membership {
+	return &membership{self: self, ttl: ttl, lastSeen: make(map[string]time.Time)}
+}
+
+func (m *membership) seen(peer string, now time.Time) bool {
+	_, known := m.lastSeen[peer]
+	m.lastSeen[peer] = now
+	return !known
+}
+
+func (m *membership) leave(peer string) bool {
+	if _, known := m.lastSeen[peer]; !known {
+		return false
+	}
+	delete(m.lastSeen, peer)
+	return true
+}
+
+func (m *membership) prune(now time.Time) (changed bool) {
+	for peer, seen := range m.lastSeen {
+		if now.Sub(seen) > m.ttl {
+			delete(m.lastSeen, peer)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (m *membership) list() []string {
+	peers := []string{m.self}
+	for peer := range m.lastSeen {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	return peers
+}
@@ -0,0 +1,238 @@
+package peercache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Распределённый кэш между экземплярами микросервиса (в духе groupcache). Каждый ключ принадлежит одному экземпляру
+(согласованное хеширование, файл ring.go): промах по чужому ключу сначала запрашивается у владельца
+и только если владелец недоступен – загружается самостоятельно (из БД). Так при N экземплярах каждый заказ
+загружается из БД и хранится в кэше один раз, а не N раз.
+
+1) Структура Options[V] – параметры группы:
+	1.1) Self – имя этого экземпляра (без точек, пробелов, * и > – оно входит в subject NATS);
+	1.2) Transport – доставка запросов другим экземплярам (NATSTransport или LocalTransport для тестов, файл transport.go);
+	1.3) Load – локальная загрузка значения (свой кэш и БД) с контекстом запроса. Вызывается для своих ключей,
+	для запросов других экземпляров (функция Serve) и при недоступности владельца. Load не должна обращаться к группе;
+	1.4) NotFound – ошибка Load «значения нет» (например, ErrOrderNotFound). Передаётся между экземплярами
+	как статус not_found и не считается недоступностью владельца;
+	1.5) Timeout – максимальное время запроса к владельцу (по умолчанию DefaultTimeout);
+	1.6) Replicas – количество виртуальных узлов на экземпляр (по умолчанию DefaultReplicas);
+	1.7) OnPeerError – функция, которая вызывается при ошибке запроса к владельцу (для логов);
+	1.8) ServeTimeout – максимальное время Load при запросе другого экземпляра (по умолчанию DefaultServeTimeout).
+	Может быть больше Timeout: запросивший экземпляр уже загрузил значение сам, но владелец всё равно
+	заканчивает загрузку и сохраняет значение в свой кэш для следующих запросов.
+2) Структура Group[V] – группа экземпляров. Конструктор New проверяет параметры; до вызова SetPeers
+группа состоит из одного Self, то есть все ключи загружаются локально.
+3) Функция SetPeers задаёт состав группы (Self добавляется всегда) и создаёт новое кольцо.
+Вызывается при запуске (статический список) или при изменении состава (Discovery, файл discovery.go).
+Функция IsLocal – этот экземпляр владеет ключом (например, чтобы решить, записывать ли в свой кэш значение,
+загруженное в обход Get).
+4) Функция Get возвращает значение по ключу: свой ключ – Load, чужой – запрос владельцу (не дольше Timeout
+и срока ctx); при ошибке запроса – Load, если срок ctx ещё не истёк.
+Объединение одновременных запросов одного ключа и запись в кэш – задача вызывающего кода и Load.
+5) Функция Serve – обработчик запроса другого экземпляра (интерфейс Handler): вызывает Load и кодирует ответ
+peerReply в JSON (статус ok, not_found или error). Запрос другого экземпляра никогда не пересылается дальше,
+даже если по своему кольцу этот экземпляр не владелец: разные представления о составе группы не приводят к циклам.
+6) Функция Stats – количество экземпляров, локальных загрузок, запросов владельцам, ошибок этих запросов
+и обслуженных запросов других экземпляров.
+*/
+
+const (
+	DefaultTimeout      = 500 * time.Millisecond
+	DefaultServeTimeout = 5 * time.Second
+)
+
+const (
+	statusOK       = "ok"
+	statusNotFound = "not_found"
+	statusError    = "error"
+)
+
+var ErrPeerUnavailable = errors.New("peercache: экземпляр недоступен")
+
+type Options[V any] struct {
+	Self         string
+	Transport    Transport
+	Load         func(ctx context.Context, key string) (V, error)
+	NotFound     error
+	Timeout      time.Duration
+	Replicas     int
+	OnPeerError  func(peer, key string, err error)
+	ServeTimeout time.Duration
+}
+
+type Stats struct {
+	Peers        int    `json:"peers"`
+	Local        uint64 `json:"local"`
+	Remote       uint64 `json:"remote"`
+	RemoteErrors uint64 `json:"remote_errors"`
+	Served       uint64 `json:"served"`
+}
+
+type peerReply[V any] struct {
+	Status string `json:"status"`
+	Value  V      `json:"value,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Group[V any] struct {
+	local        uint64
+	remote       uint64
+	remoteErrors uint64
+	served       uint64
+
+	opts Options[V]
+
+	mu   sync.RWMutex
+	ring *Ring
+}
+
+func New[V any](opts Options[V]) (*Group[V], error) {
+	if err := ValidName(opts.Self); err != nil {
+		return nil, err
+	}
+	if opts.Transport == nil || opts.Load == nil {
+		return nil, fmt.Errorf("peercache: не заданы Transport и Load")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.ServeTimeout <= 0 {
+		opts.ServeTimeout = DefaultServeTimeout
+	}
+	return &Group[V]{
+		opts: opts,
+		ring: NewRing(opts.Replicas, opts.Self),
+	}, nil
+}
+
+// ValidName проверяет, что имя экземпляра можно использовать как часть subject NATS.
+func ValidName(name string) error {
+	if name == "" || strings.ContainsAny(name, ". \t\r\n*>") {
+		return fmt.Errorf("peercache: недопустимое имя экземпляра %q", name)
+	}
+	return nil
+}
+
+func (g *Group[V]) SetPeers(peers ...string) {
+	ring := NewRing(g.opts.Replicas, append([]string{g.opts.Self}, peers...)...)
+	g.mu.Lock()
+	g.ring = ring
+	g.mu.Unlock()
+}
+
+func (g *Group[V]) Peers() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.ring.Peers()
+}
+
+func (g *Group[V]) Owner(key string) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.ring.Owner(key)
+}
+
+func (g *Group[V]) IsLocal(key string) bool {
+	return g.Owner(key) == g.opts.Self
+}
+
+func (g *Group[V]) Get(ctx context.Context, key string) (V, error) {
+
+	owner := g.Owner(key)
+	if owner == g.opts.Self {
+		atomic.AddUint64(&g.local, 1)
+		return g.opts.Load(ctx, key)
+	}
+
+	value, err := g.fetch(ctx, owner, key)
+	if err == nil || (g.opts.NotFound != nil && errors.Is(err, g.opts.NotFound)) {
+		atomic.AddUint64(&g.remote, 1)
+		return value, err
+	}
+
+	atomic.AddUint64(&g.remoteErrors, 1)
+	if g.opts.OnPeerError != nil {
+		g.opts.OnPeerError(owner, key, err)
+	}
+	if ctx.Err() != nil {
+		return value, ctx.Err()
+	}
+	atomic.AddUint64(&g.local, 1)
+	return g.opts.Load(ctx, key)
+}
+
+func (g *Group[V]) fetch(ctx context.Context, peer, key string) (value V, err error) {
+
+	ctx, cancel := context.WithTimeout(ctx, g.opts.Timeout)
+	defer cancel()
+
+	data, err := g.opts.Transport.Fetch(ctx, peer, key)
+	if err != nil {
+		return value, err
+	}
+	var reply peerReply[V]
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return value, fmt.Errorf("peercache: ответ %s: %w", peer, err)
+	}
+
+	switch reply.Status {
+	case statusOK:
+		return reply.Value, nil
+	case statusNotFound:
+		if g.opts.NotFound != nil {
+			return value, g.opts.NotFound
+		}
+	}
+	return value, fmt.Errorf("peercache: %s: %s", peer, reply.Error)
+}
+
+// busyReply – ответ статусом error без загрузки: экземпляр не может обслужить запрос сейчас (пул обработчиков заполнен).
+func busyReply(err error) []byte {
+	data, _ := json.Marshal(peerReply[struct{}]{Status: statusError, Error: "экземпляр занят: " + err.Error()})
+	return data
+}
+
+func (g *Group[V]) Serve(key string) []byte {
+
+	atomic.AddUint64(&g.served, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.opts.ServeTimeout)
+	defer cancel()
+
+	var reply peerReply[V]
+	value, err := g.opts.Load(ctx, key)
+	switch {
+	case err == nil:
+		reply.Status, reply.Value = statusOK, value
+	case g.opts.NotFound != nil && errors.Is(err, g.opts.NotFound):
+		reply.Status = statusNotFound
+	default:
+		reply.Status, reply.Error = statusError, err.Error()
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		data, _ = json.Marshal(peerReply[V]{Status: statusError, Error: err.Error()})
+	}
+	return data
+}
+
+func (g *Group[V]) Stats() Stats {
+	return Stats{
+		Peers:        len(g.Peers()),
+		Local:        atomic.LoadUint64(&g.local),
+		Remote:       atomic.LoadUint64(&g.remote),
+		RemoteErrors: atomic.LoadUint64(&g.remoteErrors),
+		Served:       atomic.LoadUint64(&g.served),
+	}
+}
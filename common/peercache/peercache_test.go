@@ -0,0 +1,291 @@
+package peercache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+Тестирование распределённого кэша без NATS – несколько экземпляров в одном процессе (LocalTransport):
+1) Кольцо: ключи распределяются равномерно, владелец одинаков при любом порядке экземпляров,
+при добавлении экземпляра владелец меняется примерно у 1/N ключей;
+2) Каждый ключ загружается только владельцем, остальные экземпляры получают его значение по запросу;
+3) Статус not_found передаётся как ошибка NotFound, а не как недоступность владельца;
+4) Если владелец недоступен – экземпляр загружает значение сам;
+5) Состав группы (membership): объявления, остановка экземпляра и удаление по истечении ttl;
+6) Если пул обработчиков владельца заполнен (serveWith, submit отказал) – владелец сразу отвечает ошибкой,
+а экземпляр загружает значение сам; без отказа запрос обслуживается через пул.
+*/
+
+var errNotFound = errors.New("not found")
+
+func TestRingSpread(t *testing.T) {
+	peers := []string{"query-a", "query-b", "query-c"}
+	ring := NewRing(0, peers...)
+	reversed := NewRing(0, "query-c", "query-b", "query-a", "query-a")
+
+	counts := make(map[string]int)
+	for i := 0; i < 30000; i++ {
+		key := "order-" + strconv.Itoa(i)
+		owner := ring.Owner(key)
+		if owner != reversed.Owner(key) {
+			t.Fatalf("owner of %s depends on peer order", key)
+		}
+		counts[owner]++
+	}
+	for _, peer := range peers {
+		if counts[peer] < 7000 || counts[peer] > 13000 {
+			t.Fatalf("peer %s owns %d of 30000 keys, want about 10000", peer, counts[peer])
+		}
+	}
+	if got := len(reversed.Peers()); got != 3 {
+		t.Fatalf("Peers = %d, want 3 (duplicates skipped)", got)
+	}
+	if NewRing(0).Owner("order-1") != "" {
+		t.Fatal("empty ring returned an owner")
+	}
+}
+
+func TestRingRebalance(t *testing.T) {
+	before := NewRing(0, "a", "b", "c")
+	after := NewRing(0, "a", "b", "c", "d")
+
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := "order-" + strconv.Itoa(i)
+		if o := before.Owner(key); o != after.Owner(key) {
+			if after.Owner(key) != "d" {
+				t.Fatalf("key %s moved from %s to %s, not to the new peer", key, o, after.Owner(key))
+			}
+			moved++
+		}
+	}
+	if moved < 1500 || moved > 3500 {
+		t.Fatalf("%d of 10000 keys moved, want about 2500", moved)
+	}
+}
+
+// testPeer – экземпляр группы с «БД» в памяти и счётчиком загрузок.
+type testPeer struct {
+	group *Group[string]
+	loads int64
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+func newTestGroup(t *testing.T, names ...string) (map[string]*testPeer, *LocalTransport) {
+	t.Helper()
+	transport := NewLocalTransport()
+	peers := make(map[string]*testPeer)
+
+	for _, name := range names {
+		p := &testPeer{cache: make(map[string]string)}
+		group, err := New(Options[string]{
+			Self:      name,
+			Transport: transport,
+			NotFound:  errNotFound,
+			Load:      p.load,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		group.SetPeers(names...)
+		p.group = group
+		transport.Register(name, group)
+		peers[name] = p
+	}
+	return peers, transport
+}
+
+// load – локальный кэш и «БД»: ключи missing-* не существуют.
+func (p *testPeer) load(ctx context.Context, key string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if v, ok := p.cache[key]; ok {
+		return v, nil
+	}
+	atomic.AddInt64(&p.loads, 1)
+	if len(key) > 8 && key[:8] == "missing-" {
+		return "", errNotFound
+	}
+	p.cache[key] = "value-" + key
+	return p.cache[key], nil
+}
+
+func TestGroupLoadsOnlyOnOwner(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	peers, _ := newTestGroup(t, names...)
+
+	keys := make([]string, 300)
+	for i := range keys {
+		keys[i] = "order-" + strconv.Itoa(i)
+	}
+	// Каждый экземпляр запрашивает каждый ключ.
+	for _, name := range names {
+		for _, key := range keys {
+			v, err := peers[name].group.Get(context.Background(), key)
+			if err != nil || v != "value-"+key {
+				t.Fatalf("%s: Get(%s) = %q, %v", name, key, v, err)
+			}
+		}
+	}
+
+	var total int64
+	for _, name := range names {
+		p := peers[name]
+		total += p.loads
+		for key := range p.cache {
+			if owner := p.group.Owner(key); owner != name || !p.group.IsLocal(key) {
+				t.Fatalf("%s cached %s owned by %s", name, key, owner)
+			}
+		}
+		if stats := p.group.Stats(); stats.Peers != 3 || stats.Remote == 0 || stats.Served == 0 || stats.RemoteErrors != 0 {
+			t.Fatalf("%s: Stats = %+v", name, stats)
+		}
+	}
+	if total != int64(len(keys)) {
+		t.Fatalf("loads = %d, want %d (each key loaded once)", total, len(keys))
+	}
+}
+
+func TestGroupNotFound(t *testing.T) {
+	peers, _ := newTestGroup(t, "a", "b")
+
+	var key string
+	for i := 0; ; i++ {
+		key = fmt.Sprintf("missing-%d", i)
+		if peers["a"].group.Owner(key) == "b" {
+			break
+		}
+	}
+	if _, err := peers["a"].group.Get(context.Background(), key); !errors.Is(err, errNotFound) {
+		t.Fatalf("Get(%s) err = %v, want %v", key, err, errNotFound)
+	}
+	if peers["a"].loads != 0 || peers["b"].loads != 1 {
+		t.Fatalf("loads a = %d, b = %d; want 0 and 1", peers["a"].loads, peers["b"].loads)
+	}
+}
+
+func TestGroupPeerDown(t *testing.T) {
+	peers, transport := newTestGroup(t, "a", "b")
+
+	var failed []string
+	peers["a"].group.opts.OnPeerError = func(peer, key string, err error) {
+		if !errors.Is(err, ErrPeerUnavailable) {
+			t.Errorf("OnPeerError err = %v", err)
+		}
+		failed = append(failed, peer)
+	}
+	transport.SetDown("b", true)
+
+	var key string
+	for i := 0; ; i++ {
+		key = "order-" + strconv.Itoa(i)
+		if peers["a"].group.Owner(key) == "b" {
+			break
+		}
+	}
+	v, err := peers["a"].group.Get(context.Background(), key)
+	if err != nil || v != "value-"+key {
+		t.Fatalf("Get(%s) = %q, %v", key, v, err)
+	}
+	if peers["a"].loads != 1 || len(failed) != 1 || failed[0] != "b" {
+		t.Fatalf("loads = %d, failed = %v; want local load after peer error", peers["a"].loads, failed)
+	}
+	if stats := peers["a"].group.Stats(); stats.RemoteErrors != 1 || stats.Local != 1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+}
+
+func TestNewValidatesName(t *testing.T) {
+	for _, name := range []string{"", "query.1", "query 1", "query*"} {
+		_, err := New(Options[string]{Self: name, Transport: NewLocalTransport(), Load: func(context.Context, string) (string, error) { return "", nil }})
+		if err == nil {
+			t.Fatalf("New accepted name %q", name)
+		}
+	}
+}
+
+func TestMembership(t *testing.T) {
+	start := time.Unix(1000, 0)
+	m := newMembership("a", 3*time.Second)
+
+	if !m.seen("b", start) || m.seen("b", start.Add(time.Second)) {
+		t.Fatal("seen: only the first announcement changes membership")
+	}
+	m.seen("c", start)
+	if got := fmt.Sprint(m.list()); got != "[a b c]" {
+		t.Fatalf("list = %s, want [a b c]", got)
+	}
+
+	// c не объявлялся дольше ttl, b – объявлялся через секунду.
+	if !m.prune(start.Add(3500*time.Millisecond)) || fmt.Sprint(m.list()) != "[a b]" {
+		t.Fatalf("prune: list = %v, want [a b]", m.list())
+	}
+	if !m.leave("b") || m.leave("b") || fmt.Sprint(m.list()) != "[a]" {
+		t.Fatalf("leave: list = %v, want [a]", m.list())
+	}
+}
+
+// pooledTransport – LocalTransport, в котором владелец обслуживает запросы через submit (как NATSTransport.Listen).
+type pooledTransport struct {
+	*LocalTransport
+	submit func(task func()) error
+}
+
+func (t pooledTransport) Fetch(ctx context.Context, peer, key string) ([]byte, error) {
+	t.mu.RLock()
+	h := t.handlers[peer]
+	t.mu.RUnlock()
+
+	reply := make(chan []byte, 1)
+	serveWith(t.submit, h, key, func(data []byte) { reply <- data })
+	select {
+	case data := <-reply:
+		return data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestGroupOwnerBusy(t *testing.T) {
+	peers, local := newTestGroup(t, "a", "b")
+	errFull := errors.New("queue full")
+	var submitted int32
+	transport := &pooledTransport{LocalTransport: local, submit: func(task func()) error {
+		if atomic.AddInt32(&submitted, 1) > 1 {
+			return errFull
+		}
+		go task()
+		return nil
+	}}
+	peers["a"].group.opts.Transport = transport
+
+	var keys []string
+	for i := 0; len(keys) < 2; i++ {
+		key := "order-" + strconv.Itoa(i)
+		if peers["a"].group.Owner(key) == "b" {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		v, err := peers["a"].group.Get(context.Background(), key)
+		if err != nil || v != "value-"+key {
+			t.Fatalf("Get(%s) = %q, %v", key, v, err)
+		}
+	}
+	if peers["b"].loads != 1 || peers["a"].loads != 1 {
+		t.Fatalf("loads a = %d, b = %d; want 1 and 1 (second request rejected by b's pool)", peers["a"].loads, peers["b"].loads)
+	}
+	if stats := peers["a"].group.Stats(); stats.Remote != 1 || stats.RemoteErrors != 1 || stats.Local != 1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+}
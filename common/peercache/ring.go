@@ -0,0 +1,77 @@
+package peercache
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+/*
+Согласованное хеширование (consistent hashing): каждому ключу – один экземпляр-владелец.
+При добавлении или удалении экземпляра меняется владелец только у части ключей (примерно 1/N),
+поэтому кэши остальных экземпляров остаются тёплыми.
+
+1) Структура Ring – кольцо хешей: каждый экземпляр занимает replicas точек (виртуальных узлов) – так ключи
+распределяются равномерно даже между двумя-тремя экземплярами. Кольцо не изменяется после создания:
+при изменении состава экземпляров создаётся новое (функция NewRing), поэтому его можно читать из нескольких горутин.
+2) Функция NewRing принимает количество виртуальных узлов на экземпляр (0 – DefaultReplicas) и имена экземпляров.
+Повторяющиеся и пустые имена пропускаются.
+3) Функция Owner возвращает владельца ключа: первую точку кольца с хешем не меньше хеша ключа (по кругу).
+Для пустого кольца – пустая строка.
+4) Функция Peers – имена экземпляров в кольце по алфавиту.
+*/
+
+const DefaultReplicas = 100
+
+type Ring struct {
+	hashes []uint32
+	owners map[uint32]string
+	peers  []string
+}
+
+func NewRing(replicas int, peers ...string) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	r := &Ring{owners: make(map[uint32]string)}
+
+	seen := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		if peer == "" || seen[peer] {
+			continue
+		}
+		seen[peer] = true
+		r.peers = append(r.peers, peer)
+	}
+	sort.Strings(r.peers)
+
+	for _, peer := range r.peers {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + "#" + peer))
+			// Совпадение хешей разных точек маловероятно; побеждает первый экземпляр по алфавиту.
+			if _, taken := r.owners[h]; taken {
+				continue
+			}
+			r.owners[h] = peer
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+func (r *Ring) Owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+func (r *Ring) Peers() []string {
+	return append([]string(nil), r.peers...)
+}
@@ -0,0 +1,113 @@
+package peercache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+/*
+Доставка запросов между экземплярами группы.
+
+1) Интерфейс Transport – функция Fetch отправляет ключ экземпляру peer и возвращает его ответ (результат Handler.Serve).
+Интерфейс Handler – обработчик запросов других экземпляров (Group.Serve).
+2) Структура NATSTransport – запросы через NATS (nats request): экземпляр name слушает subject <subject>.get.<name>
+(функция Listen), тело запроса – ключ, ответ – JSON (peerReply). Используется общее соединение микросервиса,
+подписка закрывается вместе с ним (Drain). Горутина подписки не выполняет Serve сама (медленная загрузка из БД
+задерживала бы ответы на остальные запросы), а передаёт его функции submit (функция serveWith):
+	2.1) submit – пул обработчиков микросервиса (например, workpool.Pool.TrySubmit): запросы других экземпляров
+	занимают те же обработчики, что и запросы пользователей, и число одновременных загрузок из БД ограничено пулом;
+	2.2) Если submit отказал (пул заполнен или закрыт) – сразу отвечаем статусом error (busyReply): запросивший
+	экземпляр загрузит значение сам, а не будет ждать Timeout;
+	2.3) submit = nil – Serve в отдельной горутине без ограничения (для тестов и микросервисов без пула).
+3) Структура LocalTransport – запросы внутри одного процесса, без NATS: для тестов с несколькими экземплярами.
+Функция SetDown имитирует недоступность экземпляра (Fetch возвращает ErrPeerUnavailable).
+*/
+
+type Transport interface {
+	Fetch(ctx context.Context, peer, key string) ([]byte, error)
+}
+
+type Handler interface {
+	Serve(key string) []byte
+}
+
+type NATSTransport struct {
+	nc      *nats.Conn
+	subject string
+}
+
+func NewNATSTransport(nc *nats.Conn, subject string) *NATSTransport {
+	return &NATSTransport{nc: nc, subject: subject}
+}
+
+func (t *NATSTransport) Fetch(ctx context.Context, peer, key string) ([]byte, error) {
+	msg, err := t.nc.RequestWithContext(ctx, t.getSubject(peer), []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrPeerUnavailable, peer, err)
+	}
+	return msg.Data, nil
+}
+
+func (t *NATSTransport) Listen(name string, h Handler, submit func(task func()) error) (*nats.Subscription, error) {
+	return t.nc.Subscribe(t.getSubject(name), func(m *nats.Msg) {
+		serveWith(submit, h, string(m.Data), func(data []byte) { m.Respond(data) })
+	})
+}
+
+func serveWith(submit func(task func()) error, h Handler, key string, respond func([]byte)) {
+	task := func() { respond(h.Serve(key)) }
+	if submit == nil {
+		go task()
+		return
+	}
+	if err := submit(task); err != nil {
+		respond(busyReply(err))
+	}
+}
+
+func (t *NATSTransport) getSubject(peer string) string {
+	return t.subject + ".get." + peer
+}
+
+type LocalTransport struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	down     map[string]bool
+}
+
+func NewLocalTransport() *LocalTransport {
+	return &LocalTransport{
+		handlers: make(map[string]Handler),
+		down:     make(map[string]bool),
+	}
+}
+
+func (t *LocalTransport) Register(name string, h Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers[name] = h
+}
+
+func (t *LocalTransport) SetDown(name string, down bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.down[name] = down
+}
+
+func (t *LocalTransport) Fetch(ctx context.Context, peer, key string) ([]byte, error) {
+	t.mu.RLock()
+	h, found := t.handlers[peer]
+	down := t.down[peer]
+	t.mu.RUnlock()
+
+	if !found || down {
+		return nil, fmt.Errorf("%w: %s", ErrPeerUnavailable, peer)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return h.Serve(key), nil
+}
@@ -0,0 +1,114 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Объединение одновременных запросов с одинаковым ключом (singleflight). Используется микросервисом query:
+одновременные запросы одного заказа, которого нет в кэше, выполняют один запрос в БД и одно заполнение кэша.
+
+1) Структура call – выполняющийся запрос: done (закрывается по окончании запроса), результат val и ошибка err.
+2) Структура Group[K, V] – группа запросов с ключами типа K и результатом типа V. Нулевое значение готово к работе.
+Состоит из:
+	2.1) deduplicated – количество вызовов, которые не выполняли функцию, а получили результат другого вызова;
+	2.2) mu и calls – выполняющиеся запросы по ключам.
+3) Функция Do принимает в качестве аргументов ключ и функцию fn. Если для ключа уже выполняется запрос – ждёт его
+окончания и возвращает тот же результат (shared = true). Иначе – выполняет fn сама. Результат не запоминается:
+следующий вызов после окончания запроса выполнит fn заново (хранение результатов – задача кэша).
+Если fn вызвала panic – panic продолжается в вызове, который выполнял fn, а ожидающие вызовы получают ErrPanicked.
+4) Функция DoContext – то же, что Do, но fn выполняется в отдельной горутине, а каждый вызов (и первый, и ожидающие)
+ждёт результата не дольше своего контекста ctx: по его окончании возвращает ctx.Err(), а запрос продолжается
+для остальных вызовов. Поэтому fn не должна использовать контекст вызова – у запроса свой срок (его задаёт fn).
+panic в fn не завершает процесс: все вызовы получают ErrPanicked.
+5) Функция Deduplicated – количество объединённых вызовов за время работы, InFlight – количество выполняющихся запросов.
+*/
+
+var ErrPanicked = errors.New("singleflight: запрос завершился panic")
+
+type call[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+type Group[K comparable, V any] struct {
+	deduplicated uint64
+
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (v V, err error, shared bool) {
+
+	c, leader := g.join(key)
+	if !leader {
+		<-c.done
+		return c.val, c.err, true
+	}
+	defer g.finish(key, c)
+
+	c.val, c.err = fn()
+	return c.val, c.err, false
+}
+
+func (g *Group[K, V]) DoContext(ctx context.Context, key K, fn func() (V, error)) (v V, err error, shared bool) {
+
+	c, leader := g.join(key)
+	if leader {
+		go func() {
+			defer g.finish(key, c)
+			defer func() {
+				if r := recover(); r != nil {
+					c.err = fmt.Errorf("%w: %v", ErrPanicked, r)
+				}
+			}()
+			c.val, c.err = fn()
+		}()
+	}
+
+	select {
+	case <-c.done:
+		return c.val, c.err, !leader
+	case <-ctx.Done():
+		return v, ctx.Err(), !leader
+	}
+}
+
+// join возвращает выполняющийся запрос с ключом key или регистрирует новый (leader = true).
+func (g *Group[K, V]) join(key K) (c *call[V], leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	if c, found := g.calls[key]; found {
+		atomic.AddUint64(&g.deduplicated, 1)
+		return c, false
+	}
+	c = &call[V]{done: make(chan struct{}), err: ErrPanicked}
+	g.calls[key] = c
+	return c, true
+}
+
+func (g *Group[K, V]) finish(key K, c *call[V]) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(c.done)
+}
+
+func (g *Group[K, V]) Deduplicated() uint64 {
+	return atomic.LoadUint64(&g.deduplicated)
+}
+
+func (g *Group[K, V]) InFlight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.calls)
+}
@@ -0,0 +1,209 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+Тестирование объединения запросов:
+1) Одновременные вызовы с одним ключом выполняют функцию один раз и получают один результат;
+2) Разные ключи не объединяются, результат не запоминается после окончания запроса;
+3) Ошибка возвращается всем ожидающим вызовам;
+4) panic в функции не «подвешивает» ожидающие вызовы (они получают ErrPanicked);
+5) DoContext: вызов, контекст которого закончился, сразу получает ctx.Err(), а запрос продолжается и его результат
+получает вызов с действующим контекстом, – даже если закончился контекст первого вызова.
+*/
+
+func TestDoDeduplicates(t *testing.T) {
+	var g Group[string, int]
+	var calls int32
+	release := make(chan struct{})
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make(chan int, callers)
+	var shared int32
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, s := g.Do("a", func() (int, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return 42, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			if s {
+				atomic.AddInt32(&shared, 1)
+			}
+			results <- v
+		}()
+	}
+
+	waitFor(t, func() bool { return g.Deduplicated() == callers-1 })
+	if g.InFlight() != 1 {
+		t.Fatalf("InFlight = %d, want 1", g.InFlight())
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for v := range results {
+		if v != 42 {
+			t.Fatalf("result = %d, want 42", v)
+		}
+	}
+	if calls != 1 || shared != callers-1 {
+		t.Fatalf("calls = %d, shared = %d; want 1 and %d", calls, shared, callers-1)
+	}
+	if g.InFlight() != 0 {
+		t.Fatalf("InFlight = %d after Do, want 0", g.InFlight())
+	}
+}
+
+func TestDoKeysAndRepeat(t *testing.T) {
+	var g Group[string, string]
+	var calls int
+
+	for _, key := range []string{"a", "b", "a"} {
+		v, err, shared := g.Do(key, func() (string, error) {
+			calls++
+			return key, nil
+		})
+		if v != key || err != nil || shared {
+			t.Fatalf("Do(%q) = %q, %v, %v", key, v, err, shared)
+		}
+	}
+	if calls != 3 || g.Deduplicated() != 0 {
+		t.Fatalf("calls = %d, deduplicated = %d; want 3 and 0", calls, g.Deduplicated())
+	}
+}
+
+func TestDoError(t *testing.T) {
+	var g Group[int, int]
+	errLoad := errors.New("load failed")
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err, _ := g.Do(1, func() (int, error) {
+				<-release
+				return 0, errLoad
+			})
+			if !errors.Is(err, errLoad) {
+				t.Errorf("err = %v, want %v", err, errLoad)
+			}
+		}()
+	}
+	waitFor(t, func() bool { return g.Deduplicated() == 2 })
+	close(release)
+	wg.Wait()
+}
+
+func TestDoPanic(t *testing.T) {
+	var g Group[int, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		g.Do(1, func() (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	done := make(chan error)
+	go func() {
+		_, err, _ := g.Do(1, func() (int, error) { return 1, nil })
+		done <- err
+	}()
+	waitFor(t, func() bool { return g.Deduplicated() == 1 })
+	close(release)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrPanicked) {
+			t.Fatalf("err = %v, want %v", err, ErrPanicked)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting call hung after panic")
+	}
+}
+
+func TestDoContextCallerDeadline(t *testing.T) {
+	var g Group[string, int]
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err, _ := g.DoContext(leaderCtx, "a", fn)
+		leader <- err
+	}()
+	waitFor(t, func() bool { return g.InFlight() == 1 })
+
+	follower := make(chan int, 1)
+	go func() {
+		v, err, shared := g.DoContext(context.Background(), "a", fn)
+		if err != nil || !shared {
+			t.Errorf("DoContext = %d, %v, %v; want 42, nil, true", v, err, shared)
+		}
+		follower <- v
+	}()
+	waitFor(t, func() bool { return g.Deduplicated() == 1 })
+
+	cancelLeader()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Fatalf("leader err = %v, want %v", err, context.Canceled)
+	}
+	close(release)
+
+	select {
+	case v := <-follower:
+		if v != 42 || atomic.LoadInt32(&calls) != 1 {
+			t.Fatalf("follower = %d, calls = %d; want 42 and 1", v, calls)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("follower hung after the leader's context ended")
+	}
+	waitFor(t, func() bool { return g.InFlight() == 0 })
+}
+
+func TestDoContextPanic(t *testing.T) {
+	var g Group[int, int]
+	_, err, _ := g.DoContext(context.Background(), 1, func() (int, error) { panic("boom") })
+	if !errors.Is(err, ErrPanicked) {
+		t.Fatalf("err = %v, want %v", err, ErrPanicked)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,114 @@
+package workpool
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Пул обработчиков с ограниченной очередью. Используется микросервисом query: запросы обрабатываются одновременно
+несколькими горутинами, а медленный запрос в БД не задерживает остальные.
+
+1) Функция New принимает количество обработчиков (workers) и размер очереди (queue) и запускает обработчики.
+2) Функция TrySubmit ставит задачу в очередь и никогда не блокируется: если очередь заполнена или пул закрыт –
+возвращает ErrBusy или ErrClosed, и вызывающий код сразу отвечает отказом («занят»), а не ждёт.
+3) Функция Close перестаёт принимать задачи и ждёт окончания всех задач из очереди. Повторный вызов ничего не делает.
+4) Функция Stats – количество обработчиков, размер и заполненность очереди, выполненные и отклонённые задачи
+и задачи, которые выполняются сейчас.
+Если задача вызвала panic – пул не восстанавливает её: panic останавливает процесс, как и без пула.
+*/
+
+var (
+	ErrBusy   = errors.New("workpool: очередь заполнена")
+	ErrClosed = errors.New("workpool: пул закрыт")
+)
+
+type Stats struct {
+	Workers  int    `json:"workers"`
+	Capacity int    `json:"capacity"`
+	Queued   int    `json:"queued"`
+	Running  int64  `json:"running"`
+	Done     uint64 `json:"done"`
+	Rejected uint64 `json:"rejected"`
+}
+
+type Pool struct {
+	running  int64
+	done     uint64
+	rejected uint64
+
+	workers int
+	queue   chan func()
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func New(workers, queue int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	p := &Pool{
+		workers: workers,
+		queue:   make(chan func(), queue),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for task := range p.queue {
+		atomic.AddInt64(&p.running, 1)
+		task()
+		atomic.AddInt64(&p.running, -1)
+		atomic.AddUint64(&p.done, 1)
+	}
+}
+
+func (p *Pool) TrySubmit(task func()) error {
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		atomic.AddUint64(&p.rejected, 1)
+		return ErrClosed
+	}
+	select {
+	case p.queue <- task:
+		return nil
+	default:
+		atomic.AddUint64(&p.rejected, 1)
+		return ErrBusy
+	}
+}
+
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Workers:  p.workers,
+		Capacity: cap(p.queue),
+		Queued:   len(p.queue),
+		Running:  atomic.LoadInt64(&p.running),
+		Done:     atomic.LoadUint64(&p.done),
+		Rejected: atomic.LoadUint64(&p.rejected),
+	}
+}
@@ -0,0 +1,99 @@
+package workpool
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+Тестирование пула обработчиков:
+1) Задачи выполняются одновременно всеми обработчиками;
+2) При заполненной очереди TrySubmit сразу возвращает ErrBusy, а не блокируется;
+3) Close ждёт окончания задач из очереди, после Close задачи не принимаются (ErrClosed).
+*/
+
+func TestPoolRunsConcurrently(t *testing.T) {
+	const workers = 4
+	p := New(workers, workers)
+	defer p.Close()
+
+	started := make(chan struct{}, workers)
+	release := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		if err := p.TrySubmit(func() {
+			started <- struct{}{}
+			<-release
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < workers; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of %d tasks started concurrently", i, workers)
+		}
+	}
+	if stats := p.Stats(); stats.Running != workers || stats.Workers != workers {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	close(release)
+}
+
+func TestPoolBusy(t *testing.T) {
+	p := New(1, 2)
+	defer p.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	p.TrySubmit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	// Обработчик занят: две задачи помещаются в очередь, третья отклоняется сразу.
+	for i := 0; i < 2; i++ {
+		if err := p.TrySubmit(func() {}); err != nil {
+			t.Fatalf("task %d: %v", i, err)
+		}
+	}
+	start := time.Now()
+	if err := p.TrySubmit(func() {}); !errors.Is(err, ErrBusy) {
+		t.Fatalf("TrySubmit on full queue: err = %v, want ErrBusy", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("TrySubmit blocked for %s", elapsed)
+	}
+	if stats := p.Stats(); stats.Queued != 2 || stats.Capacity != 2 || stats.Rejected != 1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	close(release)
+}
+
+func TestPoolCloseWaits(t *testing.T) {
+	p := New(2, 10)
+
+	var done int32
+	for i := 0; i < 10; i++ {
+		if err := p.TrySubmit(func() {
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&done, 1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Close()
+	if done != 10 {
+		t.Fatalf("Close returned after %d of 10 tasks", done)
+	}
+	if err := p.TrySubmit(func() {}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("TrySubmit after Close: err = %v, want ErrClosed", err)
+	}
+	p.Close()
+	if stats := p.Stats(); stats.Done != 10 || stats.Rejected != 1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+}
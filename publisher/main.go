@@ -2,8 +2,11 @@ package main
 
 import (
 	"log"
+	"os"
 
 	nats "github.com/nats-io/nats.go"
+	"my.service.common/config"
+	"my.service.common/natsconn"
 	//"github.com/nats-io/stan.go"
 )
 
@@ -133,399 +136,444 @@ var o4 = `{"order_uid":"9last","entry":"WBIL","internal_signature":"8062ffbe87d2
 var p4 = `{"order_uid":"910last","entry":"WBIL","internal_signature":"8062ffbe87d2446dbc98b05a0e4e07bd53565c0b2fd90c6c6e6a96594de61433","payment":{"transaction":"afc4e94948fe433f8b55b68a0f169e56","currency":"USD","provider":"WbPay","amount":3107,"payment_dt":1614540555,"bank":"alpha","delivery_cost":0,"goods_total":3107},"items":[{"chrt_id":11241243,"price":300,"rid":"7ed1002bcbc14fa594d48b422ef6980d","name":"Сказки малышам. (библиотека детского сада).","sale":45,"size":"","total_price":164,"nm_id":2786861,"brand":"Умка"},{"chrt_id":29535331,"price":236,"rid":"d7a9cd9d6cc04b48b50e00b9f4cd4c9a","name":"Лопушок. Сказки для малышей","sale":28,"size":"","total_price":169,"nm_id":8771153,"brand":"Амрита"},{"chrt_id":34856620,"price":259,"rid":"758de71d0f764554839c53b4bb062f67","name":"Иван-крестьянский сын и Чудо-юдо","sale":45,"size":"","total_price":142,"nm_id":10881092,"brand":"Издательство Речь"},{"chrt_id":39310989,"price":316,"rid":"69657189a2864ffbb0acb7adeaaf4705","name":"ЛЕВ ТОЛСТОЙ. РАССКАЗЫ, СКАЗКИ И БЫЛИ  \"ДЮЖИНА\"","sale":41,"size":"","total_price":184,"nm_id":12759167,"brand":"Проф-Пресс"},{"chrt_id":39310990,"price":303,"rid":"4cdc75623c4c4e65969d2635f6f55598","name":"МОИ ПЕРВЫЕ СКАЗКИ","sale":48,"size":"","total_price":157,"nm_id":12759168,"brand":"Проф-Пресс"},{"chrt_id":39310991,"price":316,"rid":"4e09c06d863844bab9f0eec9844d5de8","name":"РУССКИЕ СКАЗКИ МАЛЫШАМ  \"ДЮЖИНА\"","sale":55,"size":"","total_price":142,"nm_id":12759169,"brand":"Проф-Пресс"},{"chrt_id":39819896,"price":314,"rid":"5787871dc18746ddbc97cbcb974fc470","name":"Записная книжка","sale":51,"size":"","total_price":152,"nm_id":12993881,"brand":"СИМА-ЛЕНД"},{"chrt_id":41096819,"price":886,"rid":"a71041ee3c98464293149b1b3882858e","name":"Ночная сорочка","sale":60,"size":"60","total_price":350,"nm_id":13587977,"brand":"Alena Alenkina"},{"chrt_id":42079863,"price":179,"rid":"521f177ea92e4df0a3b24a0d0a0990c3","name":"Федорино горе","sale":20,"size":"","total_price":142,"nm_id":14065528,"brand":"Школьная Книга"},{"chrt_id":42079877,"price":179,"rid":"e7fb76e9e13641dc96181b4421c0516f","name":"Бармалей","sale":20,"size":"","total_price":142,"nm_id":14065542,"brand":"Школьная Книга"},{"chrt_id":42079888,"price":179,"rid":"0f24b387352d441fa7bc3e3b028785d9","name":"Снегурочка","sale":20,"size":"","total_price":142,"nm_id":14065553,"brand":"Школьная Книга"},{"chrt_id":44683515,"price":179,"rid":"d12cd58fca9a46f593cebda3c2ed087b","name":"Лиса и журавль. Журавль и цапля. ИГРАЕМ В СКАЗКУ. ТЕАТРАЛИЗАЦИЯ СКАЗОК с игровыми полями","sale":20,"size":"","total_price":142,"nm_id":15346238,"brand":"Школьная Книга"},{"chrt_id":44996935,"price":236,"rid":"62abdf5eb55f4eafb0eeb02b813d8c7f","name":"Наушники","sale":30,"size":"","total_price":164,"nm_id":15503637,"brand":"Dream Tech"},{"chrt_id":45726498,"price":159,"rid":"dd7a0f9a64d547978829cbd471d0f267","name":"\"КОЛОБОК\" КНИЖКА-ГАРМОШКА","sale":36,"size":"","total_price":101,"nm_id":15889643,"brand":"Проф-Пресс"},{"chrt_id":47551424,"price":1886,"rid":"555c32eae827422bbb9bb377e1b2854b","name":"Тапочки","sale":56,"size":"43-44","total_price":100,"nm_id":16962802,"brand":"Naturella\u0026Home"}],"locale":"ru","customer_id":"5ea488619943420eaefcbcc402eb8ddc","track_number":"WBIL2817015795SL","delivery_service":"meest","shardkey":"2","sm_id":33}`
 var a4 = `{"order_uid":"011last","entry":"WBIL","internal_signature":"8062ffbe87d2446dbc98b05a0e4e07bd53565c0b2fd90c6c6e6a96594de61433","payment":{"transaction":"afc4e94948fe433f8b55b68a0f169e56","currency":"USD","provider":"WbPay","amount":3107,"payment_dt":1614540555,"bank":"alpha","delivery_cost":0,"goods_total":3107},"items":[{"chrt_id":11241243,"price":300,"rid":"7ed1002bcbc14fa594d48b422ef6980d","name":"Сказки малышам. (библиотека детского сада).","sale":45,"size":"","total_price":164,"nm_id":2786861,"brand":"Умка"},{"chrt_id":29535331,"price":236,"rid":"d7a9cd9d6cc04b48b50e00b9f4cd4c9a","name":"Лопушок. Сказки для малышей","sale":28,"size":"","total_price":169,"nm_id":8771153,"brand":"Амрита"},{"chrt_id":34856620,"price":259,"rid":"758de71d0f764554839c53b4bb062f67","name":"Иван-крестьянский сын и Чудо-юдо","sale":45,"size":"","total_price":142,"nm_id":10881092,"brand":"Издательство Речь"},{"chrt_id":39310989,"price":316,"rid":"69657189a2864ffbb0acb7adeaaf4705","name":"ЛЕВ ТОЛСТОЙ. РАССКАЗЫ, СКАЗКИ И БЫЛИ  \"ДЮЖИНА\"","sale":41,"size":"","total_price":184,"nm_id":12759167,"brand":"Проф-Пресс"},{"chrt_id":39310990,"price":303,"rid":"4cdc75623c4c4e65969d2635f6f55598","name":"МОИ ПЕРВЫЕ СКАЗКИ","sale":48,"size":"","total_price":157,"nm_id":12759168,"brand":"Проф-Пресс"},{"chrt_id":39310991,"price":316,"rid":"4e09c06d863844bab9f0eec9844d5de8","name":"РУССКИЕ СКАЗКИ МАЛЫШАМ  \"ДЮЖИНА\"","sale":55,"size":"","total_price":142,"nm_id":12759169,"brand":"Проф-Пресс"},{"chrt_id":39819896,"price":314,"rid":"5787871dc18746ddbc97cbcb974fc470","name":"Записная книжка","sale":51,"size":"","total_price":152,"nm_id":12993881,"brand":"СИМА-ЛЕНД"},{"chrt_id":41096819,"price":886,"rid":"a71041ee3c98464293149b1b3882858e","name":"Ночная сорочка","sale":60,"size":"60","total_price":350,"nm_id":13587977,"brand":"Alena Alenkina"},{"chrt_id":42079863,"price":179,"rid":"521f177ea92e4df0a3b24a0d0a0990c3","name":"Федорино горе","sale":20,"size":"","total_price":142,"nm_id":14065528,"brand":"Школьная Книга"},{"chrt_id":42079877,"price":179,"rid":"e7fb76e9e13641dc96181b4421c0516f","name":"Бармалей","sale":20,"size":"","total_price":142,"nm_id":14065542,"brand":"Школьная Книга"},{"chrt_id":42079888,"price":179,"rid":"0f24b387352d441fa7bc3e3b028785d9","name":"Снегурочка","sale":20,"size":"","total_price":142,"nm_id":14065553,"brand":"Школьная Книга"},{"chrt_id":44683515,"price":179,"rid":"d12cd58fca9a46f593cebda3c2ed087b","name":"Лиса и журавль. Журавль и цапля. ИГРАЕМ В СКАЗКУ. ТЕАТРАЛИЗАЦИЯ СКАЗОК с игровыми полями","sale":20,"size":"","total_price":142,"nm_id":15346238,"brand":"Школьная Книга"},{"chrt_id":44996935,"price":236,"rid":"62abdf5eb55f4eafb0eeb02b813d8c7f","name":"Наушники","sale":30,"size":"","total_price":164,"nm_id":15503637,"brand":"Dream Tech"},{"chrt_id":45726498,"price":159,"rid":"dd7a0f9a64d547978829cbd471d0f267","name":"\"КОЛОБОК\" КНИЖКА-ГАРМОШКА","sale":36,"size":"","total_price":101,"nm_id":15889643,"brand":"Проф-Пресс"},{"chrt_id":47551424,"price":1886,"rid":"555c32eae827422bbb9bb377e1b2854b","name":"Тапочки","sale":56,"size":"43-44","total_price":100,"nm_id":16962802,"brand":"Naturella\u0026Home"}],"locale":"ru","customer_id":"5ea488619943420eaefcbcc402eb8ddc","track_number":"WBIL2817015795SL","delivery_service":"meest","shardkey":"2","sm_id":33}`
 
-func main() {
+/*
+Структура Config – настройки публикатора тестовых заказов. Загружаются функцией config.MustLoad
+(пакет my.service.common/config): файл настроек (-config или CONFIG_FILE), переменные окружения и флаги.
+Ключи и значения по умолчанию адреса NATS JetStream (jetstream_url), subject заказов (order_subject)
+и потока (stream) – те же, что у микросервиса save, поэтому с одним файлом настроек заказы попадают в save.
+Заказы публикуются в JetStream (js.Publish): каждая публикация подтверждается потоком stream (nats.ExpectStream),
+а если потока нет (save ещё не запускался) или subject принадлежит другому потоку – программа завершается с ошибкой.
+Все заказы публикуются через одно соединение (пакет my.service.common/natsconn), которое закрывается через Drain.
+*/
+
+type Config struct {
+	NATSURL string `json:"jetstream_url" env:"JETSTREAM_URL" flag:"nats-url" usage:"Адрес сервера NATS JetStream" required:"true"`
+	Stream  string `json:"stream" env:"SAVE_STREAM" flag:"stream" usage:"Поток JetStream, который должен подтвердить публикацию" required:"true"`
+	Subject string `json:"order_subject" env:"ORDER_SUBJECT" flag:"subject" usage:"Subject, в который публикуются новые заказы" required:"true"`
+	Repeat  int    `json:"publisher_repeat" env:"PUBLISHER_REPEAT" flag:"repeat" usage:"Количество повторов набора тестовых заказов"`
+}
 
-	for i := 0; i < 150; i++ {
+func main() {
 
-		nc, err := nats.Connect("demo.nats.io")
-		if err != nil {
-			log.Fatal(err)
+	cfg := Config{
+		NATSURL: "wbx-world-nats-stage.dp.wb.ru",
+		Stream:  "ORDERS",
+		Subject: "go.test",
+		Repeat:  150,
+	}
+	config.MustLoad(&cfg, os.Stdout)
+
+	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime)
+
+	nc, err := natsconn.Connect(natsconn.Options{
+		URL:      cfg.NATSURL,
+		Name:     "publisher",
+		InfoLog:  infoLog,
+		ErrorLog: errorLog,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !nc.Healthy() {
+		log.Fatalf("Нет соединения с NATS %s", cfg.NATSURL)
+	}
+	defer func() {
+		if err := nc.Drain(); err != nil {
+			errorLog.Println(err)
 		}
+	}()
+
+	js, err := nc.JetStream()
+	if err != nil {
+		log.Fatal(err)
+	}
+	expect := nats.ExpectStream(cfg.Stream)
 
-		defer nc.Close()
+	for i := 0; i < cfg.Repeat; i++ {
 
-		if err := nc.Publish("newOrder", []byte(q)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(q), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte("NO")); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte("NO"), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(w)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(w), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(e)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(e), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(r)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(r), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(t)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(t), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(wrongid)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wrongid), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(y)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(y), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(u)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(u), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(wronge)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wronge), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(wrongsid)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wrongsid), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(m)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(m), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(o)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(o), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(p)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(p), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(a)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(a), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(wrongpaym)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wrongpaym), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(s)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(s), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(d)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(d), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(f)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(f), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(g)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(g), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(h)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(h), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(j)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(j), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(k)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(k), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(l)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(l), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(z)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(z), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(qq)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(qq), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(ww)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(ww), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(ee)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(ee), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(rr)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(rr), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(tt)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(tt), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(yy)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(yy), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(uu)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(uu), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(mm)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(mm), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(oo)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(oo), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(pp)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(pp), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(aa)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(aa), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(ss)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(ss), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(dd)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(dd), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(ff)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(ff), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(gg)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(gg), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(hh)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(hh), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(jj)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(jj), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(kk)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(kk), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(ll)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(ll), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(zz)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(zz), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(qqq)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(qqq), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(www)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(www), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(wrongitem)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wrongitem), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(eee)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(eee), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(rrr)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(rrr), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(ttt)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(ttt), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(yyy)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(yyy), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(uuu)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(uuu), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(mmm)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(mmm), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(ooo)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(ooo), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(ppp)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(ppp), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(aaa)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(aaa), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(qqqq)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(qqqq), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(wwww)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wwww), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(eeee)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(eeee), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(rrrr)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(rrrr), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(tttt)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(tttt), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(yyyy)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(yyyy), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(uuuu)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(uuuu), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(mmmm)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(mmmm), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(oooo)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(oooo), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(pppp)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(pppp), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(aaaa)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(aaaa), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(q1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(q1), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(w1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(w1), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(e1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(e1), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(r1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(r1), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(t1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(t1), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(y1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(y1), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(wrongloc)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wrongloc), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(u1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(u1), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(m1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(m1), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(o1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(o1), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(p1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(p1), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(a1)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(a1), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(wrongloccustid)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wrongloccustid), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(q2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(q2), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(w2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(w2), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(wrongtrsack)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wrongtrsack), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(e2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(e2), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(r2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(r2), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(t2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(t2), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(y2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(y2), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(u2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(u2), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(m2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(m2), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(o2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(o2), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(p2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(p2), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(a2)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(a2), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(q3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(q3), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(w3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(w3), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(e3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(e3), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(wrongdeliv)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wrongdeliv), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(r3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(r3), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(t3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(t3), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(y3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(y3), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(u3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(u3), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(m3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(m3), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(o3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(o3), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(p3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(p3), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(a3)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(a3), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(q4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(q4), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(w4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(w4), expect); err != nil {
 			log.Fatal(err)
 		}
 
-		if err := nc.Publish("newOrder", []byte(e4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(e4), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(r4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(r4), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(t4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(t4), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(y4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(y4), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(wrongshard)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(wrongshard), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(u4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(u4), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(m4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(m4), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(o4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(o4), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(p4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(p4), expect); err != nil {
 			log.Fatal(err)
 		}
-		if err := nc.Publish("newOrder", []byte(a4)); err != nil {
+		if _, err := js.Publish(cfg.Subject, []byte(a4), expect); err != nil {
 			log.Fatal(err)
 		}
 	}
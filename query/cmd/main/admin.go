@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+
+	"my.service.common/cacheadmin"
+)
+
+/*
+Функция startAdmin запускает администрирование кэшей (общий запуск – cacheadmin.Start): статистика, просмотр заказа
+по ID, удаление заказа или заказов по префиксу ID и очистка кэша. Кэши: orders (OrderCache) и, если включён,
+not_found (NotFoundCache). Настройки: admin_subject (NATS), admin_addr (HTTP) и admin_token.
+Возвращает функцию остановки HTTP-сервера (вызывается в main при остановке). Если администрирование выключено –
+функция остановки ничего не делает.
+*/
+
+func (app *application) startAdmin() (shutdown func(ctx context.Context) error, err error) {
+
+	caches := map[string]cacheadmin.Target{
+		"orders": cacheadmin.Wrap(app.orderGet.OrderCache),
+	}
+	if app.orderGet.NotFoundCache != nil {
+		caches["not_found"] = cacheadmin.Wrap(app.orderGet.NotFoundCache)
+	}
+
+	return cacheadmin.Start(app.nc.Conn, cacheadmin.Options{
+		Addr:     app.config.AdminAddr,
+		Subject:  app.config.AdminSubject,
+		Token:    app.config.AdminToken,
+		Caches:   caches,
+		InfoLog:  app.infoLog,
+		ErrorLog: app.errorLog,
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	nats "github.com/nats-io/nats.go"
+	"my.service.query/pkg/models"
+)
+
+/*
+Функция ServeOrderBatches подписывается на пакетные запросы заказов (subject из настройки BatchSubject)
+и возвращает подписку и ошибку (при наличии). Если subject не задан – не подписывается (возвращает nil, nil).
+Каждый полученный запрос обрабатывает функция replyBatch в пуле обработчиков (функция serve, файл workers.go)
+со сроком batch_timeout.
+
+Функция replyBatch принимает в качестве аргументов контекст запроса и сообщение-запрос.
+Процесс работы функции:
+1) Декодируем запрос типа models.BatchRequest (список ID + RequestID); нечитаемый запрос – ответ invalid_request
+без RequestID (функция replyInvalid, файл workers.go). Пустой список или больше BatchMaxIDs ID –
+статус invalid_request и причина в поле Error;
+2) Проверяем ID (функция batchIDs): повторяющиеся ID учитываются один раз, некорректные (models.ValidOrderUID –
+та же проверка, что и у запроса одного заказа) получают результат invalid_id и в БД не запрашиваются;
+3) Загружаем заказы (DbModel.GetOrders) с контекстом запроса: из кэша, остальные – одним запросом в БД;
+если срок истёк – статус unavailable;
+4) Формируем ответ типа models.BatchReply с тем же RequestID: результат по каждому ID в порядке запроса.
+При ошибке БД – логируем её, статус internal, а в поле Error – общее сообщение;
+5) Проверяем размер ответа: заказ с оплатой и товарами занимает от сотен байт до десятков килобайт, поэтому
+batch_max_ids не гарантирует, что ответ поместится в одно сообщение NATS (max_payload сервера, по умолчанию 1 МБ).
+Если ответ больше – отвечаем статусом invalid_request и оценкой, сколько ID помещается в ответ (функция payloadFit):
+иначе m.Respond завершится ошибкой, и show прождёт ответа до batch_timeout;
+6) Отправляем ответ в inbox, указанный в запросе (m.Respond).
+*/
+
+func (app *application) ServeOrderBatches() (*nats.Subscription, error) {
+	if app.config.BatchSubject == "" {
+		return nil, nil
+	}
+	return app.nc.Subscribe(app.config.BatchSubject, app.serve(app.config.BatchTimeout, app.replyBatch))
+}
+
+func (app *application) replyBatch(ctx context.Context, m *nats.Msg) {
+
+	var request models.BatchRequest
+	if err := json.Unmarshal(m.Data, &request); err != nil {
+		app.replyInvalid(m, err)
+		return
+	}
+
+	reply := models.BatchReply{Reply: models.Reply{RequestID: request.RequestID, Status: models.StatusOK}}
+
+	switch n := len(request.OrderUIDs); {
+	case n == 0:
+		reply.Fail(models.StatusInvalidRequest, "не задан ни один ID заказа")
+	case n > app.config.BatchMaxIDs:
+		reply.Fail(models.StatusInvalidRequest, fmt.Sprintf("слишком много ID заказов: %d, не больше %d в одном запросе", n, app.config.BatchMaxIDs))
+	default:
+		results, valid := batchIDs(request.OrderUIDs)
+		found, err := app.orderGet.GetOrders(ctx, valid)
+		switch {
+		case ctx.Err() != nil:
+			app.errorLog.Printf("Пакетный запрос %d заказов: срок запроса истёк (%s): %v", n, app.config.BatchTimeout, err)
+			reply.Fail(models.StatusUnavailable, "срок запроса истёк")
+		case err != nil:
+			app.errorLog.Printf("Пакетный запрос %d заказов: %v", n, err)
+			reply.Fail(models.StatusInternal, "заказы не загружены")
+		default:
+			reply.Results = mergeResults(results, found)
+		}
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		app.errorLog.Println(err)
+		return
+	}
+
+	if max := app.nc.MaxPayload(); max > 0 && int64(len(data)) > max {
+		fits := payloadFit(len(reply.Results), len(data), max)
+		app.errorLog.Printf("Пакетный запрос %d заказов: ответ %d байт больше max_payload %d", len(reply.Results), len(data), max)
+		reply.Results = nil
+		reply.Fail(models.StatusInvalidRequest, fmt.Sprintf("ответ не помещается в сообщение NATS (%d байт, максимум %d): запросите не больше %d ID", len(data), max, fits))
+		if data, err = json.Marshal(reply); err != nil {
+			app.errorLog.Println(err)
+			return
+		}
+	}
+
+	if err := m.Respond(data); err != nil {
+		app.errorLog.Println(err)
+	}
+}
+
+// batchIDs возвращает результаты по уникальным ID в порядке запроса (некорректные ID – со статусом BatchInvalidID,
+// остальные заполняет mergeResults) и список корректных ID для загрузки.
+func batchIDs(orderIds []string) (results []models.BatchResult, valid []string) {
+	seen := make(map[string]bool, len(orderIds))
+	for _, id := range orderIds {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result := models.BatchResult{OrderUID: id}
+		if models.ValidOrderUID(id) {
+			valid = append(valid, id)
+		} else {
+			result.Status = models.BatchInvalidID
+		}
+		results = append(results, result)
+	}
+	return results, valid
+}
+
+// mergeResults переносит результаты загрузки корректных ID в результаты batchIDs.
+func mergeResults(results, found []models.BatchResult) []models.BatchResult {
+	byID := make(map[string]models.BatchResult, len(found))
+	for _, r := range found {
+		byID[r.OrderUID] = r
+	}
+	for i, r := range results {
+		if r.Status == "" {
+			results[i] = byID[r.OrderUID]
+		}
+	}
+	return results
+}
+
+// payloadFit – сколько ID помещается в ответ размером не больше max байт при среднем размере результата
+// size / ids (с запасом 10% – размер заказов различается).
+func payloadFit(ids, size int, max int64) int64 {
+	if ids == 0 || size == 0 {
+		return 0
+	}
+	return int64(ids) * max / int64(size) * 9 / 10
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"my.service.common/cache"
+	"my.service.common/natsconn"
+	"my.service.common/peercache"
+	"my.service.query/pkg/models"
+	"my.service.query/pkg/models/postgresql"
+)
+
+/*
+Структура Config – настройки микросервиса «query». Загружаются функцией config.MustLoad (пакет my.service.common/config)
+в порядке: значения по умолчанию (функция defaultConfig), файл настроек (-config или CONFIG_FILE),
+переменные окружения, флаги командной строки.
+Функция natsOptions возвращает параметры общего соединения с NATS (пакет my.service.common/natsconn).
+Функция cacheOptions возвращает параметры кэша заказов (пакет my.service.common/cache): срок хранения,
+ограничения по количеству заказов и памяти, политику вытеснения, количество сегментов (cache_shards),
+файл снимка кэша (ошибки записи – в errorLog). Ограничение по памяти должно быть меньше лимита памяти пода
+(queryspec.yaml) с запасом на остальную часть процесса.
+Функция notFoundCacheOptions возвращает параметры кэша ненайденных заказов (cache_not_found_ttl, cache_not_found_max_entries).
+Функция peerName возвращает имя экземпляра в группе распределённого кэша (peer_name, по умолчанию – имя хоста без точек).
+
+Функция Validate проверяет значения, которые нельзя проверить по типу: пул обработчиков и срок запроса, время запроса поиска, параметры пакетных запросов, время хранения записей в кэше,
+ограничения, политику вытеснения и сегменты кэша, кэш ненайденных заказов, режим обработки order.changed,
+режим и параметры прогрева кэша (файл warmup.go), токен администрирования кэшей (файл admin.go),
+режим и параметры распределённого кэша (файл peers.go).
+*/
+
+const (
+	orderChangedEvict   = "evict"
+	orderChangedRefresh = "refresh"
+)
+
+const (
+	peerModeOff    = "off"
+	peerModeStatic = "static"
+	peerModeNATS   = "nats"
+)
+
+type Config struct {
+	DSN                   string        `json:"dsn" env:"DSN" flag:"dsn" usage:"Название источника данных" required:"true" secret:"true"`
+	NATSURL               string        `json:"nats_url" env:"NATS_URL" flag:"nats-url" usage:"Адрес сервера NATS для запросов микросервиса «show»" required:"true"`
+	OrderSubject          string        `json:"order_request_subject" env:"ORDER_REQUEST_SUBJECT" flag:"subject" usage:"Subject запросов заказа по ID" required:"true"`
+	Workers               int           `json:"workers" env:"QUERY_WORKERS" flag:"workers" usage:"Количество одновременно обрабатываемых запросов микросервиса «show»"`
+	QueueSize             int           `json:"queue_size" env:"QUERY_QUEUE_SIZE" flag:"queue-size" usage:"Размер очереди запросов; при заполненной очереди запрос сразу получает ответ со статусом unavailable"`
+	RequestDeadline       time.Duration `json:"request_deadline" env:"QUERY_REQUEST_DEADLINE" flag:"request-deadline" usage:"Срок обработки запроса заказа по ID от получения, включая ожидание в очереди (меньше request_timeout микросервиса «show»)"`
+	SearchSubject         string        `json:"order_search_subject" env:"ORDER_SEARCH_SUBJECT" flag:"search-subject" usage:"Subject запросов поиска заказов (пусто – поиск выключен)"`
+	SearchTimeout         time.Duration `json:"search_timeout" env:"QUERY_SEARCH_TIMEOUT" flag:"search-timeout" usage:"Максимальное время запроса поиска заказов к БД"`
+	BatchSubject          string        `json:"order_batch_subject" env:"ORDER_BATCH_SUBJECT" flag:"batch-subject" usage:"Subject пакетных запросов заказов по списку ID (пусто – выключены)"`
+	BatchMaxIDs           int           `json:"batch_max_ids" env:"QUERY_BATCH_MAX_IDS" flag:"batch-max-ids" usage:"Максимальное количество ID в пакетном запросе (ответ к тому же должен поместиться в max_payload NATS)"`
+	BatchTimeout          time.Duration `json:"batch_timeout" env:"QUERY_BATCH_TIMEOUT" flag:"batch-timeout" usage:"Максимальное время пакетного запроса заказов к БД"`
+	OrderChangedSubject   string        `json:"order_changed_subject" env:"ORDER_CHANGED_SUBJECT" flag:"changed-subject" usage:"Subject событий микросервиса «save» о сохранённых заказах (пусто – не подписываться)"`
+	OrderChangedMode      string        `json:"order_changed_mode" env:"QUERY_ORDER_CHANGED_MODE" flag:"changed-mode" usage:"Что делать с заказом в кэше при событии order.changed: evict – удалить, refresh – загрузить новую версию"`
+	CacheTTL              time.Duration `json:"cache_ttl" env:"QUERY_CACHE_TTL" flag:"cache-ttl" usage:"Время хранения заказа в кэше"`
+	CacheCleanup          time.Duration `json:"cache_cleanup" env:"QUERY_CACHE_CLEANUP" flag:"cache-cleanup" usage:"Интервал удаления устаревших записей из кэша"`
+	CacheMaxEntries       int           `json:"cache_max_entries" env:"QUERY_CACHE_MAX_ENTRIES" flag:"cache-max-entries" usage:"Максимальное количество заказов в кэше (0 – без ограничения)"`
+	CacheMaxBytes         int64         `json:"cache_max_bytes" env:"QUERY_CACHE_MAX_BYTES" flag:"cache-max-bytes" usage:"Примерный объём памяти под заказы в кэше, байт (0 – без ограничения)"`
+	CachePolicy           string        `json:"cache_policy" env:"QUERY_CACHE_POLICY" flag:"cache-policy" usage:"Политика вытеснения из кэша: lru или lfu"`
+	CacheShards           int           `json:"cache_shards" env:"QUERY_CACHE_SHARDS" flag:"cache-shards" usage:"Количество сегментов кэша со своей блокировкой: больше – меньше ожидания при одновременных запросах"`
+	CacheSnapshot         string        `json:"cache_snapshot" env:"QUERY_CACHE_SNAPSHOT" flag:"cache-snapshot" usage:"Файл снимка кэша: восстанавливается при запуске, записывается периодически и при остановке (пусто – без снимков)"`
+	CacheSnapshotInterval time.Duration `json:"cache_snapshot_interval" env:"QUERY_CACHE_SNAPSHOT_INTERVAL" flag:"cache-snapshot-interval" usage:"Интервал записи снимка кэша (0 – только при остановке)"`
+	NotFoundTTL           time.Duration `json:"cache_not_found_ttl" env:"QUERY_CACHE_NOT_FOUND_TTL" flag:"cache-not-found-ttl" usage:"Время хранения ID ненайденного заказа в кэше (0 – не кэшировать)"`
+	NotFoundMaxEntries    int           `json:"cache_not_found_max_entries" env:"QUERY_CACHE_NOT_FOUND_MAX_ENTRIES" flag:"cache-not-found-max-entries" usage:"Максимальное количество ID ненайденных заказов в кэше (0 – без ограничения)"`
+	WarmUp                string        `json:"cache_warmup" env:"QUERY_CACHE_WARMUP" flag:"cache-warmup" usage:"Прогрев кэша при запуске: off – нет, recent – последние созданные заказы, requested – самые запрашиваемые"`
+	WarmUpLimit           int           `json:"cache_warmup_limit" env:"QUERY_CACHE_WARMUP_LIMIT" flag:"cache-warmup-limit" usage:"Сколько заказов загрузить в кэш при прогреве"`
+	WarmUpBatch           int           `json:"cache_warmup_batch" env:"QUERY_CACHE_WARMUP_BATCH" flag:"cache-warmup-batch" usage:"Сколько заказов загружать одним запросом к БД при прогреве"`
+	WarmUpTimeout         time.Duration `json:"cache_warmup_timeout" env:"QUERY_CACHE_WARMUP_TIMEOUT" flag:"cache-warmup-timeout" usage:"Максимальное время прогрева кэша"`
+	ReadyFile             string        `json:"ready_file" env:"QUERY_READY_FILE" flag:"ready-file" usage:"Файл, который создаётся после прогрева кэша, когда микросервис готов к запросам (readinessProbe)"`
+	AdminAddr             string        `json:"admin_addr" env:"QUERY_ADMIN_ADDR" flag:"admin-addr" usage:"Адрес HTTP-сервера администрирования кэшей (пусто – выключен)"`
+	AdminSubject          string        `json:"admin_subject" env:"QUERY_ADMIN_SUBJECT" flag:"admin-subject" usage:"Subject запросов NATS администрирования кэшей (пусто – выключен)"`
+	AdminToken            string        `json:"admin_token" env:"ADMIN_TOKEN" flag:"admin-token" usage:"Токен доступа к администрированию кэшей" secret:"true"`
+	PeerMode              string        `json:"peer_mode" env:"QUERY_PEER_MODE" flag:"peer-mode" usage:"Распределённый кэш между экземплярами: off – нет, static – список peers, nats – обнаружение через NATS"`
+	Peers                 []string      `json:"peers" env:"QUERY_PEERS" flag:"peers" usage:"Имена экземпляров группы через запятую (peer_mode static)"`
+	PeerName              string        `json:"peer_name" env:"QUERY_PEER_NAME" flag:"peer-name" usage:"Имя этого экземпляра в группе (пусто – имя хоста, точки заменяются на -)"`
+	PeerSubject           string        `json:"peer_subject" env:"QUERY_PEER_SUBJECT" flag:"peer-subject" usage:"Префикс subject запросов между экземплярами и их объявлений"`
+	PeerTimeout           time.Duration `json:"peer_timeout" env:"QUERY_PEER_TIMEOUT" flag:"peer-timeout" usage:"Максимальное время запроса заказа у экземпляра-владельца (после – загрузка из БД)"`
+	PeerAnnounce          time.Duration `json:"peer_announce" env:"QUERY_PEER_ANNOUNCE" flag:"peer-announce" usage:"Интервал объявлений экземпляра (peer_mode nats); без объявлений 3 интервала – экземпляр исключается"`
+	ReconnectWait         time.Duration `json:"nats_reconnect_wait" env:"NATS_RECONNECT_WAIT" flag:"reconnect-wait" usage:"Пауза между попытками переподключения к NATS"`
+	ReconnectBuf          int           `json:"nats_reconnect_buf" env:"NATS_RECONNECT_BUF" flag:"reconnect-buf" usage:"Размер буфера публикаций (байт) на время переподключения к NATS"`
+	ShutdownTimeout       time.Duration `json:"shutdown_timeout" env:"QUERY_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"Максимальное время остановки по сигналу SIGTERM (0 – без ограничения)"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		DSN:                   "user=postgres password=postgres dbname=test sslmode=disable",
+		NATSURL:               "demo.nats.io",
+		OrderSubject:          "IDSend",
+		Workers:               16,
+		QueueSize:             256,
+		RequestDeadline:       4 * time.Second,
+		SearchSubject:         "OrderSearch",
+		SearchTimeout:         3 * time.Second,
+		BatchSubject:          "OrderBatch",
+		BatchMaxIDs:           200,
+		BatchTimeout:          10 * time.Second,
+		OrderChangedSubject:   "order.changed",
+		OrderChangedMode:      orderChangedRefresh,
+		CacheTTL:              5 * time.Minute,
+		CacheCleanup:          10 * time.Minute,
+		CacheMaxEntries:       100000,
+		CacheMaxBytes:         64 * 1024 * 1024,
+		CachePolicy:           string(cache.PolicyLRU),
+		CacheShards:           16,
+		CacheSnapshotInterval: 30 * time.Second,
+		NotFoundTTL:           30 * time.Second,
+		NotFoundMaxEntries:    10000,
+		WarmUp:                warmUpOff,
+		WarmUpLimit:           10000,
+		WarmUpBatch:           500,
+		WarmUpTimeout:         30 * time.Second,
+		PeerMode:              peerModeOff,
+		PeerSubject:           "query.peers",
+		PeerTimeout:           peercache.DefaultTimeout,
+		PeerAnnounce:          2 * time.Second,
+		ReconnectWait:         2 * time.Second,
+		ReconnectBuf:          8 * 1024 * 1024,
+		ShutdownTimeout:       20 * time.Second,
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.CacheTTL <= 0 || c.CacheCleanup <= 0 {
+		return fmt.Errorf("cache_ttl и cache_cleanup должны быть больше 0")
+	}
+	if c.CacheMaxEntries < 0 || c.CacheMaxBytes < 0 || c.CacheSnapshotInterval < 0 {
+		return fmt.Errorf("cache_max_entries, cache_max_bytes и cache_snapshot_interval не могут быть отрицательными")
+	}
+	if c.Workers < 1 || c.QueueSize < 0 || c.RequestDeadline <= 0 {
+		return fmt.Errorf("workers и request_deadline должны быть больше 0, queue_size не может быть отрицательным")
+	}
+	if c.SearchTimeout <= 0 || c.BatchTimeout <= 0 || c.BatchMaxIDs < 1 {
+		return fmt.Errorf("search_timeout, batch_timeout и batch_max_ids должны быть больше 0")
+	}
+	if c.CacheShards < 1 {
+		return fmt.Errorf("cache_shards должен быть больше 0")
+	}
+	if c.NotFoundTTL < 0 || c.NotFoundMaxEntries < 0 {
+		return fmt.Errorf("cache_not_found_ttl и cache_not_found_max_entries не могут быть отрицательными")
+	}
+	if _, err := cache.ParsePolicy(c.CachePolicy); err != nil {
+		return err
+	}
+	if c.OrderChangedMode != orderChangedEvict && c.OrderChangedMode != orderChangedRefresh {
+		return fmt.Errorf("неизвестный режим order_changed_mode: %s (evict или refresh)", c.OrderChangedMode)
+	}
+	if (c.AdminAddr != "" || c.AdminSubject != "") && c.AdminToken == "" {
+		return fmt.Errorf("для admin_addr и admin_subject нужен admin_token")
+	}
+	switch c.PeerMode {
+	case peerModeOff:
+	case peerModeStatic, peerModeNATS:
+		if c.PeerSubject == "" || c.PeerTimeout <= 0 || c.PeerAnnounce <= 0 {
+			return fmt.Errorf("для peer_mode %s нужны peer_subject, peer_timeout и peer_announce больше 0", c.PeerMode)
+		}
+		if err := peercache.ValidName(c.peerName()); err != nil {
+			return err
+		}
+		for _, peer := range c.Peers {
+			if err := peercache.ValidName(peer); err != nil {
+				return err
+			}
+		}
+		if c.PeerMode == peerModeStatic && len(c.Peers) == 0 {
+			return fmt.Errorf("для peer_mode static нужен список peers")
+		}
+	default:
+		return fmt.Errorf("неизвестный режим peer_mode: %s (off, static или nats)", c.PeerMode)
+	}
+	switch c.WarmUp {
+	case warmUpOff:
+	case postgresql.WarmUpRecent, postgresql.WarmUpRequested:
+		if c.WarmUpLimit < 1 || c.WarmUpBatch < 1 || c.WarmUpTimeout <= 0 {
+			return fmt.Errorf("cache_warmup_limit, cache_warmup_batch и cache_warmup_timeout должны быть больше 0")
+		}
+	default:
+		return fmt.Errorf("неизвестный режим cache_warmup: %s (off, recent или requested)", c.WarmUp)
+	}
+	return nil
+}
+
+func (c *Config) cacheOptions(errorLog *log.Logger) cache.Options[string, models.OrderPost] {
+	return cache.Options[string, models.OrderPost]{
+		DefaultTTL:       c.CacheTTL,
+		CleanupInterval:  c.CacheCleanup,
+		MaxEntries:       c.CacheMaxEntries,
+		MaxBytes:         c.CacheMaxBytes,
+		Policy:           cache.Policy(c.CachePolicy),
+		Shards:           c.CacheShards,
+		SnapshotPath:     c.CacheSnapshot,
+		SnapshotInterval: c.CacheSnapshotInterval,
+		OnSnapshot: func(entries int, err error) {
+			if err != nil {
+				errorLog.Printf("Снимок кэша %s не записан: %v", c.CacheSnapshot, err)
+			}
+		},
+	}
+}
+
+// notFoundCacheOptions – параметры кэша ненайденных заказов. Срок хранения короткий, поэтому «сборщик» мусора
+// запускается с тем же интервалом, а количество ID ограничено: перебор ID не должен занимать всю память.
+func (c *Config) notFoundCacheOptions() cache.Options[string, struct{}] {
+	return cache.Options[string, struct{}]{
+		DefaultTTL:      c.NotFoundTTL,
+		CleanupInterval: c.NotFoundTTL,
+		MaxEntries:      c.NotFoundMaxEntries,
+	}
+}
+
+func (c *Config) peerName() string {
+	if c.PeerName != "" {
+		return c.PeerName
+	}
+	hostname, _ := os.Hostname()
+	return strings.ReplaceAll(hostname, ".", "-")
+}
+
+func (c *Config) natsOptions(infoLog, errorLog *log.Logger) natsconn.Options {
+	hostname, _ := os.Hostname()
+	return natsconn.Options{
+		URL:              c.NATSURL,
+		Name:             "query" + "-" + hostname,
+		ReconnectWait:    c.ReconnectWait,
+		ReconnectBufSize: c.ReconnectBuf,
+		DrainTimeout:     c.ShutdownTimeout,
+		InfoLog:          infoLog,
+		ErrorLog:         errorLog,
+	}
+}
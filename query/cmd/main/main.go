@@ -1,84 +1,174 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"flag"
 	"log"
 	"os"
-	"sync"
 
 	_ "github.com/lib/pq"
-	"my.service.query/pkg/models"
+	nats "github.com/nats-io/nats.go"
+	"my.service.common/cache"
+	"my.service.common/config"
+	"my.service.common/lifecycle"
+	"my.service.common/natsconn"
+	"my.service.common/workpool"
 	"my.service.query/pkg/models/postgresql"
 )
 
 /*
 Основная часть программы:
 1) Структура Application – основная структура программы, управляющая информацией о работе программы и ошибках
-+ управляет функциями добавления данных в БД.
++ управляет функциями добавления данных в БД. Поле config – настройки микросервиса (структура Config, файл config.go), поле nc – общее долгоживущее соединение с NATS
+(переподключается автоматически, состояние – nc.Health()), поле pool – пул обработчиков запросов (файл workers.go).
 2) Функция OpenDB – управляет подключением к БД. В качестве параметра принимает свойства для подключения к БД
 и возвращает готовое, проверенное соединение с БД.
-3) Каналы ChanForID и ChanForResult - для общения функций и хранения ID и модели соответственно;
-4) Указатель на область памяти типа string (ID) - для хранения ID заказа, который ищет пользователь.
-5) В функции main:
-	5.1) Устанавливаем счётчик WaitGroup;
-	5.2) Задаём параметры для информировании о работе приложения и об ошибках в нём (infoLog, errorLog);
-	5.3) Получаем получение к БД, создавая объект структуры OpenDB;
-	5.4) Получаем функциональность приложения в части возможности вызова функций для добавления информации в БД,
-	создавая объект структуры  Application.
-	5.5) Запускаем функцию getSearchedID и записываем результат её работы в канал (а это ID заказа, введённый
-	пользователем) + присваиваем полученное значение указателю ID.
-	5.6) С помощью ID, запускаем функцию GetOriginOrder и получаем нужный заказ;
-	5.7) Публикуем этот заказ с помощью функции PubishOrder.
-	В качестве параметра передаём созданный с помощью конструктора кэш.
+3) Пул обработчиков (workers, queue_size): запросы микросервиса «show» обрабатываются одновременно,
+при заполненной очереди – сразу получают ответ со статусом unavailable;
+4) В функции main:
+	4.1) Загружаем настройки: файл настроек, переменные окружения и флаги;
+	4.2) Задаём параметры для информировании о работе приложения и об ошибках в нём (infoLog, errorLog);
+	4.3) Получаем получение к БД, создавая объект структуры OpenDB;
+	4.4) Получаем функциональность приложения в части возможности вызова функций для добавления информации в БД,
+	создавая объект структуры  Application (вместе с кэшем найденных заказов и, если cache_not_found_ttl > 0,
+	кэшем ненайденных заказов).
+	4.5) Соединяемся с NATS (natsconn.Connect), восстанавливаем кэш из снимка (функция restoreCache) и, если включено,
+	прогреваем кэш заказами из БД (функция warmUpCache, файл warmup.go) – не дольше cache_warmup_timeout.
+	Если включено (peer_mode) – объединяем кэши экземпляров в распределённый кэш (функция startPeers, файл peers.go);
+	4.6) Подписываемся на события микросервиса «save» о сохранённых заказах (функция ServeOrderChanges)
+	и запускаем функцию ServeOrderRequests: каждый запрос микросервиса «show» обрабатывается отдельно и получает
+	ответ в собственный inbox; запросы поиска заказов обрабатывает функция ServeOrderSearch (файл searchOrders.go),
+	пакетные запросы по списку ID – функция ServeOrderBatches (файл batchOrders.go).
+	Если включено – запускаем администрирование кэшей (функция startAdmin, файл admin.go).
+	Только после этого отмечаем готовность (lifecycle.MarkReady, файл ready_file).
+	4.7) При получении SIGINT/SIGTERM (lifecycle.SignalContext) снимаем готовность, останавливаем HTTP-сервер
+	администрирования, сообщаем остальным экземплярам о выходе из группы, останавливаем подписки на запросы
+	(natsconn.DrainSubscriptions: новые запросы не принимаются, полученные попадают в очередь), ждём обработки очереди
+	(pool.Close) и только затем закрываем соединение с NATS через Drain – ответы отправляются до закрытия.
+	После этого записываем снимок и очищаем кэш, закрываем соединение с БД. Если остановка заняла больше ShutdownTimeout –
+	процесс завершается с ошибкой (lifecycle.Watchdog).
 */
 
 type application struct {
 	errorLog *log.Logger
 	infoLog  *log.Logger
 	orderGet *postgresql.DbModel
+	config   Config
+	nc       *natsconn.Conn
+	pool     *workpool.Pool
 }
 
-var Wg sync.WaitGroup
-
-var ChanForID = make(chan string, 1000)
-
-var ChanForResult = make(chan models.OrderPost, 1000)
-
-var ID = new(string)
-
 func main() {
-	Wg.Add(2)
 
-	dsn := flag.String("dsn", "user=postgres password=postgres dbname=test sslmode=disable", "Название источника данных")
+	cfg := defaultConfig()
+	config.MustLoad(&cfg, os.Stdout)
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	db, err := OpenDB(*dsn)
+	db, err := OpenDB(cfg.DSN)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
-	defer db.Close()
 
 	app := &application{
 		errorLog: errorLog,
 		infoLog:  infoLog,
-		orderGet: &postgresql.DbModel{DB: db},
+		orderGet: &postgresql.DbModel{
+			DB:          db,
+			OrderCache:  cache.NewWithOptions(cfg.cacheOptions(errorLog)),
+			CacheTTL:    cfg.CacheTTL,
+			LoadTimeout: cfg.RequestDeadline,
+		},
+		config: cfg,
+		pool:   workpool.New(cfg.Workers, cfg.QueueSize),
+	}
+	if cfg.NotFoundTTL > 0 {
+		app.orderGet.NotFoundCache = cache.NewWithOptions(cfg.notFoundCacheOptions())
+		app.orderGet.NotFoundTTL = cfg.NotFoundTTL
 	}
 
 	infoLog.Printf("Запуск приложения. Выдача сведений о заказе при запросе с помощью ID.")
 
-	for {
+	nc, err := natsconn.Connect(cfg.natsOptions(infoLog, errorLog))
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	app.nc = nc
+
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
+	lifecycle.Watchdog(ctx, cfg.ShutdownTimeout, func() {
+		errorLog.Fatalf("Остановка не завершена за %s", cfg.ShutdownTimeout)
+	})
+
+	app.restoreCache()
+	app.warmUpCache(ctx)
 
-		asked := <-app.getSearchedID(ChanForID)
-		*ID = asked
+	stopPeers, err := app.startPeers()
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	if _, err := app.ServeOrderChanges(); err != nil {
+		errorLog.Fatal(err)
+	}
+	var requestSubs []*nats.Subscription
+	for _, serve := range []func() (*nats.Subscription, error){app.ServeOrderRequests, app.ServeOrderSearch, app.ServeOrderBatches} {
+		sub, err := serve()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		requestSubs = append(requestSubs, sub)
+	}
+	shutdownAdmin, err := app.startAdmin()
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	if err := lifecycle.MarkReady(cfg.ReadyFile); err != nil {
+		errorLog.Println(err)
+	}
+	infoLog.Println("Приложение готово к обработке запросов.")
 
-		searchedOrder := <-app.orderGet.GetOriginOrder(ID, ChanForResult)
-		app.PubishOrder(searchedOrder)
+	<-ctx.Done()
+	stop()
+	if err := lifecycle.MarkNotReady(cfg.ReadyFile); err != nil {
+		errorLog.Println(err)
+	}
+	infoLog.Println("Остановка: новые запросы не принимаются, ответы на полученные запросы отправляются.")
 
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownAdmin(shutdownCtx); err != nil {
+		errorLog.Println(err)
+	}
+	stopPeers()
+	if err := natsconn.DrainSubscriptions(shutdownCtx, requestSubs...); err != nil {
+		errorLog.Println(err)
+	}
+	app.pool.Close()
+	if err := nc.Drain(); err != nil {
+		errorLog.Println(err)
 	}
 
+	stats := app.orderGet.OrderCache.Stats()
+	infoLog.Printf("Кэш: попаданий – %d, промахов – %d, вытеснено – %d (срок хранения – %d, количество – %d, память – %d)",
+		stats.Hits, stats.Misses, stats.Evictions(), stats.EvictedExpired, stats.EvictedEntries, stats.EvictedBytes)
+	infoLog.Printf("Одновременных запросов одного заказа объединено – %d", app.orderGet.Deduplicated())
+	ws := app.pool.Stats()
+	infoLog.Printf("Обработчики запросов (%d, очередь %d): обработано – %d, отклонено (busy) – %d", ws.Workers, ws.Capacity, ws.Done, ws.Rejected)
+	if app.orderGet.Peers != nil {
+		ps := app.orderGet.Peers.Stats()
+		infoLog.Printf("Распределённый кэш: загружено локально – %d, получено от владельцев – %d, ошибок запросов – %d, обслужено запросов экземпляров – %d",
+			ps.Local, ps.Remote, ps.RemoteErrors, ps.Served)
+	}
+	infoLog.Printf("Остановка: из кэша удалено заказов – %d", app.orderGet.OrderCache.Close())
+	if app.orderGet.NotFoundCache != nil {
+		app.orderGet.NotFoundCache.Close()
+	}
+	if err := db.Close(); err != nil {
+		errorLog.Println(err)
+	}
+	infoLog.Println("Приложение остановлено.")
 }
 
 func OpenDB(dsn string) (*sql.DB, error) {
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+
+	"my.service.common/peercache"
+	"my.service.query/pkg/models"
+	"my.service.query/pkg/models/postgresql"
+)
+
+/*
+Функция startPeers включает распределённый кэш заказов между экземплярами микросервиса (пакет my.service.common/peercache).
+Каждый ID заказа принадлежит одному экземпляру (согласованное хеширование): промах по чужому ID сначала запрашивается
+у владельца через NATS (<peer_subject>.get.<peer_name>) и только если владелец недоступен или не ответил за peer_timeout –
+загружается из БД. Заказ хранится в кэше владельца, поэтому при N экземплярах он загружается из БД один раз, а не N раз.
+1) Создаёт группу (поле orderGet.Peers) с загрузкой DbModel.LoadOrder и подписывается на запросы других экземпляров
+(загрузка по запросу другого экземпляра – не дольше request_deadline). Запросы других экземпляров выполняются пулом
+обработчиков (app.pool.TrySubmit), как и запросы show: если очередь заполнена, владелец сразу отвечает ошибкой,
+и запросивший экземпляр загружает заказ из БД сам;
+2) peer_mode static – состав группы из настроек (peers), nats – обнаружение экземпляров через NATS (peercache.Discover):
+экземпляры объявляют себя в <peer_subject>.announce, изменения состава пишутся в infoLog;
+3) Ошибки запросов к владельцу пишутся в errorLog.
+Возвращает функцию остановки (вызывается в main до Drain): остальные экземпляры сразу перестают отправлять запросы этому.
+Если распределённый кэш выключен – функция остановки ничего не делает.
+*/
+
+func (app *application) startPeers() (stop func(), err error) {
+
+	stop = func() {}
+	cfg := app.config
+	if cfg.PeerMode == peerModeOff {
+		return stop, nil
+	}
+
+	self := cfg.peerName()
+	transport := peercache.NewNATSTransport(app.nc.Conn, cfg.PeerSubject)
+	group, err := peercache.New(peercache.Options[models.OrderPost]{
+		Self:         self,
+		Transport:    transport,
+		Load:         app.orderGet.LoadOrder,
+		NotFound:     postgresql.ErrOrderNotFound,
+		Timeout:      cfg.PeerTimeout,
+		ServeTimeout: cfg.RequestDeadline,
+		OnPeerError: func(peer, key string, err error) {
+			app.errorLog.Printf("Заказ %s не получен от экземпляра %s, загрузка из БД: %v", key, peer, err)
+		},
+	})
+	if err != nil {
+		return stop, err
+	}
+	if _, err := transport.Listen(self, group, app.pool.TrySubmit); err != nil {
+		return stop, err
+	}
+	app.orderGet.Peers = group
+
+	switch cfg.PeerMode {
+	case peerModeStatic:
+		group.SetPeers(cfg.Peers...)
+	case peerModeNATS:
+		discovery, err := peercache.Discover(app.nc.Conn, cfg.PeerSubject, self, cfg.PeerAnnounce, func(peers []string) {
+			group.SetPeers(peers...)
+			app.infoLog.Printf("Распределённый кэш: экземпляры %s", strings.Join(peers, ", "))
+		})
+		if err != nil {
+			return stop, err
+		}
+		stop = discovery.Stop
+	}
+	app.infoLog.Printf("Распределённый кэш: экземпляр %s, экземпляры %s", self, strings.Join(group.Peers(), ", "))
+	return stop, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	nats "github.com/nats-io/nats.go"
+	"my.service.query/pkg/models"
+	"my.service.query/pkg/models/postgresql"
+)
+
+/*
+Функция ServeOrderRequests использует общее соединение с NATS (поле nc структуры application).
+Подписывается на запросы микросервиса «show» (subject из настройки OrderSubject) и возвращает подписку и ошибку (при наличии).
+Каждый полученный запрос обрабатывает функция replyOrder в пуле обработчиков (функция serve, файл workers.go)
+со сроком request_deadline.
+
+Функция replyOrder принимает в качестве аргументов контекст запроса и сообщение-запрос.
+Процесс работы функции:
+1) Декодируем запрос типа models.OrderRequest (ID заказа + RequestID); нечитаемый запрос – ответ invalid_request
+без RequestID (функция replyInvalid, файл workers.go);
+2) Проверяем ID (models.ValidOrderUID): некорректный ID – статус invalid_id, в БД запрос не отправляется;
+3) С помощью ID, запускаем функцию GetOriginOrder с контекстом запроса и получаем нужный заказ;
+4) Формируем ответ типа models.OrderReply с тем же RequestID и статусом (файл pkg/models/reply.go):
+ok – заказ найден, not_found – заказа нет (postgresql.ErrOrderNotFound), unavailable – истёк срок запроса,
+internal – ошибка БД (логируем её, а в поле Error – общее сообщение, без подробностей о БД);
+5) Отправляем ответ в inbox, указанный в запросе (m.Respond) – ответ получит только тот, кто спрашивал.
+Ответ отправляется всегда, поэтому show не ждёт ответа до таймаута.
+
+Использование NATS вместо NATS streaming обусловлено наличием связи между микросервисами (show и query)
+в формате «запрос – ответ» и выдачей только 1-го результата на каждый запрос.
+Подписка создаётся один раз: после переподключения к NATS она восстанавливается автоматически.
+*/
+
+func (app *application) ServeOrderRequests() (*nats.Subscription, error) {
+	return app.nc.Subscribe(app.config.OrderSubject, app.serve(app.config.RequestDeadline, app.replyOrder))
+}
+
+func (app *application) replyOrder(ctx context.Context, m *nats.Msg) {
+
+	var request models.OrderRequest
+	if err := json.Unmarshal(m.Data, &request); err != nil {
+		app.replyInvalid(m, err)
+		return
+	}
+
+	reply := models.OrderReply{Reply: models.Reply{RequestID: request.RequestID, Status: models.StatusOK}}
+
+	if !models.ValidOrderUID(request.OrderUID) {
+		reply.Fail(models.StatusInvalidID, "некорректный ID заказа")
+	} else {
+		order, err := app.orderGet.GetOriginOrder(ctx, request.OrderUID)
+		switch {
+		case errors.Is(err, postgresql.ErrOrderNotFound):
+			reply.Fail(models.StatusNotFound, "заказ не найден")
+		case ctx.Err() != nil:
+			app.errorLog.Printf("Заказ %s: срок запроса истёк (%s): %v", request.OrderUID, app.config.RequestDeadline, err)
+			reply.Fail(models.StatusUnavailable, "срок запроса истёк")
+		case err != nil:
+			app.errorLog.Printf("Заказ %s: %v", request.OrderUID, err)
+			reply.Fail(models.StatusInternal, "заказ не загружен")
+		default:
+			reply.Order = &order
+		}
+	}
+
+	app.respond(m, reply)
+}
+
+/*
+Функция ServeOrderChanges подписывается на события микросервиса «save» о сохранённых заказах
+(subject из настройки OrderChangedSubject) и возвращает подписку и ошибку (при наличии).
+Если subject не задан – не подписывается (возвращает nil, nil).
+Функция orderChanged принимает в качестве аргумента событие типа models.OrderChanged и обновляет заказ
+(DbModel.RefreshOrder, не дольше request_deadline): удаляет ID из кэша ненайденных заказов, пересчитывает строку order_post
+и удаляет заказ из кэша или сразу загружает в кэш новую версию (настройка OrderChangedMode: evict или refresh).
+Каждый экземпляр query получает все события (подписка без очереди), поэтому обновляет свой кэш;
+пересчёт order_post повторяется каждым экземпляром, но даёт один и тот же результат.
+События доставляются, только если save и query подключены к одному серверу NATS (jetstream_url и nats_url).
+*/
+
+func (app *application) ServeOrderChanges() (*nats.Subscription, error) {
+	if app.config.OrderChangedSubject == "" {
+		return nil, nil
+	}
+	return app.nc.Subscribe(app.config.OrderChangedSubject, app.orderChanged)
+}
+
+func (app *application) orderChanged(m *nats.Msg) {
+
+	var event models.OrderChanged
+	if err := json.Unmarshal(m.Data, &event); err != nil {
+		app.errorLog.Println(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.RequestDeadline)
+	defer cancel()
+
+	cached, err := app.orderGet.RefreshOrder(ctx, event.OrderUID, app.config.OrderChangedMode == orderChangedRefresh)
+	if err != nil && !errors.Is(err, postgresql.ErrOrderNotFound) {
+		app.errorLog.Printf("Заказ %s (версия %d) не обновлён: %v", event.OrderUID, event.Version, err)
+		return
+	}
+	if cached {
+		app.infoLog.Printf("Заказ %s изменён (%s, версия %d): кэш обновлён (%s)", event.OrderUID, event.Outcome, event.Version, app.config.OrderChangedMode)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	nats "github.com/nats-io/nats.go"
+	"my.service.query/pkg/models"
+)
+
+/*
+Функция ServeOrderSearch подписывается на запросы поиска заказов микросервиса «show» (subject из настройки SearchSubject)
+и возвращает подписку и ошибку (при наличии). Если subject не задан – не подписывается (возвращает nil, nil).
+Каждый полученный запрос обрабатывает функция replySearch в пуле обработчиков (функция serve, файл workers.go)
+со сроком search_timeout.
+
+Функция replySearch принимает в качестве аргументов контекст запроса и сообщение-запрос.
+Процесс работы функции:
+1) Декодируем запрос типа models.SearchRequest (фильтры, сортировка, размер страницы, курсор + RequestID);
+нечитаемый запрос – ответ invalid_request без RequestID (функция replyInvalid, файл workers.go);
+2) Ищем заказы (DbModel.SearchOrders) с контекстом запроса; если срок истёк – статус unavailable;
+3) Формируем ответ типа models.SearchReply с тем же RequestID: найденные заказы и курсор следующей страницы.
+Некорректный запрос (models.ErrInvalidSearch) – статус invalid_request и в поле Error причина; при ошибке БД –
+логируем её, статус internal, а в поле Error – общее сообщение, без подробностей о БД;
+4) Отправляем ответ в inbox, указанный в запросе (m.Respond).
+*/
+
+func (app *application) ServeOrderSearch() (*nats.Subscription, error) {
+	if app.config.SearchSubject == "" {
+		return nil, nil
+	}
+	return app.nc.Subscribe(app.config.SearchSubject, app.serve(app.config.SearchTimeout, app.replySearch))
+}
+
+func (app *application) replySearch(ctx context.Context, m *nats.Msg) {
+
+	var request models.SearchRequest
+	if err := json.Unmarshal(m.Data, &request); err != nil {
+		app.replyInvalid(m, err)
+		return
+	}
+
+	reply := models.SearchReply{Reply: models.Reply{RequestID: request.RequestID, Status: models.StatusOK}}
+
+	orders, next, err := app.orderGet.SearchOrders(ctx, request)
+	switch {
+	case errors.Is(err, models.ErrInvalidSearch):
+		reply.Fail(models.StatusInvalidRequest, err.Error())
+	case ctx.Err() != nil:
+		app.errorLog.Printf("Поиск заказов: срок запроса истёк (%s): %v", app.config.SearchTimeout, err)
+		reply.Fail(models.StatusUnavailable, "срок запроса истёк")
+	case err != nil:
+		app.errorLog.Printf("Поиск заказов: %v", err)
+		reply.Fail(models.StatusInternal, "поиск не выполнен")
+	default:
+		reply.Orders, reply.NextCursor = orders, next
+	}
+
+	app.respond(m, reply)
+}
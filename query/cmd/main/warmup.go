@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"my.service.query/pkg/models"
+)
+
+/*
+Функция warmUpCache вызывается при запуске до подписки на запросы микросервиса «show» (функция ServeOrderRequests).
+Принимает в качестве аргумента контекст (отменяется по SIGINT/SIGTERM). Процесс работы функции:
+1) Если прогрев выключен (cache_warmup = off) – сразу возвращает управление;
+2) Ограничивает количество заказов размером кэша (cache_max_entries): лишние заказы всё равно были бы вытеснены;
+3) Загружает заказы из БД пакетами (функция DbModel.WarmUp) не дольше cache_warmup_timeout
+и после каждого пакета логирует, сколько заказов загружено и сколько прошло времени;
+4) Логирует итог: прогрев завершён, прерван по времени (загруженные заказы остаются в кэше) или завершился ошибкой.
+Ошибка прогрева не останавливает микросервис: заказы, которых нет в кэше, ищутся в БД.
+Готовность (файл ready_file) main отмечает только после возврата из функции.
+
+Функция restoreCache вызывается перед warmUpCache, если задан файл снимка кэша (cache_snapshot): восстанавливает
+заказы, срок хранения которых не истек (LoadFile). Повреждённый снимок не загружается, ошибка не останавливает микросервис.
+События order.changed, полученные во время остановки, пропущены, поэтому заказ из снимка может быть устаревшим
+не дольше cache_ttl; прогрев после восстановления перезаписывает заказы из снимка версиями из БД.
+Заказы из снимка, записанного до появления полных сведений о заказе (без поля items), удаляются из кэша:
+они загрузятся из БД вместе с оплатой и товарами при первом запросе.
+*/
+
+const warmUpOff = "off"
+
+func (app *application) warmUpCache(ctx context.Context) {
+
+	cfg := app.config
+	if cfg.WarmUp == warmUpOff {
+		return
+	}
+
+	limit := cfg.WarmUpLimit
+	if cfg.CacheMaxEntries > 0 && limit > cfg.CacheMaxEntries {
+		limit = cfg.CacheMaxEntries
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.WarmUpTimeout)
+	defer cancel()
+
+	start := time.Now()
+	app.infoLog.Printf("Прогрев кэша: загрузка до %d заказов (%s), не дольше %s", limit, cfg.WarmUp, cfg.WarmUpTimeout)
+
+	loaded, err := app.orderGet.WarmUp(ctx, cfg.WarmUp, limit, cfg.WarmUpBatch, func(loaded int) {
+		app.infoLog.Printf("Прогрев кэша: загружено %d из %d (%s)", loaded, limit, time.Since(start).Round(time.Millisecond))
+	})
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		app.errorLog.Printf("Прогрев кэша прерван по времени (%s): загружено заказов – %d", cfg.WarmUpTimeout, loaded)
+	case errors.Is(err, context.Canceled):
+		app.infoLog.Printf("Прогрев кэша прерван остановкой: загружено заказов – %d", loaded)
+	case err != nil:
+		app.errorLog.Printf("Прогрев кэша: %v (загружено заказов – %d)", err, loaded)
+	default:
+		app.infoLog.Printf("Прогрев кэша завершён: загружено заказов – %d за %s, в кэше – %d", loaded, elapsed, app.orderGet.OrderCache.Len())
+	}
+}
+
+func (app *application) restoreCache() {
+
+	path := app.config.CacheSnapshot
+	if path == "" {
+		return
+	}
+	restored, expired, err := app.orderGet.OrderCache.LoadFile(path)
+	if err != nil {
+		app.errorLog.Printf("Снимок кэша %s не восстановлен: %v", path, err)
+		return
+	}
+	var partial []string
+	app.orderGet.OrderCache.Range(func(id string, order models.OrderPost) bool {
+		if order.Items == nil {
+			partial = append(partial, id)
+		}
+		return true
+	})
+	for _, id := range partial {
+		app.orderGet.OrderCache.Delete(id)
+	}
+	app.infoLog.Printf("Снимок кэша %s: восстановлено заказов – %d, пропущено устаревших – %d, без товаров – %d",
+		path, restored-len(partial), expired, len(partial))
+}
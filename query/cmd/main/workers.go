@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"my.service.query/pkg/models"
+)
+
+/*
+Одновременная обработка запросов микросервиса «show» (заказ по ID, поиск, пакетный запрос) пулом обработчиков
+(пакет my.service.common/workpool, поле pool структуры application): workers обработчиков и очередь на queue_size
+запросов. Подписка NATS вызывает свой обработчик последовательно, поэтому без пула медленный запрос в БД
+задерживал все остальные.
+
+1) Функция serve принимает срок обработки запроса (timeout) и функцию обработки handle и возвращает обработчик
+подписки NATS. Обработчик подписки только ставит запрос в очередь и никогда не ждёт:
+	1.1) Срок запроса (контекст с timeout) отсчитывается от получения сообщения, поэтому время в очереди входит в него.
+	Контекст передаётся в handle и дальше – во все запросы к БД (и к другим экземплярам query);
+	1.2) Если очередь заполнена (или пул закрыт при остановке) – сразу отвечаем «занят» (функция replyBusy);
+	1.3) Если срок запроса истёк, пока он ждал в очереди, – тоже отвечаем «занят»: show ещё может ждать ответа
+	(request_timeout больше request_deadline), а запрос в БД уже не успеет выполниться.
+2) Функция replyBusy отвечает конвертом ответа (models.Reply) со статусом unavailable: конверт встроен во все ответы
+query (OrderReply, SearchReply, BatchReply), а RequestID берётся из запроса, поэтому show сопоставит ответ с запросом.
+3) Функция replyInvalid отвечает на нечитаемый запрос (не JSON): статус invalid_request и ошибка разбора. RequestID
+взять неоткуда, поэтому он пустой – show считает такой ответ ответом на свой запрос (400), а не ответом на чужой.
+Без ответа show ждал бы его до request_timeout. Функция respond кодирует и отправляет ответ.
+*/
+
+func (app *application) serve(timeout time.Duration, handle func(ctx context.Context, m *nats.Msg)) nats.MsgHandler {
+	return func(m *nats.Msg) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+		err := app.pool.TrySubmit(func() {
+			defer cancel()
+			if ctx.Err() != nil {
+				app.replyBusy(m)
+				return
+			}
+			handle(ctx, m)
+		})
+		if err != nil {
+			cancel()
+			app.replyBusy(m)
+		}
+	}
+}
+
+func (app *application) replyBusy(m *nats.Msg) {
+
+	var request models.Reply
+	if err := json.Unmarshal(m.Data, &request); err != nil {
+		app.replyInvalid(m, err)
+		return
+	}
+	app.respond(m, models.Reply{RequestID: request.RequestID, Status: models.StatusUnavailable, Error: "микросервис query перегружен"})
+}
+
+func (app *application) replyInvalid(m *nats.Msg, err error) {
+	app.errorLog.Printf("Нечитаемый запрос на %s: %v", m.Subject, err)
+	app.respond(m, models.Reply{Status: models.StatusInvalidRequest, Error: fmt.Sprintf("неверный запрос: %v", err)})
+}
+
+func (app *application) respond(m *nats.Msg, reply interface{}) {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		app.errorLog.Println(err)
+		return
+	}
+	if err := m.Respond(data); err != nil {
+		app.errorLog.Println(err)
+	}
+}
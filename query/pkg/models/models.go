@@ -1,17 +1,92 @@
 package models
 
 /*
-Модель данных:
-OrderPost – структура, инкапсулирующая сведения о заказе для выдачи по запросу пользователя.
+Модели данных:
+1) OrderPost – структура, инкапсулирующая сведения о заказе для выдачи по запросу пользователя.
 ВАЖНО: благодаря внедрению ключевого параметра OrderUID в дальнейшем возможно идентифицировать заказ,
 набор товаров из него, а так же способ и порядок оплаты. Это упрощает идентификацию данных, ускоряет их обработку.
+Полные сведения о заказе: оплата (Payment – строка таблицы payment), товары (Items – строки таблицы items)
+и вычисляемые итоги: ItemsCount – количество товаров, ItemsTotal – стоимость товаров с учётом скидок (сумма total_price),
+TotalPrice – стоимость товаров и доставки (Payment.DeliveryCost).
+2) OrderRequest – запрос заказа от микросервиса «show»: ID заказа и уникальный идентификатор запроса (RequestID).
+3) OrderReply – ответ микросервису «show»: конверт ответа (Reply, файл reply.go – RequestID исходного запроса,
+статус и сообщение об ошибке) и найденный заказ. Order заполнен, только если Status = StatusOK.
+4) OrderChanged – событие микросервиса «save» о сохранённом или изменённом заказе (ID, версия и результат сохранения).
+5) BatchRequest – пакетный запрос заказов по списку ID (сверки), BatchReply – ответ: результат по каждому ID
+(BatchResult) в порядке запроса, повторяющиеся ID – один раз. Status – BatchFound (Order заполнен),
+BatchNotFound или BatchInvalidID (ID не прошёл проверку ValidOrderUID, в БД не запрашивался). Если запрос не выполнен целиком – статус конверта StatusInvalidRequest (пустой список,
+слишком много ID), StatusUnavailable или StatusInternal (ошибка БД), а Results пустой.
 */
 
+const (
+	BatchFound     = "found"
+	BatchNotFound  = "not_found"
+	BatchInvalidID = "invalid_id"
+)
+
 type OrderPost struct {
-	OrderUID        string `json:"order_uid"`
-	Entry           string `json:"entry"`
-	TotalPrice      int    `json:"total_price"`
-	CustomerID      string `json:"customer_id"`
-	TrackNumber     string `json:"track_number"`
-	DeliveryService string `json:"delivery_service"`
+	OrderUID        string  `json:"order_uid"`
+	Entry           string  `json:"entry"`
+	TotalPrice      int     `json:"total_price"`
+	CustomerID      string  `json:"customer_id"`
+	TrackNumber     string  `json:"track_number"`
+	DeliveryService string  `json:"delivery_service"`
+	Payment         Payment `json:"payment"`
+	Items           []Item  `json:"items"`
+	ItemsCount      int     `json:"items_count"`
+	ItemsTotal      int     `json:"items_total"`
+}
+
+type Payment struct {
+	Transaction  string `json:"transaction"`
+	Currency     string `json:"currency"`
+	Provider     string `json:"provider"`
+	Amount       int    `json:"amount"`
+	PaymentDt    int    `json:"payment_dt"`
+	Bank         string `json:"bank"`
+	DeliveryCost int    `json:"delivery_cost"`
+}
+
+type Item struct {
+	ChrtID     int    `json:"chrt_id"`
+	Price      int    `json:"price"`
+	Rid        string `json:"rid"`
+	Name       string `json:"name"`
+	Sale       int    `json:"sale"`
+	Size       string `json:"size"`
+	TotalPrice int    `json:"total_price"`
+	NmID       int    `json:"nm_id"`
+	Brand      string `json:"brand"`
+}
+
+type OrderRequest struct {
+	RequestID string `json:"request_id"`
+	OrderUID  string `json:"order_uid"`
+}
+
+type OrderReply struct {
+	Reply
+	Order *OrderPost `json:"order,omitempty"`
+}
+
+type OrderChanged struct {
+	OrderUID string `json:"order_uid"`
+	Version  int    `json:"version"`
+	Outcome  string `json:"outcome"`
+}
+
+type BatchRequest struct {
+	RequestID string   `json:"request_id"`
+	OrderUIDs []string `json:"order_uids"`
+}
+
+type BatchResult struct {
+	OrderUID string     `json:"order_uid"`
+	Status   string     `json:"status"`
+	Order    *OrderPost `json:"order,omitempty"`
+}
+
+type BatchReply struct {
+	Reply
+	Results []BatchResult `json:"results"`
 }
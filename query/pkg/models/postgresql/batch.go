@@ -0,0 +1,98 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+	"my.service.query/pkg/models"
+)
+
+/*
+Функция GetOrders – пакетный запрос заказов по списку ID (сверки запрашивают тысячи заказов, и запрос по одному ID
+через NATS на каждый заказ слишком медленный). Принимает в качестве аргументов контекст и список ID, возвращает
+результат по каждому ID в порядке списка (повторяющиеся ID – один раз) и ошибку. Порядок работы функции:
+1) Выдаём заказы из кэша заказов, ID из кэша ненайденных заказов – сразу BatchNotFound;
+2) Остальные ID загружаем одним запросом (хранимая процедура selectordersbyids, WHERE order_uid = ANY($1))
+и дополняем оплатой и товарами (loadDetails, файл details.go);
+3) Найденные заказы записываем в кэш на время CacheTTL, ненайденные ID – в кэш ненайденных заказов на время NotFoundTTL.
+Если задано поле Peers – в кэш записываются только ID, которыми владеет этот экземпляр (IsLocal): пакетный запрос
+не обращается к другим экземплярам, но и не заполняет кэш чужими заказами.
+В отличие от GetOrderByID, строки order_post не создаются и количество запросов (requested_count) не меняется.
+Ошибка БД – ошибка всего пакета.
+*/
+
+func (m *DbModel) GetOrders(ctx context.Context, orderIds []string) ([]models.BatchResult, error) {
+
+	results := make([]models.BatchResult, 0, len(orderIds))
+	position := make(map[string]int, len(orderIds))
+	var misses []string
+
+	for _, id := range orderIds {
+		if _, seen := position[id]; seen {
+			continue
+		}
+		position[id] = len(results)
+		result := models.BatchResult{OrderUID: id, Status: models.BatchNotFound}
+
+		if m.OrderCache != nil {
+			if order, ok := m.OrderCache.Get(id); ok {
+				result.Status, result.Order = models.BatchFound, &order
+			}
+		}
+		if result.Order == nil && !m.knownNotFound(id) {
+			misses = append(misses, id)
+		}
+		results = append(results, result)
+	}
+	if len(misses) == 0 {
+		return results, nil
+	}
+
+	query := "SELECT order_uid, entry, total_price, customer_id, track_number, delivery_service FROM selectordersbyids ($1)"
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(misses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.OrderPost
+	for rows.Next() {
+		var order models.OrderPost
+		err := rows.Scan(&order.OrderUID, &order.Entry, &order.TotalPrice, &order.CustomerID, &order.TrackNumber, &order.DeliveryService)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := m.loadDetails(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(orders))
+	for i := range orders {
+		order := orders[i]
+		found[order.OrderUID] = true
+		results[position[order.OrderUID]].Status = models.BatchFound
+		results[position[order.OrderUID]].Order = &order
+		if m.OrderCache != nil && m.owns(order.OrderUID) {
+			m.OrderCache.Set(order.OrderUID, order, m.CacheTTL)
+		}
+	}
+	for _, id := range misses {
+		if !found[id] && m.NotFoundCache != nil && m.owns(id) {
+			m.NotFoundCache.Set(id, struct{}{}, m.NotFoundTTL)
+		}
+	}
+	return results, nil
+}
+
+// owns – заказ хранится в кэше этого экземпляра: распределённый кэш выключен или этот экземпляр – владелец ID.
+func (m *DbModel) owns(orderId string) bool {
+	return m.Peers == nil || m.Peers.IsLocal(orderId)
+}
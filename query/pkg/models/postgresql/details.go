@@ -0,0 +1,87 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+	"my.service.query/pkg/models"
+)
+
+/*
+Полные сведения о заказе: оплата (таблица payment), товары (таблица items) и вычисляемые итоги.
+Загружаются вместе с заказом – в GetOrderByID, RefreshOrder и при прогреве кэша, – и хранятся в кэше целиком,
+поэтому повторный запрос заказа не обращается к БД.
+
+1) Функция loadDetails принимает в качестве аргументов контекст и срез заказов (строки order_post) и дополняет
+каждый заказ оплатой и товарами двумя запросами на все заказы сразу (хранимые процедуры selectorderpayments
+и selectorderitems, массив ID – pq.Array): пакет прогрева из сотен заказов – два запроса, а не сотни.
+2) Функция sumItems считает итоги по товарам: количество (ItemsCount) и стоимость с учётом скидок (ItemsTotal).
+Итоговая стоимость заказа (TotalPrice) считается в БД (insertintoorderpost) и не пересчитывается.
+*/
+
+func (m *DbModel) loadDetails(ctx context.Context, orders []models.OrderPost) error {
+
+	if len(orders) == 0 {
+		return nil
+	}
+	ids := make([]string, len(orders))
+	index := make(map[string]int, len(orders))
+	for i, order := range orders {
+		ids[i] = order.OrderUID
+		index[order.OrderUID] = i
+		orders[i].Items = []models.Item{}
+	}
+
+	payments, err := m.DB.QueryContext(ctx, "SELECT order_uid, transaction, currency, provider, amount, payment_dt, bank, deliverycost FROM selectorderpayments ($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer payments.Close()
+
+	for payments.Next() {
+		var orderId string
+		var p models.Payment
+		if err := payments.Scan(&orderId, &p.Transaction, &p.Currency, &p.Provider, &p.Amount, &p.PaymentDt, &p.Bank, &p.DeliveryCost); err != nil {
+			return err
+		}
+		if i, ok := index[orderId]; ok {
+			orders[i].Payment = p
+		}
+	}
+	if err := payments.Err(); err != nil {
+		return err
+	}
+
+	items, err := m.DB.QueryContext(ctx, "SELECT order_uid, chrt_id, price, rid, name, sale, size, total_price, nmid, brand FROM selectorderitems ($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer items.Close()
+
+	for items.Next() {
+		var orderId string
+		var it models.Item
+		if err := items.Scan(&orderId, &it.ChrtID, &it.Price, &it.Rid, &it.Name, &it.Sale, &it.Size, &it.TotalPrice, &it.NmID, &it.Brand); err != nil {
+			return err
+		}
+		if i, ok := index[orderId]; ok {
+			orders[i].Items = append(orders[i].Items, it)
+		}
+	}
+	if err := items.Err(); err != nil {
+		return err
+	}
+
+	for i := range orders {
+		sumItems(&orders[i])
+	}
+	return nil
+}
+
+func sumItems(order *models.OrderPost) {
+	order.ItemsCount = len(order.Items)
+	order.ItemsTotal = 0
+	for _, it := range order.Items {
+		order.ItemsTotal += it.TotalPrice
+	}
+}
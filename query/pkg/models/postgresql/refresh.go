@@ -0,0 +1,71 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"my.service.query/pkg/models"
+)
+
+/*
+Обновление заказа по событию order.changed микросервиса save (заказ сохранён повторно или исправлен).
+Без него query выдаёт прежние итоговую стоимость и трек-номер, пока не истечёт CacheTTL, а строка order_post
+вообще не пересчитывается.
+
+1) Функция RefreshOrder принимает в качестве аргументов контекст (срок обработки события), ID заказа и признак reload. Порядок работы функции:
+	1.1) Удаляет ID из кэша ненайденных заказов (ForgetNotFound);
+	1.2) Пересчитывает строку order_post хранимой процедурой refreshorderpost (если строки нет – ничего не делает);
+	1.3) Удаляет заказ из OrderCache. Если заказ был в кэше и reload = true – сразу загружает новую версию
+	из order_post (selectOrderPost) и записывает её в кэш; если строку order_post удалил save при сохранении новой
+	версии – создаёт её заново (GetOrderByID). Если reload = false – новая версия загрузится при следующем запросе.
+Возвращает признак «заказ был в кэше» и ошибку (при наличии). Запрос, начатый до события, может записать в кэш
+прежнюю версию – она хранится не дольше CacheTTL.
+2) Функция selectOrderPost возвращает строку order_post без изменения количества запросов (requested_count)
+вместе с оплатой и товарами (loadDetails).
+*/
+
+func (m *DbModel) RefreshOrder(ctx context.Context, orderId string, reload bool) (cached bool, err error) {
+
+	m.ForgetNotFound(orderId)
+
+	if _, err := m.DB.ExecContext(ctx, "SELECT refreshorderpost ($1)", orderId); err != nil {
+		return false, err
+	}
+
+	if m.OrderCache == nil {
+		return false, nil
+	}
+	cached = m.OrderCache.Delete(orderId)
+	if !cached || !reload {
+		return cached, nil
+	}
+
+	order, err := m.selectOrderPost(ctx, orderId)
+	if errors.Is(err, ErrOrderNotFound) {
+		_, err = m.GetOrderByID(ctx, orderId)
+		return cached, err
+	}
+	if err != nil {
+		return cached, err
+	}
+	m.OrderCache.Set(order.OrderUID, order, m.CacheTTL)
+	return cached, nil
+}
+
+func (m *DbModel) selectOrderPost(ctx context.Context, orderId string) (result models.OrderPost, err error) {
+
+	query := "SELECT order_uid, entry, total_price, customer_id, track_number, delivery_service FROM order_post WHERE order_uid = $1"
+
+	err = m.DB.QueryRowContext(ctx, query, orderId).Scan(&result.OrderUID, &result.Entry, &result.TotalPrice, &result.CustomerID, &result.TrackNumber, &result.DeliveryService)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result, ErrOrderNotFound
+	}
+	if err != nil {
+		return result, err
+	}
+
+	orders := []models.OrderPost{result}
+	err = m.loadDetails(ctx, orders)
+	return orders[0], err
+}
@@ -1,105 +1,240 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"sync"
 	"time"
 
+	"github.com/lib/pq"
+	"my.service.common/cache"
+	"my.service.common/peercache"
+	"my.service.common/singleflight"
 	"my.service.query/pkg/models"
-	"my.service.query/pkg/models/postgresql/cache"
 )
 
 /*
-Структура DbModel - управляет доступом к БД.
+Структура DbModel - управляет доступом к БД. Поля OrderCache и CacheTTL – кэш найденных заказов
+и время хранения в нём (задаются в настройках микросервиса). Если OrderCache не задан – заказы всегда ищутся в БД.
+Поля NotFoundCache и NotFoundTTL – отдельный кэш ID, по которым заказ не найден, с коротким сроком хранения:
+повторные запросы несуществующего ID (опечатки, перебор ID) не обращаются к БД. Если NotFoundCache не задан –
+такие запросы всегда ищутся в БД.
 
 Индивидуальные функции, использующиеся для добавления данных в каждую таблицу SQL.
 
-1) Функция GetOrderByID принимает в качестве аргумента ID заказа,
-указанное пользователем для поиска.
+1) Функция GetOrderByID принимает в качестве аргументов контекст (срок запроса – все запросы в БД выполняются
+с ним и прерываются по его истечении) и ID заказа, указанное пользователем для поиска.
 В результате генерирует структуру из БД для последующей выдачи её по HTTP. Для этого:
 	1.1) Делает запрос в БД для формирования новой строки в таблицу с хранящимися в ней результатами запроса,
 	осуществляя поиск по ID заказа;
 	1.2) Если запись уже есть в БД – выдаёт существующую;
-	1.3) Записывает полученные данные в кэш.
-Таблица: order_post, модель: OrderPost.
+	1.3) Дополняет заказ оплатой, товарами и итогами по товарам (loadDetails, файл details.go);
+	1.4) Записывает полученные данные в кэш на время CacheTTL.
+Если заказа нет в БД – возвращает ошибку ErrOrderNotFound (хранимая процедура при этом завершается ошибкой
+ограничения, которая не считается ошибкой запроса – функция orderPostSkipped), в кэш ничего не записывает.
+Любая другая ошибка insertintoorderpost или чтения строки (соединение, срок запроса) возвращается как есть:
+сбой БД не выдаётся за ненайденный заказ и не попадает в кэш ненайденных заказов.
+Таблицы: order_post, payment, items, модель: OrderPost.
 
 2) Функция GetOriginOrder
 принимает в качестве аргументов:
-	2.1.1) Указатель на строку (ID заказа, указанное пользователем);
-	2.1.2) Канал типа models.OrderPost для записи в него результата;
-По окончании работы, возвращает канал с нужной структурой типа models.OrderPost
-для последующей выдачи его данных по http. Порядок работы функции:
-	2.2.1) Используя аргумент из п. 2.1.1 – получаем данные из кэша;
-	2.2.2) В случае возникновения ошибки, вызываем функцию GetOrderByID;
-	2.2.3) И в том и в другом случае записываем полученный результат в канал и возвращаем его в return.
+	2.1.0) Контекст запроса (срок ответа микросервису «show»): передаётся в LoadOrder или в запрос к владельцу ID;
+	2.1.1) ID заказа, указанное пользователем.
+По окончании работы, возвращает заказ типа models.OrderPost для последующей выдачи его данных по http и ошибку:
+ErrOrderNotFound – заказа нет, иначе – ошибка БД (или истёк срок запроса). Порядок работы функции:
+	2.2.1) Используя аргумент из п. 2.1.1 – получаем данные из кэша. Если ID есть в кэше ненайденных заказов –
+	сразу возвращаем ErrOrderNotFound;
+	2.2.2) В случае возникновения ошибки, загружаем заказ через singleflight (поле flight, функция DoContext):
+	одновременные промахи по одному ID ждут одну загрузку. Загрузка выполняется со своим сроком LoadTimeout
+	(функция loadContext), а не со сроком первого запроса: если он отменён или его срок истёк (обычно это запрос,
+	дольше всех ждавший в очереди), остальные запросы всё равно получают заказ. Каждый запрос ждёт загрузку
+	не дольше своего срока. Если задано поле Peers (несколько экземпляров query, пакет my.service.common/peercache) –
+	заказ запрашивается у экземпляра-владельца ID, иначе (или если владелец – этот экземпляр или недоступен) –
+	функцией LoadOrder. Количество объединённых вызовов – функция Deduplicated;
+	2.2.3) И в том и в другом случае возвращаем полученный заказ.
+	Если GetOrderByID вернула ErrOrderNotFound – записываем ID в кэш ненайденных заказов на время NotFoundTTL.
+
+3) Функция LoadOrder – загрузка заказа этим экземпляром (ожидание – не дольше контекста запроса): кэш заказов,
+кэш ненайденных заказов, затем GetOrderByID через singleflight (поле dbFlight: запросы этого экземпляра и запросы
+других экземпляров к нему ждут один запрос в БД, а не выполняют insertintoorderpost и SELECT каждый; запрос в БД –
+со сроком LoadTimeout, как в п. 2.2.2). Если заказа нет – записывает ID в кэш ненайденных заказов
+на время NotFoundTTL. Используется как peercache.Options.Load: отвечает на запросы других экземпляров.
+Заказ, полученный от другого экземпляра, в кэш этого экземпляра не записывается – его хранит владелец.
+
+4) Функция loadContext – контекст общей загрузки заказа: срок LoadTimeout (0 – без срока), не связан с запросами.
+5) Функция ForgetNotFound удаляет ID из кэша ненайденных заказов. Вызывается, когда микросервис save
+сохранил заказ с этим ID (событие order.changed), чтобы заказ был найден сразу, а не после NotFoundTTL.
 
 ВАЖНО: вся работа по добавлению и поиску данных в SQL осуществляется на стороне БД посредством хранимых процедур.
 Из приложения достаточно вызвать нужную функцию и передать ей необходимые параметры. Такой подход «избавляет»
 основной процесс от обработки данных и инкапсулирует работу в БД, непосредственно в самой БД.
 
-Хранимая процедура:
-insertintoorderpost – добавляет данные в таблицу order_post.
+Хранимые процедуры:
+insertintoorderpost – добавляет данные в таблицу order_post, а для уже добавленного заказа увеличивает
+количество запросов (requested_count) – по нему прогрев кэша выбирает самые запрашиваемые заказы (файл warmup.go).
+refreshorderpost – пересчитывает строку order_post после изменения заказа (файл refresh.go);
+selectorderpayments, selectorderitems – оплата и товары заказов (файл details.go);
+searchorders – поиск заказов (файл search.go);
+selectordersbyids – пакетный запрос заказов по списку ID (файл batch.go).
 Таблица order_post – хранит информацию о заказах, которые искали пользователи;
 
 Подробный код хранимых процедур и скрипты таблиц в файле: scripts.sql.
 */
 
+// OrderCache – кэш найденных заказов (ключ – order_uid), общая реализация из пакета my.service.common/cache.
+type OrderCache = cache.Cache[string, models.OrderPost]
+
+// NotFoundCache – кэш ID, по которым заказ не найден (ключ – order_uid, значение не используется).
+type NotFoundCache = cache.Cache[string, struct{}]
+
+// ErrOrderNotFound – заказа с указанным ID нет в БД.
+var ErrOrderNotFound = errors.New("заказ не найден")
+
 type DbModel struct {
-	DB *sql.DB
+	DB            *sql.DB
+	OrderCache    *OrderCache
+	CacheTTL      time.Duration
+	NotFoundCache *NotFoundCache
+	NotFoundTTL   time.Duration
+	LoadTimeout   time.Duration
 	sync.RWMutex
-}
 
-var OrderCache = cache.NewCacheOrderPost(5*time.Minute, 10*time.Minute)
+	Peers *peercache.Group[models.OrderPost]
+
+	flight   singleflight.Group[string, models.OrderPost]
+	dbFlight singleflight.Group[string, models.OrderPost]
+}
 
-func (m *DbModel) GetOrderByID(orderId string) (result models.OrderPost, err error) {
+func (m *DbModel) GetOrderByID(ctx context.Context, orderId string) (result models.OrderPost, err error) {
 
 	create := "SELECT insertintoorderpost ($1)"
 
-	_, err = m.DB.Exec(create, orderId)
-	if err != nil {
-		goto showingExisting
+	if _, err := m.DB.ExecContext(ctx, create, orderId); err != nil && !orderPostSkipped(err) {
+		// lib/pq при отмене запроса возвращает собственную ошибку – заменяем её ошибкой контекста.
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, err
 	}
 
-showingExisting:
 	query := "SELECT order_uid, entry, total_price, customer_id, track_number, delivery_service FROM order_post WHERE order_uid = $1"
 
-	row, err := m.DB.Query(query, orderId)
+	row, err := m.DB.QueryContext(ctx, query, orderId)
 	if err != nil {
 		return result, err
 	}
+	defer row.Close()
 
-	for row.Next() {
-		err = row.Scan(&result.OrderUID, &result.Entry, &result.TotalPrice, &result.CustomerID, &result.TrackNumber, &result.DeliveryService)
+	if !row.Next() {
+		if err := row.Err(); err != nil {
+			return result, err
+		}
+		return result, ErrOrderNotFound
+	}
+	if err := row.Scan(&result.OrderUID, &result.Entry, &result.TotalPrice, &result.CustomerID, &result.TrackNumber, &result.DeliveryService); err != nil {
+		return result, err
 	}
 	row.Close()
+	if err := row.Err(); err != nil {
+		return result, err
+	}
 
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return result, err
-		} else {
-			return result, err
-		}
+	orders := []models.OrderPost{result}
+	if err := m.loadDetails(ctx, orders); err != nil {
+		return result, err
 	}
+	result = orders[0]
 
-	OrderCache.SetCacheOrderPost(result.OrderUID, result, 5*time.Minute)
+	if m.OrderCache != nil {
+		m.OrderCache.Set(result.OrderUID, result, m.CacheTTL)
+	}
 
 	return result, nil
 }
 
-func (m *DbModel) GetOriginOrder(orderId *string, ChanForResult chan models.OrderPost) chan models.OrderPost {
+// orderPostSkipped – insertintoorderpost не добавила строку по ожидаемой причине: заказа нет в order_get
+// (order_uid NULL – not_null_violation, заказ удалён – foreign_key_violation) или строку одновременно добавил
+// другой запрос (unique_violation). Остальные ошибки (соединение, отмена запроса) – ошибки запроса.
+func orderPostSkipped(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "23502", "23503", "23505":
+		return true
+	}
+	return false
+}
+
+func (m *DbModel) GetOriginOrder(ctx context.Context, orderId string) (models.OrderPost, error) {
+
+	if m.OrderCache != nil {
+		if result, ok := m.OrderCache.Get(orderId); ok {
+			return result, nil
+		}
+	}
+	if m.knownNotFound(orderId) {
+		return models.OrderPost{}, ErrOrderNotFound
+	}
+
+	result, err, _ := m.flight.DoContext(ctx, orderId, func() (models.OrderPost, error) {
+		ctx, cancel := m.loadContext()
+		defer cancel()
+		if m.Peers != nil {
+			return m.Peers.Get(ctx, orderId)
+		}
+		return m.LoadOrder(ctx, orderId)
+	})
+	return result, err
+}
+
+func (m *DbModel) LoadOrder(ctx context.Context, orderId string) (models.OrderPost, error) {
 
-	result, ok := OrderCache.GetCacheOrderPost(*orderId)
+	// Заказ мог попасть в кэш, пока выполнялся предыдущий запрос с тем же ID.
+	if m.OrderCache != nil {
+		if cached, ok := m.OrderCache.Get(orderId); ok {
+			return cached, nil
+		}
+	}
+	if m.knownNotFound(orderId) {
+		return models.OrderPost{}, ErrOrderNotFound
+	}
 
-	if !ok {
-		result, err := m.GetOrderByID(*orderId)
-		if err != nil {
-			return nil
+	order, err, _ := m.dbFlight.DoContext(ctx, orderId, func() (models.OrderPost, error) {
+		ctx, cancel := m.loadContext()
+		defer cancel()
+		order, err := m.GetOrderByID(ctx, orderId)
+		if errors.Is(err, ErrOrderNotFound) && m.NotFoundCache != nil {
+			m.NotFoundCache.Set(orderId, struct{}{}, m.NotFoundTTL)
 		}
-		ChanForResult <- result
-		return ChanForResult
+		return order, err
+	})
+	return order, err
+}
+
+func (m *DbModel) loadContext() (context.Context, context.CancelFunc) {
+	if m.LoadTimeout <= 0 {
+		return context.WithCancel(context.Background())
 	}
+	return context.WithTimeout(context.Background(), m.LoadTimeout)
+}
+
+// ForgetNotFound удаляет ID из кэша ненайденных заказов. Возвращает false, если ID там не было.
+func (m *DbModel) ForgetNotFound(orderId string) bool {
+	return m.NotFoundCache != nil && m.NotFoundCache.Delete(orderId)
+}
+
+func (m *DbModel) knownNotFound(orderId string) bool {
+	if m.NotFoundCache == nil {
+		return false
+	}
+	_, ok := m.NotFoundCache.Get(orderId)
+	return ok
+}
 
-	ChanForResult <- result
-	return ChanForResult
+// Deduplicated – количество вызовов GetOriginOrder и LoadOrder, которые получили результат одновременного запроса с тем же ID.
+func (m *DbModel) Deduplicated() uint64 {
+	return m.flight.Deduplicated() + m.dbFlight.Deduplicated()
 }
@@ -0,0 +1,73 @@
+package postgresql
+
+import (
+	"context"
+
+	"my.service.query/pkg/models"
+)
+
+/*
+Функция SearchOrders – поиск заказов (запрос models.SearchRequest) хранимой процедурой searchorders.
+Принимает в качестве аргументов контекст (ограничение времени запроса) и запрос, возвращает страницу заказов,
+курсор следующей страницы (пустой – страниц больше нет) и ошибку. Порядок работы функции:
+1) Проверяет запрос (Normalize) и курсор (ParseCursor): ошибки – models.ErrInvalidSearch;
+2) Незаданные фильтры передаются как NULL (функция nullable) – searchorders их не использует;
+3) Запрашивает на один заказ больше Limit: если он есть, курсор следующей страницы – последний заказ страницы.
+Результаты поиска в кэш не записываются: полные сведения о найденном заказе запрашиваются по ID (GetOriginOrder).
+*/
+
+func (m *DbModel) SearchOrders(ctx context.Context, request models.SearchRequest) (orders []models.OrderSummary, next string, err error) {
+
+	if err := request.Normalize(); err != nil {
+		return nil, "", err
+	}
+	// Курсор передаётся парой (payment_dt, order_uid) или NULL, NULL – первая страница; payment_dt может быть 0.
+	var afterPaid, afterUID interface{}
+	if request.Cursor != "" {
+		after, err := models.ParseCursor(request.Cursor, request.Sort)
+		if err != nil {
+			return nil, "", err
+		}
+		afterPaid, afterUID = after.PaymentDt, after.OrderUID
+	}
+
+	query := "SELECT order_uid, customer_id, track_number, delivery_service, payment_dt, amount, currency, created_at FROM searchorders ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"
+
+	rows, err := m.DB.QueryContext(ctx, query,
+		nullable(request.CustomerID), nullable(request.TrackNumber), nullable(request.DeliveryService), nullable(request.Brand),
+		nullable(request.NmID), nullable(request.PaidFrom), nullable(request.PaidTo), request.Sort,
+		afterPaid, afterUID, request.Limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	orders = []models.OrderSummary{}
+	for rows.Next() {
+		var o models.OrderSummary
+		err := rows.Scan(&o.OrderUID, &o.CustomerID, &o.TrackNumber, &o.DeliveryService, &o.PaymentDt, &o.Amount, &o.Currency, &o.CreatedAt)
+		if err != nil {
+			return nil, "", err
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(orders) > request.Limit {
+		orders = orders[:request.Limit]
+		last := orders[len(orders)-1]
+		next = models.SearchCursor{Sort: request.Sort, PaymentDt: last.PaymentDt, OrderUID: last.OrderUID}.Encode()
+	}
+	return orders, next, nil
+}
+
+// nullable возвращает nil (NULL в запросе) для нулевого значения – фильтр не задан.
+func nullable[T comparable](v T) interface{} {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
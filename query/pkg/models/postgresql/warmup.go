@@ -0,0 +1,103 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+
+	"my.service.query/pkg/models"
+)
+
+/*
+Прогрев кэша при запуске микросервиса query: после перезапуска OrderCache пуст, и первый запрос каждого заказа
+уходит в БД (insertintoorderpost и order_post). Прогрев заранее загружает в кэш заказы, которые скорее всего запросят.
+
+1) Константы WarmUpRecent и WarmUpRequested – порядок загрузки заказов:
+	1.1) WarmUpRecent – последние созданные заказы (order_get.created_at);
+	1.2) WarmUpRequested – самые запрашиваемые заказы (order_post.requested_count, увеличивается при каждом
+	запросе заказа из БД, т.е. при промахе кэша), затем – последние созданные.
+2) Функция WarmUp принимает в качестве аргументов:
+	2.1) ctx – контекст с ограничением времени прогрева (и отменой по SIGTERM);
+	2.2) mode – порядок загрузки из п. 1;
+	2.3) limit – сколько заказов загрузить всего, batch – сколько заказов загружать одним запросом;
+	2.4) progress – функция, которая вызывается после каждого пакета с количеством загруженных заказов (может быть nil).
+Загружает заказы пакетами через хранимую процедуру selectwarmuporders, дополняет пакет оплатой и товарами
+(loadDetails, файл details.go) и сохраняет каждый заказ в кэш на время CacheTTL.
+Возвращает количество загруженных заказов и ошибку. При истечении времени или отмене контекста
+уже загруженные заказы остаются в кэше, а ошибка – ошибка контекста (context.DeadlineExceeded или context.Canceled).
+Если OrderCache не задан – ничего не делает.
+*/
+
+const (
+	WarmUpRecent    = "recent"
+	WarmUpRequested = "requested"
+)
+
+func (m *DbModel) WarmUp(ctx context.Context, mode string, limit, batch int, progress func(loaded int)) (loaded int, err error) {
+
+	if mode != WarmUpRecent && mode != WarmUpRequested {
+		return 0, fmt.Errorf("неизвестный порядок прогрева кэша: %s", mode)
+	}
+	if m.OrderCache == nil || limit <= 0 {
+		return 0, nil
+	}
+	if batch <= 0 || batch > limit {
+		batch = limit
+	}
+
+	query := "SELECT order_uid, entry, total_price, customer_id, track_number, delivery_service FROM selectwarmuporders ($1, $2, $3)"
+
+	for loaded < limit {
+		size := batch
+		if rest := limit - loaded; rest < size {
+			size = rest
+		}
+
+		n, err := m.warmUpBatch(ctx, query, mode, size, loaded)
+		loaded += n
+		if err != nil {
+			// lib/pq при отмене запроса возвращает собственную ошибку – заменяем её ошибкой контекста.
+			if ctx.Err() != nil {
+				return loaded, ctx.Err()
+			}
+			return loaded, err
+		}
+		if progress != nil {
+			progress(loaded)
+		}
+		if n < size {
+			break
+		}
+	}
+	return loaded, nil
+}
+
+func (m *DbModel) warmUpBatch(ctx context.Context, query, mode string, size, offset int) (n int, err error) {
+
+	rows, err := m.DB.QueryContext(ctx, query, mode, size, offset)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var orders []models.OrderPost
+	for rows.Next() {
+		var order models.OrderPost
+		err := rows.Scan(&order.OrderUID, &order.Entry, &order.TotalPrice, &order.CustomerID, &order.TrackNumber, &order.DeliveryService)
+		if err != nil {
+			return 0, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	if err := m.loadDetails(ctx, orders); err != nil {
+		return 0, err
+	}
+	for _, order := range orders {
+		m.OrderCache.Set(order.OrderUID, order, m.CacheTTL)
+	}
+	return len(orders), nil
+}
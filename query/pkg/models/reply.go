@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Конверт ответа микросервиса «query» (Reply): встраивается в OrderReply, SearchReply и BatchReply, поэтому у всех
+ответов одинаковые поля request_id, status и error, и show определяет результат по статусу, а не по содержимому ответа.
+
+1) Status – результат запроса:
+	1.1) StatusOK – запрос выполнен (заказ найден, поиск или пакетный запрос выполнены);
+	1.2) StatusNotFound – заказа с таким ID нет;
+	1.3) StatusInvalidID – ID заказа некорректен (ValidOrderUID), в БД запрос не отправлялся;
+	1.4) StatusInvalidRequest – некорректный запрос поиска или пакетный запрос (нет фильтров, слишком много ID);
+	1.5) StatusUnavailable – query перегружен (очередь запросов заполнена) или срок запроса истёк: запрос можно повторить;
+	1.6) StatusInternal – ошибка БД. Подробности пишутся в лог query, в поле Error – общее сообщение.
+2) Error – сообщение для пользователя; пустое, если Status = StatusOK.
+3) Функция ValidOrderUID проверяет ID заказа: непустой, не длиннее MaxOrderUIDLen байт, корректный UTF-8
+без пробелов и управляющих символов.
+*/
+
+const (
+	StatusOK             = "ok"
+	StatusNotFound       = "not_found"
+	StatusInvalidID      = "invalid_id"
+	StatusInvalidRequest = "invalid_request"
+	StatusUnavailable    = "unavailable"
+	StatusInternal       = "internal"
+
+	MaxOrderUIDLen = 128
+)
+
+type Reply struct {
+	RequestID string `json:"request_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Fail задаёт статус ошибки и сообщение для пользователя.
+func (r *Reply) Fail(status, message string) {
+	r.Status, r.Error = status, message
+}
+
+func ValidOrderUID(id string) bool {
+	if id == "" || len(id) > MaxOrderUIDLen || !utf8.ValidString(id) {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}) < 0
+}
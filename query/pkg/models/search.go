@@ -0,0 +1,136 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+/*
+Поиск заказов по сведениям, которые обычно знает служба поддержки: ID клиента, трек-номер, служба доставки,
+бренд или артикул (nm_id) товара и период оплаты (payment_dt).
+
+1) SearchRequest – запрос поиска от микросервиса «show»: фильтры (пустая строка или 0 – фильтр не задан),
+порядок сортировки (Sort), размер страницы (Limit) и курсор следующей страницы (Cursor – NextCursor предыдущего ответа).
+PaidFrom и PaidTo – границы периода оплаты включительно, Unix-время в секундах.
+2) OrderSummary – строка результата поиска: сведения о заказе без товаров (полные сведения – запросом по ID).
+3) SearchReply – ответ микросервиса «query»: конверт ответа (Reply, файл reply.go), найденные заказы и NextCursor
+(пустой – страниц больше нет). Запрос, отклонённый Normalize или ParseCursor, – статус StatusInvalidRequest
+и причина в поле Error.
+4) Функция Normalize проверяет запрос и задаёт значения по умолчанию: нужен хотя бы один фильтр (поиск по всем
+заказам – полный просмотр таблиц), Sort – SortPaidDesc или SortPaidAsc (по умолчанию SortPaidDesc),
+Limit – от 1 до SearchMaxLimit (0 – SearchDefaultLimit). PaidTo позже 2038 года ограничивается
+максимальным значением payment_dt (INTEGER в БД). Ошибки оборачивают ErrInvalidSearch.
+5) SearchCursor – позиция в результатах поиска (keyset pagination): порядок сортировки и payment_dt, order_uid
+последнего заказа страницы. Следующая страница начинается сразу после него, поэтому заказы, добавленные
+между запросами страниц, не сдвигают результаты. Функция Encode кодирует курсор в непрозрачную строку (base64 JSON),
+функция ParseCursor – обратно; курсор другого порядка сортировки – ErrInvalidSearch.
+*/
+
+const (
+	SortPaidDesc = "paid_desc"
+	SortPaidAsc  = "paid_asc"
+
+	SearchDefaultLimit = 20
+	SearchMaxLimit     = 100
+)
+
+var ErrInvalidSearch = errors.New("некорректный запрос поиска")
+
+type SearchRequest struct {
+	RequestID       string `json:"request_id"`
+	CustomerID      string `json:"customer_id,omitempty"`
+	TrackNumber     string `json:"track_number,omitempty"`
+	DeliveryService string `json:"delivery_service,omitempty"`
+	Brand           string `json:"brand,omitempty"`
+	NmID            int    `json:"nm_id,omitempty"`
+	PaidFrom        int64  `json:"paid_from,omitempty"`
+	PaidTo          int64  `json:"paid_to,omitempty"`
+	Sort            string `json:"sort,omitempty"`
+	Limit           int    `json:"limit,omitempty"`
+	Cursor          string `json:"cursor,omitempty"`
+}
+
+type OrderSummary struct {
+	OrderUID        string    `json:"order_uid"`
+	CustomerID      string    `json:"customer_id"`
+	TrackNumber     string    `json:"track_number"`
+	DeliveryService string    `json:"delivery_service"`
+	PaymentDt       int       `json:"payment_dt"`
+	Amount          int       `json:"amount"`
+	Currency        string    `json:"currency"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type SearchReply struct {
+	Reply
+	Orders     []OrderSummary `json:"orders"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type SearchCursor struct {
+	Sort      string `json:"s"`
+	PaymentDt int    `json:"p"`
+	OrderUID  string `json:"o"`
+}
+
+func (r *SearchRequest) Normalize() error {
+
+	r.CustomerID = strings.TrimSpace(r.CustomerID)
+	r.TrackNumber = strings.TrimSpace(r.TrackNumber)
+	r.DeliveryService = strings.TrimSpace(r.DeliveryService)
+	r.Brand = strings.TrimSpace(r.Brand)
+
+	if r.CustomerID == "" && r.TrackNumber == "" && r.DeliveryService == "" && r.Brand == "" &&
+		r.NmID == 0 && r.PaidFrom == 0 && r.PaidTo == 0 {
+		return fmt.Errorf("%w: не задан ни один фильтр", ErrInvalidSearch)
+	}
+	if r.NmID < 0 || r.PaidFrom < 0 || r.PaidTo < 0 {
+		return fmt.Errorf("%w: nm_id, paid_from и paid_to не могут быть отрицательными", ErrInvalidSearch)
+	}
+	if r.PaidTo > math.MaxInt32 {
+		r.PaidTo = math.MaxInt32
+	}
+	if r.PaidFrom > math.MaxInt32 || (r.PaidFrom > 0 && r.PaidTo > 0 && r.PaidFrom > r.PaidTo) {
+		return fmt.Errorf("%w: начало периода оплаты позже конца", ErrInvalidSearch)
+	}
+
+	switch r.Sort {
+	case "":
+		r.Sort = SortPaidDesc
+	case SortPaidDesc, SortPaidAsc:
+	default:
+		return fmt.Errorf("%w: неизвестный порядок сортировки %q (%s или %s)", ErrInvalidSearch, r.Sort, SortPaidDesc, SortPaidAsc)
+	}
+
+	switch {
+	case r.Limit == 0:
+		r.Limit = SearchDefaultLimit
+	case r.Limit < 0 || r.Limit > SearchMaxLimit:
+		return fmt.Errorf("%w: limit должен быть от 1 до %d", ErrInvalidSearch, SearchMaxLimit)
+	}
+	return nil
+}
+
+func (c SearchCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func ParseCursor(s, sort string) (c SearchCursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.OrderUID == "" {
+		return c, fmt.Errorf("%w: повреждённый курсор", ErrInvalidSearch)
+	}
+	if c.Sort != sort {
+		return c, fmt.Errorf("%w: курсор для сортировки %s, запрошена %s", ErrInvalidSearch, c.Sort, sort)
+	}
+	return c, nil
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/lib/pq"
 
+	"my.service.common/cache"
+	"my.service.common/peercache"
 	"my.service.query/pkg/models"
 	"my.service.query/pkg/models/postgresql"
 )
@@ -16,7 +24,29 @@ import (
 1) За основу берём корректную сущность типа models.OrderPost из БД;
 2) Далее выполняем тесовое подключение к БД;
 3) На основании этого подключения, делаем запрос из БД с помощью функции GetOrderByID, передавая её в качестве аргумента ID образца;
-4) Сравниваем результаты: образец и полученный результат – если они сходятся: тест пройден.
+4) Сравниваем результаты: образец и полученный результат (без оплаты и товаров) – если они сходятся
+и итоговая цена равна стоимости товаров и доставки: тест пройден.
+
+Тестирование кэша ненайденных заказов (функция GetOriginOrder, БД не нужна – поле DB не задано):
+1) ID из кэша ненайденных заказов возвращает ErrOrderNotFound без обращения к БД;
+2) Найденный заказ из кэша заказов выдаётся без обращения к БД;
+3) После ForgetNotFound (событие order.changed) ID в кэше ненайденных заказов отсутствует.
+
+Тестирование распределённого кэша (два экземпляра в одном процессе, peercache.LocalTransport, БД не нужна):
+1) Заказ из кэша владельца выдаётся другому экземпляру и не записывается в его кэш;
+2) ID из кэша ненайденных заказов владельца возвращает другому экземпляру ErrOrderNotFound.
+
+Тестирование запроса поиска заказов (БД не нужна):
+1) Normalize задаёт сортировку и размер страницы по умолчанию и отклоняет запросы без фильтров, с неизвестной
+сортировкой, слишком большой страницей и перепутанными границами периода оплаты (models.ErrInvalidSearch);
+2) Курсор кодируется и декодируется без потерь, а повреждённый курсор и курсор другой сортировки отклоняются.
+
+Тестирование пакетного запроса заказов (функция GetOrders, БД не нужна – все ID есть в кэшах):
+результаты в порядке запроса, повторяющийся ID – один раз, статусы found и not_found.
+
+Тестирование конверта ответа (файл pkg/models/reply.go):
+1) ValidOrderUID отклоняет пустой ID, слишком длинный ID, ID с пробелами, управляющими символами и некорректным UTF-8;
+2) Поля конверта (request_id, status, error) находятся на верхнем уровне JSON ответа, заказ – только при статусе ok.
 */
 
 func TestGetOrderByID(t *testing.T) {
@@ -40,13 +70,220 @@ func TestGetOrderByID(t *testing.T) {
 		DB: testSQLDB,
 	}
 
-	result, err := testDB.GetOrderByID("1q1")
+	result, err := testDB.GetOrderByID(context.Background(), "1q1")
 
 	if err != nil {
 		t.Fatalf("Error %v occurated.", err)
 	}
 
-	if !reflect.DeepEqual(check, result) {
-		t.Fatalf("Incorrect parsing: %v and %v", check, result)
+	if result.ItemsCount != len(result.Items) || result.ItemsTotal+result.Payment.DeliveryCost != result.TotalPrice {
+		t.Fatalf("Incorrect totals: %+v", result)
+	}
+
+	base := result
+	base.Payment, base.Items, base.ItemsCount, base.ItemsTotal = models.Payment{}, nil, 0, 0
+	if !reflect.DeepEqual(check, base) {
+		t.Fatalf("Incorrect parsing: %v and %v", check, base)
+	}
+}
+
+func TestGetOriginOrderNotFoundCache(t *testing.T) {
+	testDB := postgresql.DbModel{
+		OrderCache:    cache.New[string, models.OrderPost](time.Minute, 0),
+		CacheTTL:      time.Minute,
+		NotFoundCache: cache.New[string, struct{}](time.Minute, 0),
+		NotFoundTTL:   time.Minute,
+	}
+	testDB.NotFoundCache.Set("missing", struct{}{}, cache.DefaultTTL)
+	testDB.OrderCache.Set("1q1", models.OrderPost{OrderUID: "1q1"}, cache.DefaultTTL)
+
+	if _, err := testDB.GetOriginOrder(context.Background(), "missing"); !errors.Is(err, postgresql.ErrOrderNotFound) {
+		t.Fatalf("GetOriginOrder(missing) = %v; want ErrOrderNotFound", err)
+	}
+
+	order, err := testDB.GetOriginOrder(context.Background(), "1q1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderUID != "1q1" {
+		t.Fatalf("GetOriginOrder(1q1) = %v", order)
+	}
+
+	if !testDB.ForgetNotFound("missing") || testDB.ForgetNotFound("missing") {
+		t.Fatal("ForgetNotFound did not remove the ID exactly once")
+	}
+}
+
+func TestGetOriginOrderFromPeer(t *testing.T) {
+	transport := peercache.NewLocalTransport()
+	replicas := make(map[string]*postgresql.DbModel)
+	for _, name := range []string{"query-a", "query-b"} {
+		m := &postgresql.DbModel{
+			OrderCache:    cache.New[string, models.OrderPost](time.Minute, 0),
+			CacheTTL:      time.Minute,
+			NotFoundCache: cache.New[string, struct{}](time.Minute, 0),
+			NotFoundTTL:   time.Minute,
+		}
+		group, err := peercache.New(peercache.Options[models.OrderPost]{
+			Self:      name,
+			Transport: transport,
+			Load:      m.LoadOrder,
+			NotFound:  postgresql.ErrOrderNotFound,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		group.SetPeers("query-a", "query-b")
+		transport.Register(name, group)
+		m.Peers = group
+		replicas[name] = m
+	}
+	a, b := replicas["query-a"], replicas["query-b"]
+
+	// ownedByB – первый ID с префиксом prefix, владелец которого – query-b.
+	ownedByB := func(prefix string) string {
+		for i := 0; ; i++ {
+			id := fmt.Sprintf("%s%d", prefix, i)
+			if a.Peers.Owner(id) == "query-b" {
+				return id
+			}
+		}
+	}
+
+	id := ownedByB("order-")
+	b.OrderCache.Set(id, models.OrderPost{OrderUID: id}, cache.DefaultTTL)
+	order, err := a.GetOriginOrder(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderUID != id {
+		t.Fatalf("GetOriginOrder(%s) = %v", id, order)
+	}
+	if _, _, ok := a.OrderCache.Peek(id); ok {
+		t.Fatalf("%s cached by query-a, owner is query-b", id)
+	}
+
+	missing := ownedByB("missing-")
+	b.NotFoundCache.Set(missing, struct{}{}, cache.DefaultTTL)
+	if _, err := a.GetOriginOrder(context.Background(), missing); !errors.Is(err, postgresql.ErrOrderNotFound) {
+		t.Fatalf("GetOriginOrder(%s) = %v; want ErrOrderNotFound", missing, err)
+	}
+
+	if stats := a.Peers.Stats(); stats.Remote != 2 || stats.Local != 0 || b.Peers.Stats().Served != 2 {
+		t.Fatalf("query-a Stats = %+v, query-b Stats = %+v", stats, b.Peers.Stats())
+	}
+}
+
+func TestSearchRequestNormalize(t *testing.T) {
+	request := models.SearchRequest{TrackNumber: " WBIL2817015795SL "}
+	if err := request.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if request.TrackNumber != "WBIL2817015795SL" || request.Sort != models.SortPaidDesc || request.Limit != models.SearchDefaultLimit {
+		t.Fatalf("Normalize = %+v", request)
+	}
+
+	for _, bad := range []models.SearchRequest{
+		{},
+		{CustomerID: "   "},
+		{Brand: "Vivienne Sabo", Sort: "name"},
+		{Brand: "Vivienne Sabo", Limit: models.SearchMaxLimit + 1},
+		{PaidFrom: 1637907727, PaidTo: 1637900000},
+		{NmID: -1},
+	} {
+		if err := bad.Normalize(); !errors.Is(err, models.ErrInvalidSearch) {
+			t.Fatalf("Normalize(%+v) err = %v, want ErrInvalidSearch", bad, err)
+		}
+	}
+}
+
+func TestSearchCursor(t *testing.T) {
+	cursor := models.SearchCursor{Sort: models.SortPaidAsc, PaymentDt: 1637907727, OrderUID: "b563feb7b2b84b6test"}
+
+	parsed, err := models.ParseCursor(cursor.Encode(), models.SortPaidAsc)
+	if err != nil || parsed != cursor {
+		t.Fatalf("ParseCursor = %+v, %v; want %+v", parsed, err, cursor)
+	}
+	if _, err := models.ParseCursor(cursor.Encode(), models.SortPaidDesc); !errors.Is(err, models.ErrInvalidSearch) {
+		t.Fatalf("cursor of another sort: err = %v", err)
+	}
+	if _, err := models.ParseCursor("not a cursor", models.SortPaidAsc); !errors.Is(err, models.ErrInvalidSearch) {
+		t.Fatalf("broken cursor: err = %v", err)
+	}
+}
+
+func TestGetOrdersFromCache(t *testing.T) {
+	testDB := postgresql.DbModel{
+		OrderCache:    cache.New[string, models.OrderPost](time.Minute, 0),
+		CacheTTL:      time.Minute,
+		NotFoundCache: cache.New[string, struct{}](time.Minute, 0),
+		NotFoundTTL:   time.Minute,
+	}
+	testDB.OrderCache.Set("1q1", models.OrderPost{OrderUID: "1q1"}, cache.DefaultTTL)
+	testDB.OrderCache.Set("2q2", models.OrderPost{OrderUID: "2q2"}, cache.DefaultTTL)
+	testDB.NotFoundCache.Set("missing", struct{}{}, cache.DefaultTTL)
+
+	results, err := testDB.GetOrders(context.Background(), []string{"2q2", "missing", "1q1", "2q2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ id, status string }{
+		{"2q2", models.BatchFound},
+		{"missing", models.BatchNotFound},
+		{"1q1", models.BatchFound},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("GetOrders returned %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.OrderUID != w.id || r.Status != w.status || (r.Status == models.BatchFound) != (r.Order != nil) {
+			t.Fatalf("results[%d] = %+v, want %s %s", i, r, w.id, w.status)
+		}
+	}
+	if results[0].Order.OrderUID != "2q2" {
+		t.Fatalf("results[0].Order = %+v", results[0].Order)
+	}
+}
+
+func TestValidOrderUID(t *testing.T) {
+	valid := []string{"1q1", "b563feb7b2b84b6test", "заказ-1", strings.Repeat("a", models.MaxOrderUIDLen)}
+	for _, id := range valid {
+		if !models.ValidOrderUID(id) {
+			t.Errorf("ValidOrderUID(%q) = false", id)
+		}
+	}
+	invalid := []string{"", " ", "1q1 ", "1 q1", "1q1\n", "1q1\x00", "\xff\xfe", strings.Repeat("a", models.MaxOrderUIDLen+1)}
+	for _, id := range invalid {
+		if models.ValidOrderUID(id) {
+			t.Errorf("ValidOrderUID(%q) = true", id)
+		}
+	}
+}
+
+func TestOrderReplyJSON(t *testing.T) {
+	reply := models.OrderReply{Reply: models.Reply{RequestID: "r1", Status: models.StatusOK}}
+	reply.Fail(models.StatusNotFound, "заказ не найден")
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"request_id":"r1","status":"not_found","error":"заказ не найден"}`; string(data) != want {
+		t.Fatalf("json.Marshal(OrderReply) = %s; want %s", data, want)
+	}
+
+	reply = models.OrderReply{Reply: models.Reply{RequestID: "r2", Status: models.StatusOK}, Order: &models.OrderPost{OrderUID: "1q1"}}
+	data, err = json.Marshal(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded models.OrderReply
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Status != models.StatusOK || decoded.Error != "" || decoded.Order == nil || decoded.Order.OrderUID != "1q1" {
+		t.Fatalf("json.Unmarshal(%s) = %+v", data, decoded)
 	}
 }
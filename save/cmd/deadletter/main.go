@@ -0,0 +1,185 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/lib/pq"
+	nats "github.com/nats-io/nats.go"
+	"my.service.common/config"
+	"my.service.save/pkg/models/postgresql"
+)
+
+/*
+Команда deadletter – просмотр и повторная отправка сообщений из таблицы dead_letter.
+Настройки (структура Config) загружаются так же, как в микросервисе «save»: файл настроек (-config или CONFIG_FILE),
+переменные окружения DSN, JETSTREAM_URL, ORDER_SUBJECT и флаги.
+Использование:
+1) deadletter [-dsn ...] list [-n 50] [-all] – список последних записей (по умолчанию – только не отправленные повторно);
+2) deadletter [-dsn ...] show ID – запись целиком, вместе с исходным сообщением;
+3) deadletter [-dsn ...] replay ID [ID ...] – повторная отправка исходного сообщения в тот же subject NATS JetStream.
+Сообщение проходит тот же путь, что и новое: проверку и сохранение в функции SubAndSave микросервиса «save».
+После отправки запись отмечается как отправленная повторно (replayed_at).
+*/
+
+type Config struct {
+	DSN     string `json:"dsn" env:"DSN" flag:"dsn" usage:"Название источника данных" required:"true" secret:"true"`
+	NATSURL string `json:"jetstream_url" env:"JETSTREAM_URL" flag:"nats-url" usage:"Адрес сервера NATS JetStream" required:"true"`
+	Subject string `json:"order_subject" env:"ORDER_SUBJECT" flag:"subject" usage:"Subject для записей без subject" required:"true"`
+}
+
+func main() {
+
+	cfg := Config{
+		DSN:     "user=postgres password=postgres dbname=test sslmode=disable",
+		NATSURL: "wbx-world-nats-stage.dp.wb.ru",
+		Subject: "go.test",
+	}
+	args := config.MustLoad(&cfg, io.Discard)
+
+	if len(args) < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	db, err := OpenDB(cfg.DSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	model := &postgresql.DbModel{DB: db}
+
+	switch command, args := args[0], args[1:]; command {
+	case "list":
+		err = list(model, args)
+	case "show":
+		err = show(model, args)
+	case "replay":
+		err = replay(model, cfg, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Использование: %s [-config FILE] [-dsn DSN] [-nats-url URL] list [-n N] [-all] | show ID | replay ID [ID ...]\n", os.Args[0])
+}
+
+func list(model *postgresql.DbModel, args []string) error {
+
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	limit := fs.Int("n", 50, "Количество записей")
+	all := fs.Bool("all", false, "Показывать уже отправленные повторно")
+	fs.Parse(args)
+
+	letters, err := model.GetDeadLetters(*limit, *all)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSUBJECT\tSEQ\tCREATED\tREPLAYED\tREASON")
+	for _, letter := range letters {
+		replayed := "-"
+		if letter.ReplayedAt != nil {
+			replayed = letter.ReplayedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\n", letter.ID, letter.Subject, letter.Sequence,
+			letter.CreatedAt.Format(time.RFC3339), replayed, letter.Reason)
+	}
+	return w.Flush()
+}
+
+func show(model *postgresql.DbModel, args []string) error {
+
+	if len(args) != 1 {
+		return fmt.Errorf("укажите ID записи")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	letter, err := model.GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ID:       %d\n", letter.ID)
+	fmt.Printf("Subject:  %s\n", letter.Subject)
+	fmt.Printf("Sequence: %d\n", letter.Sequence)
+	fmt.Printf("Created:  %s\n", letter.CreatedAt.Format(time.RFC3339))
+	if letter.ReplayedAt != nil {
+		fmt.Printf("Replayed: %s\n", letter.ReplayedAt.Format(time.RFC3339))
+	}
+	fmt.Printf("Reason:   %s\n", letter.Reason)
+	fmt.Printf("Payload:\n%s\n", letter.Payload)
+	return nil
+}
+
+func replay(model *postgresql.DbModel, cfg Config, args []string) error {
+
+	if len(args) == 0 {
+		return fmt.Errorf("укажите ID записей")
+	}
+
+	nc, err := nats.Connect(cfg.NATSURL, nats.Name("SK-deadletter"))
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		letter, err := model.GetDeadLetter(id)
+		if err != nil {
+			return fmt.Errorf("запись %d: %w", id, err)
+		}
+
+		subject := letter.Subject
+		if subject == "" {
+			subject = cfg.Subject
+		}
+
+		if _, err := js.Publish(subject, letter.Payload); err != nil {
+			return fmt.Errorf("запись %d: %w", id, err)
+		}
+		if err := model.MarkDeadLetterReplayed(id); err != nil {
+			return fmt.Errorf("запись %d: %w", id, err)
+		}
+		fmt.Printf("Запись %d отправлена повторно в %s\n", id, subject)
+	}
+	return nil
+}
+
+func OpenDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
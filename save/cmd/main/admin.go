@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+
+	"my.service.common/cacheadmin"
+	"my.service.save/pkg/models/postgresql"
+)
+
+/*
+Функция startAdmin запускает администрирование кэша заказов (общий запуск – cacheadmin.Start, кэш orders):
+статистика, просмотр заказа по ID, удаление заказа или заказов по префиксу ID и очистка кэша.
+Кэш save – источник повторного сохранения заказа при сбое записи в БД, поэтому удалять из него заказы стоит,
+только когда БД доступна (иначе заказ, ожидающий повторного сохранения, будет отправлен в dead_letter).
+Настройки: admin_subject (NATS), admin_addr (HTTP) и admin_token. Возвращает функцию остановки HTTP-сервера
+(вызывается в main при остановке).
+*/
+
+func (app *Application) startAdmin(cfg Config, orderCache *postgresql.OrderCache) (shutdown func(ctx context.Context) error, err error) {
+	return cacheadmin.Start(app.nc.Conn, cacheadmin.Options{
+		Addr:    cfg.AdminAddr,
+		Subject: cfg.AdminSubject,
+		Token:   cfg.AdminToken,
+		Caches: map[string]cacheadmin.Target{
+			"orders": cacheadmin.Wrap(orderCache),
+		},
+		InfoLog:  app.infoLog,
+		ErrorLog: app.errorLog,
+	})
+}
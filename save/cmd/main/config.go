@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"my.service.common/cache"
+	"my.service.common/natsconn"
+	"my.service.save/pkg/models"
+	"my.service.save/pkg/models/postgresql"
+)
+
+/*
+Структура Config – настройки микросервиса «save». Загружаются функцией config.MustLoad (пакет my.service.common/config)
+в порядке: значения по умолчанию (функция defaultConfig), файл настроек (-config или CONFIG_FILE),
+переменные окружения, флаги командной строки.
+
+Функция Validate проверяет значения, которые нельзя проверить по типу: политику -on-conflict, параметры JetStream,
+ограничения и снимки кэша, токен администрирования кэша (файл admin.go).
+Функция natsOptions возвращает параметры соединения с NATS (пакет my.service.common/natsconn).
+Функция cacheOptions возвращает параметры кэша заказов (пакет my.service.common/cache), включая файл снимка кэша
+(ошибки записи снимка выводятся в errorLog).
+Функция subscriberConfig возвращает параметры получения заказов из NATS JetStream для структуры Application.
+*/
+
+type Config struct {
+	DSN                   string        `json:"dsn" env:"DSN" flag:"dsn" usage:"Название источника данных" required:"true" secret:"true"`
+	NATSURL               string        `json:"jetstream_url" env:"JETSTREAM_URL" flag:"nats-url" usage:"Адрес сервера NATS JetStream" required:"true"`
+	ClientID              string        `json:"client_id" env:"SAVE_CLIENT_ID" flag:"client-id" usage:"Имя соединения с NATS, уникальное для каждого экземпляра" required:"true"`
+	Stream                string        `json:"stream" env:"SAVE_STREAM" flag:"stream" usage:"Поток JetStream для новых заказов" required:"true"`
+	Subject               string        `json:"order_subject" env:"ORDER_SUBJECT" flag:"subject" usage:"Subject, в который публикуются новые заказы" required:"true"`
+	OrderChangedSubject   string        `json:"order_changed_subject" env:"ORDER_CHANGED_SUBJECT" flag:"changed-subject" usage:"Subject событий о сохранённых заказах для микросервиса «query» (пусто – не публиковать)"`
+	Durable               string        `json:"durable" env:"SAVE_DURABLE" flag:"durable" usage:"Имя durable pull-консьюмера: экземпляры с одним именем делят сообщения между собой" required:"true"`
+	AckWait               time.Duration `json:"ack_wait" env:"SAVE_ACK_WAIT" flag:"ack-wait" usage:"Время ожидания подтверждения перед повторной доставкой"`
+	MaxAckPending         int           `json:"max_ack_pending" env:"SAVE_MAX_ACK_PENDING" flag:"max-ack-pending" usage:"Максимум неподтверждённых сообщений"`
+	MaxDeliver            int           `json:"max_deliver" env:"SAVE_MAX_DELIVER" flag:"max-deliver" usage:"Максимум попыток доставки одного сообщения"`
+	Batch                 int           `json:"batch" env:"SAVE_BATCH" flag:"batch" usage:"Количество сообщений в одном запросе Fetch"`
+	FetchWait             time.Duration `json:"fetch_wait" env:"SAVE_FETCH_WAIT" flag:"fetch-wait" usage:"Время ожидания одного запроса Fetch"`
+	Backoff               time.Duration `json:"backoff" env:"SAVE_BACKOFF" flag:"backoff" usage:"Задержка перед первой повторной доставкой, далее – в 2 раза больше"`
+	OnConflict            string        `json:"on_conflict" env:"SAVE_ON_CONFLICT" flag:"on-conflict" usage:"Поведение при повторном order_uid с другим содержимым: reject – отклонить, version – сохранить новую версию"`
+	CacheTTL              time.Duration `json:"cache_ttl" env:"SAVE_CACHE_TTL" flag:"cache-ttl" usage:"Время хранения заказа в кэше"`
+	CacheCleanup          time.Duration `json:"cache_cleanup" env:"SAVE_CACHE_CLEANUP" flag:"cache-cleanup" usage:"Интервал удаления устаревших записей из кэша"`
+	CacheMaxEntries       int           `json:"cache_max_entries" env:"SAVE_CACHE_MAX_ENTRIES" flag:"cache-max-entries" usage:"Максимальное количество заказов в кэше (0 – без ограничения)"`
+	CacheMaxBytes         int64         `json:"cache_max_bytes" env:"SAVE_CACHE_MAX_BYTES" flag:"cache-max-bytes" usage:"Примерный объём памяти под заказы в кэше, байт (0 – без ограничения)"`
+	CachePolicy           string        `json:"cache_policy" env:"SAVE_CACHE_POLICY" flag:"cache-policy" usage:"Политика вытеснения из кэша: lru или lfu"`
+	CacheSnapshot         string        `json:"cache_snapshot" env:"SAVE_CACHE_SNAPSHOT" flag:"cache-snapshot" usage:"Файл снимка кэша: восстанавливается при запуске, записывается периодически и при остановке (пусто – без снимков)"`
+	CacheSnapshotInterval time.Duration `json:"cache_snapshot_interval" env:"SAVE_CACHE_SNAPSHOT_INTERVAL" flag:"cache-snapshot-interval" usage:"Интервал записи снимка кэша (0 – только при остановке)"`
+	AdminAddr             string        `json:"admin_addr" env:"SAVE_ADMIN_ADDR" flag:"admin-addr" usage:"Адрес HTTP-сервера администрирования кэша (пусто – выключен)"`
+	AdminSubject          string        `json:"admin_subject" env:"SAVE_ADMIN_SUBJECT" flag:"admin-subject" usage:"Subject запросов NATS администрирования кэша (пусто – выключен)"`
+	AdminToken            string        `json:"admin_token" env:"ADMIN_TOKEN" flag:"admin-token" usage:"Токен доступа к администрированию кэша" secret:"true"`
+	ReconnectWait         time.Duration `json:"nats_reconnect_wait" env:"NATS_RECONNECT_WAIT" flag:"reconnect-wait" usage:"Пауза между попытками переподключения к NATS"`
+	ReconnectBuf          int           `json:"nats_reconnect_buf" env:"NATS_RECONNECT_BUF" flag:"reconnect-buf" usage:"Размер буфера публикаций (байт) на время переподключения к NATS"`
+	ShutdownTimeout       time.Duration `json:"shutdown_timeout" env:"SAVE_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"Максимальное время остановки по сигналу SIGTERM (0 – без ограничения)"`
+}
+
+func defaultConfig() Config {
+	hostname, _ := os.Hostname()
+	return Config{
+		DSN:                   "user=postgres password=postgres dbname=test sslmode=disable",
+		NATSURL:               "wbx-world-nats-stage.dp.wb.ru",
+		ClientID:              "SK-" + hostname,
+		Stream:                "ORDERS",
+		Subject:               "go.test",
+		OrderChangedSubject:   "order.changed",
+		Durable:               "save",
+		AckWait:               30 * time.Second,
+		MaxAckPending:         64,
+		MaxDeliver:            5,
+		Batch:                 16,
+		FetchWait:             5 * time.Second,
+		Backoff:               time.Second,
+		OnConflict:            postgresql.ConflictReject,
+		CacheTTL:              5 * time.Minute,
+		CacheCleanup:          10 * time.Minute,
+		CacheMaxEntries:       10000,
+		CacheMaxBytes:         32 * 1024 * 1024,
+		CachePolicy:           string(cache.PolicyLRU),
+		CacheSnapshotInterval: 30 * time.Second,
+		ReconnectWait:         2 * time.Second,
+		ReconnectBuf:          8 * 1024 * 1024,
+		ShutdownTimeout:       20 * time.Second,
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.OnConflict != postgresql.ConflictReject && c.OnConflict != postgresql.ConflictVersion {
+		return fmt.Errorf("неизвестная политика on_conflict: %s", c.OnConflict)
+	}
+	if c.AckWait <= time.Second {
+		return fmt.Errorf("ack_wait должен быть больше 1s, получено %s", c.AckWait)
+	}
+	if c.Batch < 1 || c.MaxAckPending < 1 {
+		return fmt.Errorf("batch и max_ack_pending должны быть больше 0")
+	}
+	if c.MaxDeliver < 0 || c.Backoff < 0 || c.FetchWait <= 0 || c.CacheTTL <= 0 {
+		return fmt.Errorf("max_deliver и backoff не могут быть отрицательными, fetch_wait и cache_ttl – должны быть больше 0")
+	}
+	if c.CacheMaxEntries < 0 || c.CacheMaxBytes < 0 || c.CacheSnapshotInterval < 0 {
+		return fmt.Errorf("cache_max_entries, cache_max_bytes и cache_snapshot_interval не могут быть отрицательными")
+	}
+	if (c.AdminAddr != "" || c.AdminSubject != "") && c.AdminToken == "" {
+		return fmt.Errorf("для admin_addr и admin_subject нужен admin_token")
+	}
+	_, err := cache.ParsePolicy(c.CachePolicy)
+	return err
+}
+
+func (c *Config) cacheOptions(errorLog *log.Logger) cache.Options[string, models.OrderGet] {
+	return cache.Options[string, models.OrderGet]{
+		DefaultTTL:       c.CacheTTL,
+		CleanupInterval:  c.CacheCleanup,
+		MaxEntries:       c.CacheMaxEntries,
+		MaxBytes:         c.CacheMaxBytes,
+		Policy:           cache.Policy(c.CachePolicy),
+		SnapshotPath:     c.CacheSnapshot,
+		SnapshotInterval: c.CacheSnapshotInterval,
+		OnSnapshot: func(entries int, err error) {
+			if err != nil {
+				errorLog.Printf("Снимок кэша %s не записан: %v", c.CacheSnapshot, err)
+			}
+		},
+	}
+}
+
+func (c *Config) natsOptions(infoLog, errorLog *log.Logger) natsconn.Options {
+	return natsconn.Options{
+		URL:              c.NATSURL,
+		Name:             c.ClientID,
+		ReconnectWait:    c.ReconnectWait,
+		ReconnectBufSize: c.ReconnectBuf,
+		DrainTimeout:     c.ShutdownTimeout,
+		InfoLog:          infoLog,
+		ErrorLog:         errorLog,
+	}
+}
+
+func (c *Config) subscriberConfig() SubscriberConfig {
+	return SubscriberConfig{
+		Stream:        c.Stream,
+		Subject:       c.Subject,
+		DurableName:   c.Durable,
+		AckWait:       c.AckWait,
+		MaxAckPending: c.MaxAckPending,
+		MaxDeliver:    c.MaxDeliver,
+		BatchSize:     c.Batch,
+		FetchWait:     c.FetchWait,
+		Backoff:       c.Backoff,
+		CacheTTL:      c.CacheTTL,
+	}
+}
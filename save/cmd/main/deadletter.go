@@ -0,0 +1,35 @@
+package main
+
+import (
+	nats "github.com/nats-io/nats.go"
+	"my.service.save/pkg/models"
+)
+
+/*
+Функция sendToDeadLetter принимает в качестве аргументов полученное сообщение и причину отказа.
+Сохраняет исходные данные сообщения, причину, subject и номер сообщения в потоке JetStream в таблицу dead_letter.
+Такие сообщения можно просмотреть и отправить повторно с помощью команды cmd/deadletter.
+Если сохранить сообщение не удалось (например, БД недоступна) – выводит его целиком в errorLog
+и возвращает ошибку: такое сообщение нельзя подтверждать.
+*/
+
+func (app *Application) sendToDeadLetter(m *nats.Msg, reason error) error {
+
+	letter := models.DeadLetter{
+		Subject: m.Subject,
+		Reason:  reason.Error(),
+		Payload: m.Data,
+	}
+	if meta, err := m.Metadata(); err == nil {
+		letter.Sequence = meta.Sequence.Stream
+	}
+
+	id, err := app.orderGet.InsertDeadLetter(letter)
+	if err != nil {
+		app.errorLog.Printf("Сообщение %s #%d не сохранено в dead_letter: %v. Причина отказа: %v. Сообщение: %s",
+			m.Subject, letter.Sequence, err, reason, m.Data)
+		return err
+	}
+	app.infoLog.Printf("Сообщение %s #%d перемещено в dead_letter (запись %d): %v", m.Subject, letter.Sequence, id, reason)
+	return nil
+}
@@ -4,31 +4,38 @@ import (
 	"fmt"
 
 	"my.service.save/pkg/models"
+	"my.service.save/pkg/models/postgresql"
 )
 
 /*
 Функция InsertAll принимает в качестве аргумента объект типа OrderGet.
-В процессе выполнения: поочередно вызывает функции добавления данных в БД.
+В процессе выполнения: сохраняет заказ в БД одной транзакцией (функция InsertOrder).
+Внутри транзакции данные добавляются поочерёдно: payment, items, order_get.
 Именно указанная очерёдность добавления данных - важное положение правильной работы
 логики сохранения данных в БД, основанной на работе хранимых процедур в PostgreSQL.
+Повторно доставленный заказ с тем же содержимым не сохраняется и не считается ошибкой.
+В случае ошибки транзакция откатывается, а возвращаемая ошибка (postgresql.InsertError) указывает этап сбоя.
+После фиксации нового или изменённого заказа публикует событие order.changed (функция publishOrderChanged).
 */
 
 func (app *Application) InsertAll(order models.OrderGet) (err error) {
 
-	defer Wg.Done()
-
-	errAtInsPayment := app.orderGet.InsertNewPayment(order)
-	if errAtInsPayment != nil {
-		err = errAtInsPayment
+	result, err := app.orderGet.InsertOrder(order)
+	if err != nil {
+		return err
 	}
-	errAtInsItems := app.orderGet.InsertNewItems(order)
-	if errAtInsItems != nil {
-		err = errAtInsItems
+
+	switch result.Outcome {
+	case postgresql.OutcomeDuplicate:
+		fmt.Printf("Повтор %s (версия %d) – пропущен\n", order.OrderUID, result.Version)
+	case postgresql.OutcomeUpdated:
+		fmt.Printf("Обновлён %s до версии %d\n", order.OrderUID, result.Version)
+	default:
+		fmt.Printf("Добавлен %s\n", order.OrderUID)
 	}
-	errAtInsOrder := app.orderGet.InsertNewOrder(order)
-	if errAtInsOrder != nil {
-		err = errAtInsOrder
+
+	if result.Outcome != postgresql.OutcomeDuplicate {
+		app.publishOrderChanged(order.OrderUID, result)
 	}
-	fmt.Printf("Добавлен %s\n", order.OrderUID)
-	return err
+	return nil
 }
@@ -1,38 +1,60 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"flag"
 	"log"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/lib/pq"
+	nats "github.com/nats-io/nats.go"
+	"my.service.common/cache"
+	"my.service.common/config"
+	"my.service.common/lifecycle"
+	"my.service.common/natsconn"
 	"my.service.save/pkg/models/postgresql"
-	"my.service.save/pkg/models/postgresql/cache"
 )
 
 /*
 Основная часть программы:
 1) Структура Application – основная структура программы, управляющая информацией о работе программы и ошибках
-+ управляет функциями добавления данных в БД.
++ управляет функциями добавления данных в БД. Поле subscriber – параметры подписки на NATS Streaming,
+поле rejectedOrders – счётчик заказов, не прошедших проверку, поле retries – сообщения, ожидающие повторной доставки,
+поле nc – общее долгоживущее соединение с NATS (переподключается автоматически, состояние – nc.Health()),
+поле changedSubject – subject событий order.changed о сохранённых заказах (файл orderchanged.go).
 2) Функция OpenDB – управляет подключением к БД. В качестве параметра принимает свойства для подключения к БД
 и возвращает готовое, проверенное соединение с БД.
 3) В функции main:
-	3.1) Устанавливаем счётчик WaitGroup;
+	3.1) Загружаем настройки (структура Config, файл config.go): файл настроек, переменные окружения и флаги;
 	3.2) Задаём параметры для информировании о работе приложения и об ошибках в нём (infoLog, errorLog);
-	3.3) Получаем получение к БД, создавая объект структуры OpenDB;
+	3.3) Получаем получение к БД, создавая объект структуры OpenDB, и соединение с NATS (natsconn.Connect);
 	3.4) Получаем функциональность приложения в части возможности вызова функций для добавления информации в БД,
 	создавая объект структуры  Application.
-	3.5) Запускаем саму функцию SubAndSave для сохранения данных в БД.
-	В качестве параметра передаём созданный с помощью конструктора кэш.
+	3.5) Если задан файл снимка кэша – восстанавливаем кэш и сохраняем из него заказы, которых ещё нет в БД (файл snapshot.go).
+	Если включено – запускаем администрирование кэша (функция startAdmin, файл admin.go);
+	3.6) Запускаем саму функцию SubAndSave для сохранения данных в БД. Если настройки консьюмера JetStream
+	нельзя применить к существующему консьюмеру – завершаем работу с ошибкой.
+	В качестве параметра передаём созданный с помощью конструктора кэш (тот же кэш использует DbModel).
+	3.7) При получении SIGINT/SIGTERM (контекст из lifecycle.SignalContext) функция SubAndSave завершает обработку
+	полученного пакета и возвращает управление. После этого сразу возвращаем в поток отложенные сообщения (retryNow),
+	останавливаем HTTP-сервер администрирования, закрываем соединение с NATS через Drain (подтверждения отправляются
+	серверу), записываем снимок и очищаем кэш, закрываем соединение с БД.
+	Если остановка заняла больше ShutdownTimeout – процесс завершается с ошибкой (lifecycle.Watchdog).
 */
 
 type Application struct {
-	errorLog *log.Logger
-	infoLog  *log.Logger
-	orderGet *postgresql.DbModel
+	errorLog       *log.Logger
+	infoLog        *log.Logger
+	orderGet       *postgresql.DbModel
+	subscriber     SubscriberConfig
+	rejectedOrders uint64
+	retriesMu      sync.Mutex
+	retries        map[*nats.Msg]*time.Timer
+	nc             *natsconn.Conn
+	changedSubject string
 }
 
 func OpenDB(dsn string) (*sql.DB, error) {
@@ -46,33 +68,78 @@ func OpenDB(dsn string) (*sql.DB, error) {
 	return db, nil
 }
 
-var Wg sync.WaitGroup
-
 func main() {
 
-	Wg.Add(1000)
-
-	dsn := flag.String("dsn", "user=postgres password=postgres dbname=test sslmode=disable",
-		"Название источника данных")
+	cfg := defaultConfig()
+	config.MustLoad(&cfg, os.Stdout)
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	db, err := OpenDB(*dsn)
+	db, err := OpenDB(cfg.DSN)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	nc, err := natsconn.Connect(cfg.natsOptions(infoLog, errorLog))
 	if err != nil {
 		errorLog.Fatal(err)
 	}
-	defer db.Close()
+
+	orderCache := cache.NewWithOptions(cfg.cacheOptions(errorLog))
 
 	app := &Application{
 		errorLog: errorLog,
 		infoLog:  infoLog,
-		orderGet: &postgresql.DbModel{DB: db},
+		orderGet: &postgresql.DbModel{
+			DB:         db,
+			OnConflict: cfg.OnConflict,
+			OrderCache: orderCache,
+			CacheTTL:   cfg.CacheTTL,
+		},
+		subscriber:     cfg.subscriberConfig(),
+		nc:             nc,
+		changedSubject: cfg.OrderChangedSubject,
+	}
+
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
+	lifecycle.Watchdog(ctx, cfg.ShutdownTimeout, func() {
+		errorLog.Fatalf("Остановка не завершена за %s", cfg.ShutdownTimeout)
+	})
+
+	if cfg.CacheSnapshot != "" {
+		app.restoreCache(ctx, orderCache, cfg.CacheSnapshot)
+	}
+
+	shutdownAdmin, err := app.startAdmin(cfg, orderCache)
+	if err != nil {
+		errorLog.Fatal(err)
 	}
 
 	infoLog.Println("Запуск сервера приложения. Получение и обработка новых заказов.")
 
-	for {
-		app.SubAndSave(cache.NewCacheOrderGet(5*time.Minute, 10*time.Minute))
+	for ctx.Err() == nil {
+		if err := app.SubAndSave(ctx, orderCache); err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+	stop()
+
+	app.retryNow()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownAdmin(shutdownCtx); err != nil {
+		errorLog.Println(err)
+	}
+	if err := nc.Drain(); err != nil {
+		errorLog.Println(err)
+	}
+
+	infoLog.Printf("Остановка: из кэша удалено заказов – %d, отклонено за время работы – %d",
+		orderCache.Close(), atomic.LoadUint64(&app.rejectedOrders))
+	if err := db.Close(); err != nil {
+		errorLog.Println(err)
 	}
+	infoLog.Println("Сервер приложения остановлен.")
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+
+	"my.service.save/pkg/models"
+	"my.service.save/pkg/models/postgresql"
+)
+
+/*
+Функция publishOrderChanged принимает в качестве аргументов ID заказа и результат его сохранения (postgresql.InsertResult).
+Вызывается после фиксации транзакции и публикует в NATS (subject из настройки OrderChangedSubject) событие
+типа models.OrderChanged. Микросервис query по этому событию пересчитывает строку order_post,
+обновляет заказ в кэше и удаляет ID из кэша ненайденных заказов.
+Если subject не задан – ничего не делает. Ошибка публикации только логируется: заказ уже сохранён в БД,
+а кэши query в худшем случае обновятся по истечении срока хранения. Во время переподключения к NATS
+событие остаётся в буфере публикаций (nats_reconnect_buf) и отправляется после переподключения.
+*/
+
+func (app *Application) publishOrderChanged(orderUID string, result postgresql.InsertResult) {
+
+	if app.changedSubject == "" {
+		return
+	}
+
+	data, err := json.Marshal(models.OrderChanged{
+		OrderUID: orderUID,
+		Version:  result.Version,
+		Outcome:  result.Outcome,
+	})
+	if err != nil {
+		app.errorLog.Println(err)
+		return
+	}
+
+	if err := app.nc.Publish(app.changedSubject, data); err != nil {
+		app.errorLog.Printf("Событие %s для %s не опубликовано: %v", app.changedSubject, orderUID, err)
+	}
+}
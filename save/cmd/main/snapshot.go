@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+
+	"my.service.save/pkg/models"
+	"my.service.save/pkg/models/postgresql"
+)
+
+/*
+Функция restoreCache вызывается при запуске, если задан файл снимка кэша (настройка cache_snapshot).
+Кэш – источник повторного сохранения заказа, когда запись в Postgres не удалась (функция processOrder),
+поэтому без снимка заказы, полученные во время недоступности БД, терялись бы при аварийном перезапуске.
+Порядок работы функции:
+1) Восстанавливает кэш из файла (LoadFile). Заказы с истекшим сроком хранения (CacheTTL) пропускаются,
+повреждённый снимок не загружается (ошибка в errorLog, микросервис работает с пустым кэшем);
+2) Сохраняет в БД только те восстановленные заказы, которых в БД ещё нет (RestoreOrder): снимок может быть
+старше БД, поэтому уже сохранённый заказ не изменяется и новая версия из снимка не создаётся, даже при политике
+on_conflict = version. О сохранённых заказах публикуется событие order.changed. Ошибки выводятся в errorLog
+и не останавливают восстановление.
+Прерывается по ctx (SIGINT/SIGTERM).
+*/
+
+func (app *Application) restoreCache(ctx context.Context, orderCache *postgresql.OrderCache, path string) {
+
+	restored, expired, err := orderCache.LoadFile(path)
+	if err != nil {
+		app.errorLog.Printf("Снимок кэша %s не восстановлен: %v", path, err)
+		return
+	}
+	app.infoLog.Printf("Снимок кэша %s: восстановлено заказов – %d, пропущено устаревших – %d", path, restored, expired)
+	if restored == 0 {
+		return
+	}
+
+	var saved, skipped, failed int
+	orderCache.Range(func(uid string, order models.OrderGet) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		result, err := app.orderGet.RestoreOrder(order)
+		if err != nil {
+			app.errorLog.Printf("Заказ %s из снимка кэша не сохранён: %v", uid, err)
+			failed++
+			return true
+		}
+		if result.Outcome == postgresql.OutcomeSkipped {
+			skipped++
+			return true
+		}
+		app.publishOrderChanged(uid, result)
+		saved++
+		return true
+	})
+	app.infoLog.Printf("Заказы из снимка кэша: сохранено – %d, уже в БД – %d, с ошибкой – %d", saved, skipped, failed)
+}
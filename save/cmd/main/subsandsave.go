@@ -1,88 +1,368 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
-	stan "github.com/nats-io/stan.go"
+	nats "github.com/nats-io/nats.go"
 	"my.service.save/pkg/models"
-	"my.service.save/pkg/models/postgresql/cache"
+	"my.service.save/pkg/models/postgresql"
 )
 
 /*
-Функция SubAndSave принимает в качестве аргумента разыменновынный адрес области памяти с in memory кэшем.
+Структура SubscriberConfig – параметры получения заказов из NATS JetStream:
+1) Stream и Subject – поток JetStream и subject, в который публикуются новые заказы;
+2) DurableName – имя durable pull-консьюмера. Все экземпляры «save» с одним DurableName делят сообщения между собой:
+каждое сообщение получает только один экземпляр, а после перезапуска доставка продолжается
+с первого неподтверждённого сообщения;
+3) AckWait – время, через которое неподтверждённое сообщение будет доставлено повторно;
+4) MaxAckPending – максимальное количество доставленных, но ещё не подтверждённых сообщений;
+5) MaxDeliver – максимальное количество попыток доставки одного сообщения;
+6) BatchSize и FetchWait – размер пакета и время ожидания одного запроса Fetch;
+7) Backoff – задержка перед первой повторной доставкой. Каждая следующая – в 2 раза больше, но меньше AckWait;
+8) CacheTTL – время хранения полученного заказа в in memory кэше.
+Значения задаются в настройках микросервиса (структура Config).
+
+Функция SubAndSave принимает в качестве аргументов контекст (отменяется при остановке микросервиса)
+и разыменновынный адрес области памяти с in memory кэшем.
 Этот кэш будет использован, в случае сбоя при добавлении данных в БД.
 Сама функция выполняет следующие действия:
-1) Подключается к Nats Streaming в качестве слушателя и ассинхронно принимает от него сообщения
-в виде среза byte или JSON-нотации;
-2) Переводит срез байт в строку и проверяет наличие всех обязательных атрибутов структуры models.OrderGet.
-В случае отсутствия хотя бы одного параметра, выходит из метода.
-3) «Демаршалирует» полученные данные из JSON в структуру типа models.OrderGet;
-4) Сохраняет эту структуру в кэш;
-5) Вызывает фнукцию InsertAll, передавая в качестве параметра демаршалированный из JSON объект типа models.OrderGet.
-В случае в работе функции InsertAll, вызывает её повторно, но уже отправляя данные из кэша.
-В случае сбоя этого варианта – выходит из программы с log.Fatal.
+1) Использует общее соединение с NATS (поле nc структуры Application, создаётся в main). Если соединения нет
+(сервер недоступен, идёт переподключение) – ждёт FetchWait и возвращает управление.
+Создаёт поток и консьюмер, если их ещё нет (функция provisionJetStream). Если консьюмер уже есть, а ack_wait,
+max_deliver или max_ack_pending в настройках изменились – обновляет его (изменения пишутся в infoLog).
+Если отличаются поля, которые нельзя изменить у существующего консьюмера (order_subject, политики доставки
+и подтверждения), или сервер отклонил обновление – возвращает ошибку errConsumerConfig, и main завершает работу:
+иначе новые настройки молча не действовали бы. Backoff консьюмеру не передаётся – задержку выдерживает сам save (retryLater);
+2) Пакетами (Fetch) получает сообщения в виде среза byte или JSON-нотации;
+3) Каждое сообщение обрабатывается функцией processOrder;
+4) Подтверждение (Ack) отправляется только если processOrder вернула true. Иначе – сообщение возвращается
+в поток с задержкой (функция retryLater), поэтому падение процесса или сбой БД не приводят к потере заказов.
+Возвращает управление при ошибке (nil) – main вызовет функцию заново, соединение при этом не пересоздаётся:
+переподключение к NATS выполняется в фоне (пакет my.service.common/natsconn).
+При отмене контекста: новые пакеты не запрашиваются, уже полученный пакет обрабатывается до конца (заказы сохраняются в БД).
+Отложенные повторные попытки (retryNow) и закрытие соединения через Drain выполняет main.
+
+Функция processOrder принимает в качестве аргументов сообщение и кэш. Возвращает true, если сообщение
+обработано окончательно и его можно подтвердить, и причину отказа (ошибку разбора или сохранения), если сообщение
+нужно доставить повторно:
+1) Проверяет и «демаршалирует» полученные данные из JSON в структуру типа models.OrderGet (функция models.ParseOrderGet).
+В случае ошибок – логирует каждую ошибку поля, увеличивает счётчик отклонённых заказов и отправляет сообщение
+в очередь «мёртвых» сообщений (sendToDeadLetter);
+2) Сохраняет проверенный объект в кэш;
+3) Вызывает фнукцию InsertAll, передавая в качестве параметра проверенный объект типа models.OrderGet.
+Успешная фиксация транзакции или повтор уже сохранённого заказа – сообщение подтверждается.
+Если заказ с таким order_uid уже сохранён с другим содержимым и политика -on-conflict=reject –
+отправляет сообщение в очередь «мёртвых» сообщений без повторной попытки.
+В случае сбоя в работе функции InsertAll, вызывает её повторно, но уже отправляя данные из кэша.
+В случае сбоя этого варианта (postgresql.InsertError, например БД недоступна) – сообщение не подтверждается
+и доставляется повторно с задержкой (retryLater); в очередь «мёртвых» сообщений оно попадает, только когда
+попытки доставки (MaxDeliver) исчерпаны.
+ВАЖНО: сразу в очередь «мёртвых» сообщений отправляются только некорректные заказы и конфликты содержимого.
+Такое сообщение подтверждается, только если запись в dead_letter сохранена. Если БД недоступна, сообщение
+будет доставлено повторно.
+
+Функция retryLater принимает в качестве аргументов сообщение, которое не удалось обработать, и причину отказа.
+Если попытки доставки (MaxDeliver) исчерпаны – сохраняет сообщение в dead_letter (subject, номер
+в потоке, последняя ошибка и исходные данные; sendToDeadLetter) и завершает его доставку (Term): такое сообщение
+можно отправить повторно командой cmd/deadletter. Если БД по-прежнему недоступна – сообщение целиком выводится в errorLog.
+Иначе – возвращает сообщение в поток (Nak) с задержкой Backoff * 2^(номер попытки - 1).
+Отложенные сообщения хранятся в поле retries структуры Application.
+
+Функция retryNow вызывается при остановке: отменяет задержку и сразу возвращает в поток все отложенные сообщения,
+чтобы их получил другой экземпляр «save», не дожидаясь AckWait.
 */
 
-func (app *Application) SubAndSave(inMemoryCache *cache.CacheOrderGet) {
+type SubscriberConfig struct {
+	Stream        string
+	Subject       string
+	DurableName   string
+	AckWait       time.Duration
+	MaxAckPending int
+	MaxDeliver    int
+	BatchSize     int
+	FetchWait     time.Duration
+	Backoff       time.Duration
+	CacheTTL      time.Duration
+}
 
-	var order models.OrderGet
+func (app *Application) SubAndSave(ctx context.Context, inMemoryCache *postgresql.OrderCache) error {
 
-	sc, err := stan.Connect("world-nats-stage", "SK", stan.NatsURL("wbx-world-nats-stage.dp.wb.ru"))
+	if !app.nc.Healthy() {
+		sleep(ctx, app.subscriber.FetchWait)
+		return nil
+	}
+
+	js, err := app.nc.JetStream()
 	if err != nil {
-		log.Fatal(err)
+		app.errorLog.Println(err)
+		sleep(ctx, app.subscriber.FetchWait)
+		return nil
 	}
-	defer sc.Close()
 
-	wg := sync.WaitGroup{}
+	if err := app.provisionJetStream(js); err != nil {
+		if errors.Is(err, errConsumerConfig) {
+			return err
+		}
+		app.errorLog.Println(err)
+		sleep(ctx, app.subscriber.FetchWait)
+		return nil
+	}
 
-	wg.Add(1000)
+	sub, err := js.PullSubscribe(app.subscriber.Subject, app.subscriber.DurableName, nats.BindStream(app.subscriber.Stream))
+	if err != nil {
+		app.errorLog.Println(err)
+		sleep(ctx, app.subscriber.FetchWait)
+		return nil
+	}
+	// Drain, а не Unsubscribe: в nats.go Unsubscribe удаляет durable-консьюмер вместе с позицией в потоке.
+	defer sub.Drain()
 
-	if _, err := sc.Subscribe("go.test", func(m *stan.Msg) {
+	for ctx.Err() == nil {
+		fetchCtx, cancel := context.WithTimeout(ctx, app.subscriber.FetchWait)
+		msgs, err := sub.Fetch(app.subscriber.BatchSize, nats.Context(fetchCtx))
+		cancel()
 
-		conOrdeID := strings.Contains(string(m.Data), "order_uid")
-		conEntry := strings.Contains(string(m.Data), "entry")
-		conInSig := strings.Contains(string(m.Data), "internal_signature")
-		conPayment := strings.Contains(string(m.Data), "payment")
-		conItems := strings.Contains(string(m.Data), "items")
-		conLocale := strings.Contains(string(m.Data), "locale")
-		conCustID := strings.Contains(string(m.Data), "customer_id")
-		conTNum := strings.Contains(string(m.Data), "track_number")
-		conDService := strings.Contains(string(m.Data), "delivery_service")
-		conShKey := strings.Contains(string(m.Data), "shardkey")
+		// Сообщения, полученные до сигнала остановки, обрабатываются до конца.
+		for _, m := range msgs {
+			if ack, reason := app.processOrder(m, inMemoryCache); !ack {
+				app.retryLater(m, reason)
+				continue
+			}
+			if err := m.Ack(); err != nil {
+				app.errorLog.Printf("Сообщение %s не подтверждено: %v", m.Subject, err)
+			}
+		}
 
-		if !conOrdeID || !conEntry || !conInSig || !conPayment || !conItems || !conLocale || !conCustID || !conTNum ||
-			!conDService || !conShKey {
-			fmt.Printf("Не добавлен %s\n", order.OrderUID)
-			return
+		if err != nil && ctx.Err() == nil && !errors.Is(err, nats.ErrTimeout) && !errors.Is(err, context.DeadlineExceeded) {
+			app.errorLog.Println(err)
+			sleep(ctx, app.subscriber.FetchWait)
+			return nil
 		}
+	}
+	return nil
+}
 
-		err := json.Unmarshal(m.Data, &order)
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+func (app *Application) provisionJetStream(js nats.JetStreamContext) error {
 
+	if _, err := js.StreamInfo(app.subscriber.Stream); err != nil {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     app.subscriber.Stream,
+			Subjects: []string{app.subscriber.Subject},
+			Storage:  nats.FileStorage,
+		})
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		app.infoLog.Printf("Создан поток JetStream %s (%s)", app.subscriber.Stream, app.subscriber.Subject)
+	}
 
-		inMemoryCache.SetCacheOrderGet(order.OrderUID, order, 5*time.Minute)
-		errInsert := app.InsertAll(order)
-		if errInsert != nil {
-			errCache := app.InsertAll(inMemoryCache.GetCacheOrderGet(order.OrderUID))
-			if errCache != nil {
-				log.Fatal(errCache)
-			}
-			log.Fatal(err)
+	want := app.consumerConfig()
+	info, err := js.ConsumerInfo(app.subscriber.Stream, app.subscriber.DurableName)
+	if err != nil {
+		if _, err := js.AddConsumer(app.subscriber.Stream, want); err != nil {
+			return err
 		}
+		app.infoLog.Printf("Создан консьюмер JetStream %s", app.subscriber.DurableName)
+		return nil
+	}
 
-		defer wg.Done()
+	if err := consumerFixed(info.Config, *want); err != nil {
+		return fmt.Errorf("%w %s: %v; удалите консьюмер (nats consumer rm %s %s) или задайте другой durable",
+			errConsumerConfig, app.subscriber.DurableName, err, app.subscriber.Stream, app.subscriber.DurableName)
+	}
+	changes := consumerChanges(info.Config, *want)
+	if len(changes) == 0 {
+		return nil
+	}
+	// Повторный AddConsumer с тем же durable обновляет изменяемые поля консьюмера (NATS Server 2.7+).
+	if _, err := js.AddConsumer(app.subscriber.Stream, want); err != nil {
+		if transientJetStreamError(err) {
+			return err
+		}
+		return fmt.Errorf("%w %s (%s): %v", errConsumerConfig, app.subscriber.DurableName, strings.Join(changes, ", "), err)
+	}
+	app.infoLog.Printf("Обновлён консьюмер JetStream %s: %s", app.subscriber.DurableName, strings.Join(changes, ", "))
+	return nil
+}
 
-	}); err != nil {
-		log.Fatal(err)
+// errConsumerConfig – настройки существующего консьюмера расходятся с настройками save и не могут быть применены.
+var errConsumerConfig = errors.New("настройки не применены к консьюмеру JetStream")
+
+func (app *Application) consumerConfig() *nats.ConsumerConfig {
+	return &nats.ConsumerConfig{
+		Durable:       app.subscriber.DurableName,
+		DeliverPolicy: nats.DeliverAllPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       app.subscriber.AckWait,
+		MaxDeliver:    app.subscriber.MaxDeliver,
+		MaxAckPending: app.subscriber.MaxAckPending,
+		FilterSubject: app.subscriber.Subject,
 	}
+}
+
+// consumerFixed сравнивает поля, которые нельзя изменить у существующего консьюмера.
+func consumerFixed(have, want nats.ConsumerConfig) error {
+	switch {
+	case have.DeliverSubject != "":
+		return fmt.Errorf("консьюмер push (deliver_subject %s), нужен pull", have.DeliverSubject)
+	case have.FilterSubject != want.FilterSubject:
+		return fmt.Errorf("filter_subject %q, в настройках order_subject %q", have.FilterSubject, want.FilterSubject)
+	case have.AckPolicy != want.AckPolicy:
+		return fmt.Errorf("ack_policy %v, нужна %v", have.AckPolicy, want.AckPolicy)
+	case have.DeliverPolicy != want.DeliverPolicy:
+		return fmt.Errorf("deliver_policy %v, нужна %v", have.DeliverPolicy, want.DeliverPolicy)
+	}
+	return nil
+}
+
+// consumerChanges возвращает изменяемые поля консьюмера, отличающиеся от настроек, в виде «поле было → стало».
+func consumerChanges(have, want nats.ConsumerConfig) (changes []string) {
+	if have.AckWait != want.AckWait {
+		changes = append(changes, fmt.Sprintf("ack_wait %s → %s", have.AckWait, want.AckWait))
+	}
+	if unlimited(have.MaxDeliver) != unlimited(want.MaxDeliver) {
+		changes = append(changes, fmt.Sprintf("max_deliver %d → %d", have.MaxDeliver, want.MaxDeliver))
+	}
+	if have.MaxAckPending != want.MaxAckPending {
+		changes = append(changes, fmt.Sprintf("max_ack_pending %d → %d", have.MaxAckPending, want.MaxAckPending))
+	}
+	return changes
+}
+
+// unlimited приводит «без ограничения» к одному значению: 0 в настройках сервер хранит как -1.
+func unlimited(n int) int {
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
+
+// transientJetStreamError – запрос к JetStream не получил ответа сервера: его повторяет следующий вызов SubAndSave.
+func transientJetStreamError(err error) bool {
+	return errors.Is(err, nats.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, nats.ErrConnectionClosed) || errors.Is(err, nats.ErrJetStreamNotEnabled)
+}
 
-	wg.Wait()
+func (app *Application) processOrder(m *nats.Msg, inMemoryCache *postgresql.OrderCache) (ack bool, reason error) {
 
+	order, err := models.ParseOrderGet(m.Data)
+	if err != nil {
+		app.rejectOrder(order.OrderUID, err)
+		return app.sendToDeadLetter(m, err) == nil, err
+	}
+
+	inMemoryCache.Set(order.OrderUID, order, app.subscriber.CacheTTL)
+	errInsert := app.InsertAll(order)
+	if errInsert == nil {
+		return true, nil
+	}
+	app.errorLog.Println(errInsert)
+	if errors.Is(errInsert, postgresql.ErrOrderConflict) {
+		return app.sendToDeadLetter(m, errInsert) == nil, errInsert
+	}
+
+	cached, ok := inMemoryCache.Get(order.OrderUID)
+	if !ok {
+		// Заказ уже вытеснен из кэша (cache_max_entries, cache_max_bytes) – повторяем с проверенным объектом.
+		cached = order
+	}
+	errCache := app.InsertAll(cached)
+	if errCache == nil {
+		return true, nil
+	}
+	if errors.Is(errCache, postgresql.ErrOrderConflict) {
+		return app.sendToDeadLetter(m, errCache) == nil, errCache
+	}
+	// Сбой БД (postgresql.InsertError) – сообщение не подтверждается и доставляется повторно (retryLater).
+	return false, errCache
+}
+
+func (app *Application) retryLater(m *nats.Msg, reason error) {
+
+	meta, err := m.Metadata()
+	if err != nil {
+		app.errorLog.Println(err)
+		return
+	}
+
+	if app.subscriber.MaxDeliver > 0 && meta.NumDelivered >= uint64(app.subscriber.MaxDeliver) {
+		// При неудаче записи в dead_letter sendToDeadLetter выводит сообщение целиком в errorLog.
+		app.sendToDeadLetter(m, fmt.Errorf("попытки доставки исчерпаны (%d): %w", meta.NumDelivered, reason))
+		if err := m.Term(); err != nil {
+			app.errorLog.Println(err)
+		}
+		return
+	}
+
+	delay := app.subscriber.Backoff
+	for i := uint64(1); i < meta.NumDelivered && delay < app.subscriber.AckWait; i++ {
+		delay *= 2
+	}
+	if limit := app.subscriber.AckWait - time.Second; delay > limit {
+		delay = limit
+	}
+
+	app.retriesMu.Lock()
+	defer app.retriesMu.Unlock()
+
+	if app.retries == nil {
+		app.retries = make(map[*nats.Msg]*time.Timer)
+	}
+	app.retries[m] = time.AfterFunc(delay, func() {
+		app.retriesMu.Lock()
+		delete(app.retries, m)
+		app.retriesMu.Unlock()
+
+		if err := m.Nak(); err != nil {
+			app.errorLog.Println(err)
+		}
+	})
+}
+
+func (app *Application) retryNow() {
+
+	app.retriesMu.Lock()
+	defer app.retriesMu.Unlock()
+
+	for m, timer := range app.retries {
+		if timer.Stop() {
+			if err := m.Nak(); err != nil {
+				app.errorLog.Println(err)
+			}
+		}
+		delete(app.retries, m)
+	}
+}
+
+/*
+Функция rejectOrder принимает в качестве аргументов ID заказа (может быть пустым) и ошибку проверки.
+Логирует каждую ошибку поля из models.ValidationErrors и увеличивает счётчик отклонённых заказов.
+*/
+
+func (app *Application) rejectOrder(orderUID string, err error) {
+
+	rejected := atomic.AddUint64(&app.rejectedOrders, 1)
+
+	var fieldErrors models.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		app.errorLog.Printf("Не добавлен %s: %v (всего отклонено: %d)", orderUID, err, rejected)
+		return
+	}
+
+	for _, fieldError := range fieldErrors {
+		app.errorLog.Printf("Не добавлен %s: %s", orderUID, fieldError)
+	}
+	app.errorLog.Printf("Не добавлен %s: ошибок в полях – %d (всего отклонено: %d)", orderUID, len(fieldErrors), rejected)
 }
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 /*
 Модели данных:
 1) OrderGet – структура, инкапсулирующая сведения о данных поступившего заказа.
@@ -10,6 +12,11 @@ package models
 Ключевой параметр: OrderUID.
 ВАЖНО: благодаря внедрению ключевого параметра OrderUID в дальнейшем возможно идентифицировать заказ,
 набор товаров из него, а так же способ и порядок оплаты. Это упрощает идентификацию данных, ускоряет их обработку.
+4) DeadLetter – структура, инкапсулирующая сведения о сообщении, которое не удалось разобрать или сохранить в БД:
+исходные данные (Payload), причина, subject и номер сообщения в канале, дата попадания в очередь и дата повторной отправки.
+5) OrderChanged – событие «заказ сохранён или изменён», которое save публикует после фиксации транзакции:
+ID заказа, номер версии и результат сохранения (inserted или updated). Микросервис query по этому событию
+обновляет свои кэши.
 */
 
 type OrderGet struct {
@@ -50,3 +57,19 @@ type Items struct {
 	NmID       int    `json:"nm_id"`
 	Brand      string `json:"brand"`
 }
+
+type DeadLetter struct {
+	ID         int64      `json:"id"`
+	Subject    string     `json:"subject"`
+	Sequence   uint64     `json:"sequence"`
+	Reason     string     `json:"reason"`
+	Payload    []byte     `json:"payload"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"`
+}
+
+type OrderChanged struct {
+	OrderUID string `json:"order_uid"`
+	Version  int    `json:"version"`
+	Outcome  string `json:"outcome"`
+}
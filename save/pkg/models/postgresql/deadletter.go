@@ -0,0 +1,81 @@
+package postgresql
+
+import (
+	"database/sql"
+
+	"my.service.save/pkg/models"
+)
+
+/*
+Функции для работы с очередью «мёртвых» сообщений (dead letter queue).
+Таблица: dead_letter, модель: DeadLetter.
+
+1) Функция InsertDeadLetter принимает в качестве аргумента объект типа DeadLetter (исходное сообщение,
+причина, subject и номер сообщения) и сохраняет его в БД с помощью хранимой процедуры insertdeadletter.
+Возвращает ID созданной записи;
+2) Функция GetDeadLetters принимает в качестве аргументов максимальное количество записей и признак
+«показывать уже отправленные повторно». Возвращает записи, начиная с самых новых;
+3) Функция GetDeadLetter принимает в качестве аргумента ID записи и возвращает её вместе с исходным сообщением.
+Если записи нет – возвращает sql.ErrNoRows;
+4) Функция MarkDeadLetterReplayed принимает в качестве аргумента ID записи и отмечает время её повторной отправки.
+*/
+
+func (m *DbModel) InsertDeadLetter(letter models.DeadLetter) (id int64, err error) {
+
+	stmt := "SELECT insertdeadletter ($1, $2, $3, $4)"
+
+	err = m.DB.QueryRow(stmt, letter.Subject, int64(letter.Sequence), letter.Reason, letter.Payload).Scan(&id)
+	return id, err
+}
+
+func (m *DbModel) GetDeadLetters(limit int, withReplayed bool) ([]models.DeadLetter, error) {
+
+	query := `SELECT id, subject, sequence, reason, created_at, replayed_at FROM dead_letter
+		WHERE $2 OR replayed_at IS NULL ORDER BY id DESC LIMIT $1`
+
+	rows, err := m.DB.Query(query, limit, withReplayed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []models.DeadLetter
+	for rows.Next() {
+		var letter models.DeadLetter
+		var sequence int64
+		var replayedAt sql.NullTime
+		if err := rows.Scan(&letter.ID, &letter.Subject, &sequence, &letter.Reason, &letter.CreatedAt, &replayedAt); err != nil {
+			return nil, err
+		}
+		letter.Sequence = uint64(sequence)
+		if replayedAt.Valid {
+			letter.ReplayedAt = &replayedAt.Time
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+func (m *DbModel) GetDeadLetter(id int64) (letter models.DeadLetter, err error) {
+
+	query := "SELECT id, subject, sequence, reason, payload, created_at, replayed_at FROM dead_letter WHERE id = $1"
+
+	var sequence int64
+	var replayedAt sql.NullTime
+	err = m.DB.QueryRow(query, id).Scan(&letter.ID, &letter.Subject, &sequence, &letter.Reason, &letter.Payload,
+		&letter.CreatedAt, &replayedAt)
+	if err != nil {
+		return letter, err
+	}
+	letter.Sequence = uint64(sequence)
+	if replayedAt.Valid {
+		letter.ReplayedAt = &replayedAt.Time
+	}
+	return letter, nil
+}
+
+func (m *DbModel) MarkDeadLetterReplayed(id int64) error {
+
+	_, err := m.DB.Exec("UPDATE dead_letter SET replayed_at = now() WHERE id = $1", id)
+	return err
+}
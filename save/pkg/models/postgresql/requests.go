@@ -2,27 +2,35 @@ package postgresql
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
 	"sync"
 	"time"
 
+	"my.service.common/cache"
 	"my.service.save/pkg/models"
-	"my.service.save/pkg/models/postgresql/cache"
 )
 
 /*
 Индивидуальные функции, использующиеся для добавления данных в каждую таблицу SQL.
 
-1) Функция InsertNewPayment принимает в качестве аргумента канал типа OrderGet,
+1) Функция InsertOrder принимает в качестве аргумента объект типа OrderGet и сохраняет его в БД
+в одной транзакции (sql.Tx): проверяет, не сохранён ли уже этот заказ (подробнее – в файле version.go),
+поочерёдно вызывает функции п. 2 – 4, записывает новую версию заказа и фиксирует транзакцию.
+Возвращает результат типа InsertResult: inserted, duplicate (повтор, в БД ничего не изменилось) или updated.
+Именно указанная очерёдность – важное положение правильной работы хранимых процедур.
+В случае ошибки на любом этапе – откатывает транзакцию целиком (в БД не остаётся «осиротевших» строк payment или items)
+и возвращает ошибку типа InsertError с названием этапа (Stage): begin, version, payment, items, order_get или commit.
+После успешной фиксации записывает заказ в кэш OrderCache на время CacheTTL (если кэш задан).
+Функция RestoreOrder – то же для заказа из снимка кэша, но только если заказа ещё нет в order_version:
+снимок мог устареть, поэтому уже сохранённый заказ не изменяется и новая версия не создаётся (OutcomeSkipped).
+2) Функция InsertNewPayment принимает в качестве аргументов транзакцию и объект типа OrderGet,
 осуществляет переборку данных, отбирая необходимые значения полей и вносит данные в БД.
 Таблица: payment, модель: Payment.
-2) Функция InsertNewItems принимает в качестве аргумента канал типа OrderGet,
+3) Функция InsertNewItems принимает в качестве аргументов транзакцию и объект типа OrderGet,
 осуществляет переборку данных, отбирая необходимые значения полей и вносит данные в БД.
 Таблица: items, модель: Items.
-3) Функция InsertNewOrder принимает в качестве аргумента канал типа OrderGet,
+4) Функция InsertNewOrder принимает в качестве аргументов транзакцию и объект типа OrderGet,
 осуществляет переборку данных, отбирая необходимые значения полей и вносит данные в БД.
-Функция записывает данные в кэш и в случае сбоя передачи, организует новое соединение в БД
-и вносит данные уже из кэша.
 Таблица: order_get, модель: OrderGet.
 
 ВАЖНО: вся работа по добавлению данных в SQL осуществляется на стороне БД посредством хранимых процедур.
@@ -39,23 +47,158 @@ import (
 ВАЖНО: на строне БД, указанная функцию делает выборку из таблиц items и payment по общему OrderUID
 и добавляет данные в разделы payment и items таблицы order_get.
 Таким образом минимизируем передачу составных данных и срезов, они обрабатываются на стороне БД.
+Хранимые процедуры не управляют транзакциями сами, поэтому выполняются внутри транзакции функции InsertOrder
+и видят ещё не зафиксированные строки payment и items.
 
 Подробный код хранимых процедур и скрипты таблиц в файле: scripts.sql.
 */
 
+// OrderCache – кэш сохранённых заказов (ключ – order_uid), общая реализация из пакета my.service.common/cache.
+type OrderCache = cache.Cache[string, models.OrderGet]
+
 type DbModel struct {
-	DB *sql.DB
+	DB         *sql.DB
+	OnConflict string
+	OrderCache *OrderCache
+	CacheTTL   time.Duration
 	sync.RWMutex
-	sync.WaitGroup
 }
 
-var OrderCache = cache.NewCacheOrderGet(5*time.Minute, 10*time.Minute)
+const (
+	StageBegin   = "begin"
+	StageVersion = "version"
+	StagePayment = "payment"
+	StageItems   = "items"
+	StageOrder   = "order_get"
+	StageCommit  = "commit"
+)
+
+type InsertError struct {
+	OrderUID string
+	Stage    string
+	Err      error
+}
+
+func (e *InsertError) Error() string {
+	return fmt.Sprintf("заказ %s не сохранён, этап %s: %v", e.OrderUID, e.Stage, e.Err)
+}
 
-func (m *DbModel) InsertNewPayment(order models.OrderGet) error {
+func (e *InsertError) Unwrap() error {
+	return e.Err
+}
+
+func (m *DbModel) InsertOrder(order models.OrderGet) (InsertResult, error) {
+	return m.insertOrder(order, false)
+}
+
+func (m *DbModel) RestoreOrder(order models.OrderGet) (InsertResult, error) {
+	return m.insertOrder(order, true)
+}
+
+func (m *DbModel) insertOrder(order models.OrderGet, restore bool) (result InsertResult, err error) {
 
 	m.RLock()
 	defer m.RUnlock()
 
+	payload, hash, err := orderHash(order)
+	if err != nil {
+		return result, &InsertError{OrderUID: order.OrderUID, Stage: StageVersion, Err: err}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return result, &InsertError{OrderUID: order.OrderUID, Stage: StageBegin, Err: err}
+	}
+
+	rollback := func(stage string, err error) (InsertResult, error) {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			err = fmt.Errorf("%v (откат транзакции: %v)", err, errRollback)
+		}
+		return result, &InsertError{OrderUID: order.OrderUID, Stage: stage, Err: err}
+	}
+
+	if err := lockOrder(tx, order.OrderUID); err != nil {
+		return rollback(StageVersion, err)
+	}
+
+	version, lastHash, err := lastOrderVersion(tx, order.OrderUID)
+	if err != nil {
+		return rollback(StageVersion, err)
+	}
+
+	if restore && version > 0 {
+		if err := tx.Rollback(); err != nil {
+			return result, &InsertError{OrderUID: order.OrderUID, Stage: StageVersion, Err: err}
+		}
+		return InsertResult{Outcome: OutcomeSkipped, Version: version}, nil
+	}
+
+	result = InsertResult{Outcome: OutcomeInserted, Version: version + 1}
+
+	if version > 0 && lastHash == "" {
+		same, err := storedOrderMatches(tx, order)
+		if err != nil {
+			return rollback(StageVersion, err)
+		}
+		if same {
+			if err := adoptOrderVersion(tx, order.OrderUID, version, hash, payload); err != nil {
+				return rollback(StageVersion, err)
+			}
+			if err := tx.Commit(); err != nil {
+				return result, &InsertError{OrderUID: order.OrderUID, Stage: StageCommit, Err: err}
+			}
+			return InsertResult{Outcome: OutcomeDuplicate, Version: version}, nil
+		}
+	}
+
+	if version > 0 {
+		if lastHash == hash {
+			if err := tx.Rollback(); err != nil {
+				return result, &InsertError{OrderUID: order.OrderUID, Stage: StageVersion, Err: err}
+			}
+			return InsertResult{Outcome: OutcomeDuplicate, Version: version}, nil
+		}
+		if m.OnConflict != ConflictVersion {
+			return rollback(StageVersion, ErrOrderConflict)
+		}
+		if err := deleteOrder(tx, order.OrderUID); err != nil {
+			return rollback(StageVersion, err)
+		}
+		result.Outcome = OutcomeUpdated
+	}
+
+	stages := []struct {
+		name   string
+		insert func(*sql.Tx, models.OrderGet) error
+	}{
+		{StagePayment, m.InsertNewPayment},
+		{StageItems, m.InsertNewItems},
+		{StageOrder, m.InsertNewOrder},
+	}
+
+	for _, stage := range stages {
+		if err := stage.insert(tx, order); err != nil {
+			return rollback(stage.name, err)
+		}
+	}
+
+	if err := insertOrderVersion(tx, order.OrderUID, result.Version, hash, payload); err != nil {
+		return rollback(StageVersion, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, &InsertError{OrderUID: order.OrderUID, Stage: StageCommit, Err: err}
+	}
+
+	if m.OrderCache != nil {
+		m.OrderCache.Set(order.OrderUID, order, m.CacheTTL)
+	}
+
+	return result, nil
+}
+
+func (m *DbModel) InsertNewPayment(tx *sql.Tx, order models.OrderGet) error {
+
 	payment := models.Payment{
 		OrderUID:     order.OrderUID,
 		Transaction:  order.Payment.Transaction,
@@ -70,17 +213,14 @@ func (m *DbModel) InsertNewPayment(order models.OrderGet) error {
 
 	stmt := "SELECT insertnewpayment ($1, $2, $3, $4, $5, $6, $7, $8)"
 
-	_, err := m.DB.Exec(stmt, payment.OrderUID, payment.Transaction, payment.Currency, payment.Provider, payment.Amount, payment.PaymentDt, payment.Bank, payment.DeliveryCost)
+	_, err := tx.Exec(stmt, payment.OrderUID, payment.Transaction, payment.Currency, payment.Provider, payment.Amount, payment.PaymentDt, payment.Bank, payment.DeliveryCost)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (m *DbModel) InsertNewItems(order models.OrderGet) error {
-
-	m.RLock()
-	defer m.RUnlock()
+func (m *DbModel) InsertNewItems(tx *sql.Tx, order models.OrderGet) error {
 
 	items := order.Items
 
@@ -100,7 +240,7 @@ func (m *DbModel) InsertNewItems(order models.OrderGet) error {
 			NmID:       item.NmID,
 			Brand:      item.Brand,
 		}
-		_, err := m.DB.Exec(stmt, insertItem.OrderUID, insertItem.ChrtID, insertItem.Price, insertItem.Rid, insertItem.Name, insertItem.Sale, insertItem.Size, insertItem.TotalPrice, insertItem.NmID, insertItem.Brand)
+		_, err := tx.Exec(stmt, insertItem.OrderUID, insertItem.ChrtID, insertItem.Price, insertItem.Rid, insertItem.Name, insertItem.Sale, insertItem.Size, insertItem.TotalPrice, insertItem.NmID, insertItem.Brand)
 		if err != nil {
 			return err
 		}
@@ -108,10 +248,7 @@ func (m *DbModel) InsertNewItems(order models.OrderGet) error {
 	return nil
 }
 
-func (m *DbModel) InsertNewOrder(order models.OrderGet) error {
-
-	m.RLock()
-	defer m.RUnlock()
+func (m *DbModel) InsertNewOrder(tx *sql.Tx, order models.OrderGet) error {
 
 	orderGet := models.OrderGet{
 		OrderUID:          order.OrderUID,
@@ -126,40 +263,11 @@ func (m *DbModel) InsertNewOrder(order models.OrderGet) error {
 		SmID:              order.SmID,
 	}
 
-	m.Add(2)
-
-	go func() {
-
-		defer m.Done()
-
-		OrderCache.SetCacheOrderGet(orderGet.OrderUID, orderGet, 5*time.Minute)
-	}()
-
-	go func() {
-
-		defer m.Done()
-
-		stmt := "SELECT insertneworder ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
-
-		_, err := m.DB.Exec(stmt, orderGet.OrderUID, orderGet.Entry, orderGet.InternalSignature, orderGet.Payment.Transaction, orderGet.Locale, orderGet.CustomerID, orderGet.TrackNumber, orderGet.DeliveryService, orderGet.Shardkey, orderGet.SmID)
-		if err != nil {
-
-			log.Println(err)
-
-			m.DB.Exec(stmt, OrderCache.GetCacheOrderGet(orderGet.OrderUID).OrderUID,
-				OrderCache.GetCacheOrderGet(orderGet.OrderUID).Entry,
-				OrderCache.GetCacheOrderGet(orderGet.OrderUID).InternalSignature,
-				OrderCache.GetCacheOrderGet(orderGet.OrderUID).Payment.Transaction,
-				OrderCache.GetCacheOrderGet(orderGet.OrderUID).Locale,
-				OrderCache.GetCacheOrderGet(orderGet.OrderUID).CustomerID,
-				OrderCache.GetCacheOrderGet(orderGet.OrderUID).TrackNumber,
-				OrderCache.GetCacheOrderGet(orderGet.OrderUID).DeliveryService,
-				OrderCache.GetCacheOrderGet(orderGet.OrderUID).Shardkey,
-				OrderCache.GetCacheOrderGet(orderGet.OrderUID).SmID)
-		}
-	}()
-
-	m.Wait()
+	stmt := "SELECT insertneworder ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
 
+	_, err := tx.Exec(stmt, orderGet.OrderUID, orderGet.Entry, orderGet.InternalSignature, orderGet.Payment.Transaction, orderGet.Locale, orderGet.CustomerID, orderGet.TrackNumber, orderGet.DeliveryService, orderGet.Shardkey, orderGet.SmID)
+	if err != nil {
+		return err
+	}
 	return nil
 }
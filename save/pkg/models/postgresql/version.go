@@ -0,0 +1,161 @@
+package postgresql
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/lib/pq"
+	"my.service.save/pkg/models"
+)
+
+/*
+Идемпотентное сохранение заказов.
+NATS Streaming может доставить одно и то же сообщение повторно, а один и тот же order_uid может прийти
+с другим содержимым. Поэтому для каждого заказа в таблице order_version хранится история версий
+с хэшем содержимого (payload_hash):
+1) Заказ с новым order_uid – сохраняется как версия 1 (OutcomeInserted);
+2) Заказ с тем же order_uid и тем же хэшем – повтор, в БД ничего не меняется (OutcomeDuplicate);
+3) Заказ с тем же order_uid, но другим содержимым – поведение зависит от политики DbModel.OnConflict:
+	3.1) ConflictReject (по умолчанию) – заказ отклоняется с ошибкой ErrOrderConflict;
+	3.2) ConflictVersion – предыдущая версия удаляется (хранимая процедура deleteorder), новая сохраняется
+	под следующим номером версии (OutcomeUpdated). Прежнее содержимое остаётся в order_version.
+4) Заказ, сохранённый до появления order_version, имеет версию 1 с пустым хэшем (миграция в scripts.sql не может
+вычислить хэш JSON). Такой заказ сравнивается с сохранёнными строками order_get, payment и items (storedOrderMatches):
+совпал – это повтор (OutcomeDuplicate), а его хэш и содержимое записываются в версию 1 (adoptOrderVersion),
+чтобы следующие повторы сравнивались по хэшу; не совпал – действует политика п. 3.
+5) Заказ из снимка кэша (RestoreOrder) сохраняется, только если order_uid ещё нет в order_version,
+иначе пропускается (OutcomeSkipped): устаревший снимок не создаёт новую версию и не откатывает заказ.
+
+Функции:
+1) orderHash – возвращает JSON заказа и его хэш SHA-256;
+2) lockOrder – блокирует order_uid до конца транзакции (pg_advisory_xact_lock), чтобы несколько экземпляров
+«save» не сохраняли один и тот же заказ одновременно;
+3) lastOrderVersion – возвращает номер и хэш последней версии заказа (0, "" – если заказа нет);
+4) deleteOrder, insertOrderVersion и adoptOrderVersion – вызывают одноимённые хранимые процедуры внутри транзакции;
+5) storedOrderMatches – сравнивает заказ с сохранёнными строками (хранимые процедуры selectorderget, selectorderpayments,
+selectorderitems). goods_total в БД не хранится и не сравнивается.
+*/
+
+const (
+	ConflictReject  = "reject"
+	ConflictVersion = "version"
+)
+
+const (
+	OutcomeInserted  = "inserted"
+	OutcomeDuplicate = "duplicate"
+	OutcomeUpdated   = "updated"
+	OutcomeSkipped   = "skipped"
+)
+
+var ErrOrderConflict = errors.New("заказ с таким order_uid уже сохранён с другим содержимым")
+
+type InsertResult struct {
+	Outcome string
+	Version int
+}
+
+func orderHash(order models.OrderGet) (payload []byte, hash string, err error) {
+	payload, err = json.Marshal(order)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(payload)
+	return payload, hex.EncodeToString(sum[:]), nil
+}
+
+func lockOrder(tx *sql.Tx, orderUID string) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", orderUID)
+	return err
+}
+
+func lastOrderVersion(tx *sql.Tx, orderUID string) (version int, hash string, err error) {
+	query := "SELECT version, payload_hash FROM order_version WHERE order_uid = $1 ORDER BY version DESC LIMIT 1"
+
+	err = tx.QueryRow(query, orderUID).Scan(&version, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", nil
+	}
+	return version, hash, err
+}
+
+func deleteOrder(tx *sql.Tx, orderUID string) error {
+	_, err := tx.Exec("SELECT deleteorder ($1)", orderUID)
+	return err
+}
+
+func insertOrderVersion(tx *sql.Tx, orderUID string, version int, hash string, payload []byte) error {
+	_, err := tx.Exec("SELECT insertorderversion ($1, $2, $3, $4)", orderUID, version, hash, payload)
+	return err
+}
+
+func adoptOrderVersion(tx *sql.Tx, orderUID string, version int, hash string, payload []byte) error {
+	_, err := tx.Exec("SELECT adoptorderversion ($1, $2, $3, $4)", orderUID, version, hash, payload)
+	return err
+}
+
+func storedOrderMatches(tx *sql.Tx, order models.OrderGet) (bool, error) {
+
+	stored := models.OrderGet{OrderUID: order.OrderUID}
+	ids := pq.Array([]string{order.OrderUID})
+
+	err := tx.QueryRow("SELECT entry, internal_signature, locale, customer_id, track_number, delivery_service, shardkey, sm_id FROM selectorderget ($1)", order.OrderUID).
+		Scan(&stored.Entry, &stored.InternalSignature, &stored.Locale, &stored.CustomerID, &stored.TrackNumber, &stored.DeliveryService, &stored.Shardkey, &stored.SmID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	p := &stored.Payment
+	err = tx.QueryRow("SELECT transaction, currency, provider, amount, payment_dt, bank, deliverycost FROM selectorderpayments ($1)", ids).
+		Scan(&p.Transaction, &p.Currency, &p.Provider, &p.Amount, &p.PaymentDt, &p.Bank, &p.DeliveryCost)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := tx.Query("SELECT chrt_id, price, rid, name, sale, size, total_price, nmid, brand FROM selectorderitems ($1)", ids)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it models.Items
+		if err := rows.Scan(&it.ChrtID, &it.Price, &it.Rid, &it.Name, &it.Sale, &it.Size, &it.TotalPrice, &it.NmID, &it.Brand); err != nil {
+			return false, err
+		}
+		stored.Items = append(stored.Items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	return sameOrder(stored, order), nil
+}
+
+// sameOrder сравнивает сохранённый заказ с полученным без полей, которых нет в БД: goods_total и OrderUID оплаты и товаров.
+func sameOrder(stored, order models.OrderGet) bool {
+	stored.Payment.GoodsTotal = order.Payment.GoodsTotal
+	stored.Payment.OrderUID, order.Payment.OrderUID = "", ""
+
+	if len(stored.Items) != len(order.Items) {
+		return false
+	}
+	for i := range order.Items {
+		a, b := stored.Items[i], order.Items[i]
+		a.OrderUID, b.OrderUID = "", ""
+		if a != b {
+			return false
+		}
+	}
+	stored.Items, order.Items = nil, nil
+	return reflect.DeepEqual(stored, order)
+}
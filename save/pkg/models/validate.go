@@ -0,0 +1,245 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+Проверка (валидация) поступившего заказа:
+1) Структура FieldError – ошибка конкретного поля: Field – путь к полю (например, payment.amount или items[2].brand),
+Reason – причина отказа;
+2) Тип ValidationErrors – список ошибок полей. Реализует интерфейс error, поэтому его можно вернуть как обычную ошибку,
+а затем через errors.As получить весь список – для логирования и подсчёта;
+3) Функция ParseOrderGet принимает в качестве аргумента срез байт (JSON заказа) и возвращает объект типа OrderGet
+и ошибку типа ValidationErrors (при наличии). Даже при ошибке, по возможности заполняет OrderUID – для логирования.
+Порядок проверки:
+	3.1) Наличие всех обязательных полей заказа, оплаты (payment) и каждого товара (items) и их типы: строка, целое число,
+	объект или массив;
+	3.2) Непустые строки и неотрицательные числа там, где это требуется;
+	3.3) Известная валюта (KnownCurrencies) и локаль (KnownLocales);
+	3.4) Сумма оплаты: payment.amount == payment.goods_total + payment.delivery_cost.
+ВАЖНО: если найдена ошибка типа или отсутствует обязательное поле, проверки п. 3.3 и 3.4 не выполняются –
+сначала нужно исправить структуру сообщения.
+*/
+
+var KnownCurrencies = map[string]bool{
+	"RUB": true,
+	"USD": true,
+	"EUR": true,
+	"KZT": true,
+	"BYN": true,
+}
+
+var KnownLocales = map[string]bool{
+	"ru": true,
+	"en": true,
+}
+
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	reasons := make([]string, 0, len(v))
+	for _, e := range v {
+		reasons = append(reasons, e.Error())
+	}
+	return strings.Join(reasons, "; ")
+}
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindInt
+	kindObject
+	kindArray
+)
+
+type fieldSpec struct {
+	name        string
+	kind        fieldKind
+	optional    bool
+	nonEmpty    bool
+	nonNegative bool
+}
+
+var orderGetFields = []fieldSpec{
+	{name: "order_uid", kind: kindString, nonEmpty: true},
+	{name: "entry", kind: kindString, nonEmpty: true},
+	{name: "internal_signature", kind: kindString},
+	{name: "payment", kind: kindObject},
+	{name: "items", kind: kindArray},
+	{name: "locale", kind: kindString, nonEmpty: true},
+	{name: "customer_id", kind: kindString, nonEmpty: true},
+	{name: "track_number", kind: kindString, nonEmpty: true},
+	{name: "delivery_service", kind: kindString, nonEmpty: true},
+	{name: "shardkey", kind: kindString, nonEmpty: true},
+	{name: "sm_id", kind: kindInt, optional: true, nonNegative: true},
+}
+
+var paymentFields = []fieldSpec{
+	{name: "transaction", kind: kindString, nonEmpty: true},
+	{name: "currency", kind: kindString, nonEmpty: true},
+	{name: "provider", kind: kindString, nonEmpty: true},
+	{name: "amount", kind: kindInt, nonNegative: true},
+	{name: "payment_dt", kind: kindInt, nonNegative: true},
+	{name: "bank", kind: kindString, nonEmpty: true},
+	{name: "delivery_cost", kind: kindInt, nonNegative: true},
+	{name: "goods_total", kind: kindInt, nonNegative: true},
+}
+
+var itemFields = []fieldSpec{
+	{name: "chrt_id", kind: kindInt, nonNegative: true},
+	{name: "price", kind: kindInt, nonNegative: true},
+	{name: "rid", kind: kindString, nonEmpty: true},
+	{name: "name", kind: kindString, nonEmpty: true},
+	{name: "sale", kind: kindInt, nonNegative: true},
+	{name: "size", kind: kindString},
+	{name: "total_price", kind: kindInt, nonNegative: true},
+	{name: "nm_id", kind: kindInt, nonNegative: true},
+	{name: "brand", kind: kindString, nonEmpty: true},
+}
+
+func ParseOrderGet(data []byte) (order OrderGet, err error) {
+
+	var errs ValidationErrors
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return order, ValidationErrors{{Field: "$", Reason: "некорректный JSON: " + err.Error()}}
+	}
+
+	_ = json.Unmarshal(raw["order_uid"], &order.OrderUID)
+
+	checkFields("", raw, orderGetFields, &errs)
+
+	if value, ok := raw["payment"]; ok && kindOf(value) == kindObject {
+		var payment map[string]json.RawMessage
+		if err := json.Unmarshal(value, &payment); err == nil {
+			checkFields("payment.", payment, paymentFields, &errs)
+		}
+	}
+
+	if value, ok := raw["items"]; ok && kindOf(value) == kindArray {
+		var items []json.RawMessage
+		if err := json.Unmarshal(value, &items); err == nil {
+			if len(items) == 0 {
+				errs = append(errs, FieldError{Field: "items", Reason: "заказ без товаров"})
+			}
+			for i, value := range items {
+				prefix := fmt.Sprintf("items[%d]", i)
+				var item map[string]json.RawMessage
+				if kindOf(value) != kindObject || json.Unmarshal(value, &item) != nil {
+					errs = append(errs, FieldError{Field: prefix, Reason: "ожидался объект"})
+					continue
+				}
+				checkFields(prefix+".", item, itemFields, &errs)
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return order, errs
+	}
+
+	if err := json.Unmarshal(data, &order); err != nil {
+		return order, ValidationErrors{{Field: "$", Reason: err.Error()}}
+	}
+
+	if !KnownCurrencies[order.Payment.Currency] {
+		errs = append(errs, FieldError{Field: "payment.currency", Reason: "неизвестная валюта " + order.Payment.Currency})
+	}
+	if !KnownLocales[order.Locale] {
+		errs = append(errs, FieldError{Field: "locale", Reason: "неизвестная локаль " + order.Locale})
+	}
+	if order.Payment.Amount != order.Payment.GoodsTotal+order.Payment.DeliveryCost {
+		errs = append(errs, FieldError{Field: "payment.amount",
+			Reason: fmt.Sprintf("сумма %d не равна goods_total + delivery_cost (%d)", order.Payment.Amount,
+				order.Payment.GoodsTotal+order.Payment.DeliveryCost)})
+	}
+
+	if len(errs) != 0 {
+		return order, errs
+	}
+	return order, nil
+}
+
+func checkFields(prefix string, raw map[string]json.RawMessage, specs []fieldSpec, errs *ValidationErrors) {
+	for _, spec := range specs {
+		field := prefix + spec.name
+
+		value, ok := raw[spec.name]
+		if !ok {
+			if !spec.optional {
+				*errs = append(*errs, FieldError{Field: field, Reason: "обязательное поле отсутствует"})
+			}
+			continue
+		}
+
+		if kindOf(value) != spec.kind {
+			*errs = append(*errs, FieldError{Field: field, Reason: "ожидался тип " + spec.kind.String()})
+			continue
+		}
+
+		switch spec.kind {
+		case kindString:
+			var s string
+			_ = json.Unmarshal(value, &s)
+			if spec.nonEmpty && strings.TrimSpace(s) == "" {
+				*errs = append(*errs, FieldError{Field: field, Reason: "пустая строка"})
+			}
+		case kindInt:
+			var n int
+			if err := json.Unmarshal(value, &n); err != nil {
+				*errs = append(*errs, FieldError{Field: field, Reason: "ожидался тип " + spec.kind.String()})
+				continue
+			}
+			if spec.nonNegative && n < 0 {
+				*errs = append(*errs, FieldError{Field: field, Reason: "отрицательное значение"})
+			}
+		}
+	}
+}
+
+func kindOf(value json.RawMessage) fieldKind {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 {
+		return -1
+	}
+	switch c := value[0]; {
+	case c == '"':
+		return kindString
+	case c == '{':
+		return kindObject
+	case c == '[':
+		return kindArray
+	case c == '-' || (c >= '0' && c <= '9'):
+		return kindInt
+	}
+	return -1
+}
+
+func (k fieldKind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindInt:
+		return "integer"
+	case kindObject:
+		return "object"
+	case kindArray:
+		return "array"
+	}
+	return "unknown"
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+/*
+Тестирование проверки заказа (функция ParseOrderGet):
+1) Корректный заказ должен разбираться без ошибок;
+2) Для некорректных заказов сверяем список полей, попавших в ValidationErrors:
+отсутствующее поле, неверный тип, пустая строка, отрицательная сумма, неизвестная валюта,
+неверная сумма оплаты и слово "entry" внутри названия товара (раньше такой заказ проходил проверку).
+*/
+
+const validOrder = `{"order_uid":"1q1","entry":"WBIL","internal_signature":"sig",
+"payment":{"transaction":"t1","currency":"USD","provider":"WbPay","amount":317,"payment_dt":1614540555,"bank":"alpha","delivery_cost":17,"goods_total":300},
+"items":[{"chrt_id":1,"price":300,"rid":"r1","name":"Сказки","sale":0,"size":"","total_price":300,"nm_id":2,"brand":"Умка"}],
+"locale":"ru","customer_id":"c1","track_number":"WBIL1","delivery_service":"meest","shardkey":"9","sm_id":33}`
+
+func TestParseOrderGetValid(t *testing.T) {
+	order, err := ParseOrderGet([]byte(validOrder))
+	if err != nil {
+		t.Fatalf("Error %v occurated.", err)
+	}
+	if order.OrderUID != "1q1" || len(order.Items) != 1 || order.Payment.Amount != 317 {
+		t.Fatalf("Incorrect parsing: %+v", order)
+	}
+}
+
+func TestParseOrderGetInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace [2]string
+		field   string
+	}{
+		{"missing field", [2]string{`"entry":"WBIL",`, ``}, "entry"},
+		{"entry in item name", [2]string{`"entry":"WBIL",`, `"name2":"entry",`}, "entry"},
+		{"wrong type", [2]string{`"sm_id":33`, `"sm_id":"33"`}, "sm_id"},
+		{"float amount", [2]string{`"amount":317`, `"amount":317.5`}, "payment.amount"},
+		{"empty string", [2]string{`"customer_id":"c1"`, `"customer_id":" "`}, "customer_id"},
+		{"negative price", [2]string{`"price":300`, `"price":-1`}, "items[0].price"},
+		{"missing item field", [2]string{`"brand":"Умка"`, `"brand2":"Умка"`}, "items[0].brand"},
+		{"unknown currency", [2]string{`"currency":"USD"`, `"currency":"XXX"`}, "payment.currency"},
+		{"unknown locale", [2]string{`"locale":"ru"`, `"locale":"xx"`}, "locale"},
+		{"amount mismatch", [2]string{`"delivery_cost":17`, `"delivery_cost":18`}, "payment.amount"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := strings.Replace(validOrder, tt.replace[0], tt.replace[1], 1)
+
+			order, err := ParseOrderGet([]byte(data))
+
+			var fieldErrors ValidationErrors
+			if !errors.As(err, &fieldErrors) {
+				t.Fatalf("Expected ValidationErrors, got %v", err)
+			}
+			found := false
+			for _, fieldError := range fieldErrors {
+				if fieldError.Field == tt.field {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("Field %s not reported: %v", tt.field, fieldErrors)
+			}
+			if order.OrderUID != "1q1" {
+				t.Fatalf("OrderUID is lost: %q", order.OrderUID)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"my.service.show/pkg/models"
+)
+
+/*
+HTTP API пакетного запроса заказов (маршрут /api/orders/batch) – для сверок, которым нужны тысячи заказов сразу.
+
+1) BatchOrders – хендлер: принимает POST с JSON {"order_uids": [...]} (не больше maxBatchBody байт),
+запрашивает заказы у микросервиса «query» (функция RequestBatch) и отвечает JSON models.BatchReply:
+статус запроса (status, error) и результат по каждому ID со статусом found (и заказом), not_found или invalid_id (некорректный ID).
+Код ответа – по статусу (функция statusCode, файл helpers.go):
+	1.1) 200 (ok) – запрос выполнен;
+	1.2) 400 (invalid_request) – неверный JSON или query отклонил запрос (пустой список, слишком много ID) – причина в поле error;
+	1.3) 405 (invalid_request) – метод не POST;
+	1.4) 502 (internal) – query не ответил или не загрузил заказы (ошибка БД);
+	1.5) 503 (unavailable) – пакетные запросы выключены (order_batch_subject) или query перегружен (заголовок Retry-After).
+2) Функция RequestBatch отправляет пакетный запрос микросервису «query» в режиме «запрос – ответ» так же,
+как RequestOrder (файл requestOrder.go), но ждёт ответа до batch_timeout: загрузка тысяч заказов дольше запроса одного.
+3) Функция batchError – ответ API с ошибкой show (без результатов): статус и сообщение.
+*/
+
+const maxBatchBody = 1 << 20
+
+var errBatchDisabled = errors.New("пакетные запросы выключены (order_batch_subject)")
+
+func (app *Application) BatchOrders(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		app.writeJSON(w, http.StatusMethodNotAllowed, batchError(models.StatusInvalidRequest, http.StatusText(http.StatusMethodNotAllowed)))
+		return
+	}
+
+	var request models.BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&request); err != nil {
+		app.writeJSON(w, http.StatusBadRequest, batchError(models.StatusInvalidRequest, fmt.Sprintf("неверный запрос: %v", err)))
+		return
+	}
+
+	reply, err := app.RequestBatch(request)
+	switch {
+	case errors.Is(err, errBatchDisabled):
+		app.writeJSON(w, http.StatusServiceUnavailable, batchError(models.StatusUnavailable, err.Error()))
+	case err != nil:
+		app.errorLog.Printf("Пакетный запрос %d заказов: %v", len(request.OrderUIDs), err)
+		app.writeJSON(w, http.StatusBadGateway, batchError(models.StatusInternal, "микросервис query не ответил"))
+	default:
+		app.writeJSON(w, statusCode(reply.Status), reply)
+	}
+}
+
+func batchError(status, message string) models.BatchReply {
+	return models.BatchReply{Reply: models.Reply{Status: status, Error: message}}
+}
+
+func (app *Application) RequestBatch(request models.BatchRequest) (reply models.BatchReply, err error) {
+
+	if app.config.BatchSubject == "" {
+		return reply, errBatchDisabled
+	}
+	if !app.nc.Healthy() {
+		return reply, fmt.Errorf("нет соединения с NATS (%s)", app.nc.Health().State)
+	}
+
+	request.RequestID = newRequestID()
+	data, err := json.Marshal(request)
+	if err != nil {
+		return reply, err
+	}
+
+	msg, err := app.nc.Request(app.config.BatchSubject, data, app.config.BatchTimeout)
+	if err != nil {
+		return reply, err
+	}
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		return reply, err
+	}
+	if err := checkReply(request.RequestID, reply.Reply); err != nil {
+		return reply, err
+	}
+	reply.RequestID = ""
+	return reply, nil
+}
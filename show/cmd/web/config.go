@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"my.service.common/natsconn"
+)
+
+/*
+Структура Config – настройки микросервиса «show». Загружаются функцией config.MustLoad (пакет my.service.common/config)
+в порядке: значения по умолчанию (функция defaultConfig), файл настроек (-config или CONFIG_FILE),
+переменные окружения, флаги командной строки.
+Функция natsOptions возвращает параметры общего соединения с NATS (пакет my.service.common/natsconn).
+
+Функция Validate проверяет значения, которые нельзя проверить по типу: время ожидания ответа микросервиса «query»
+на запрос заказа и на пакетный запрос.
+*/
+
+type Config struct {
+	Addr            string        `json:"addr" env:"SHOW_ADDR" flag:"addr" usage:"Сетевой адрес веб-сервера" required:"true"`
+	NATSURL         string        `json:"nats_url" env:"NATS_URL" flag:"nats-url" usage:"Адрес сервера NATS для запросов микросервису «query»" required:"true"`
+	OrderSubject    string        `json:"order_request_subject" env:"ORDER_REQUEST_SUBJECT" flag:"subject" usage:"Subject запросов заказа по ID" required:"true"`
+	SearchSubject   string        `json:"order_search_subject" env:"ORDER_SEARCH_SUBJECT" flag:"search-subject" usage:"Subject запросов поиска заказов (пусто – поиск выключен)"`
+	BatchSubject    string        `json:"order_batch_subject" env:"ORDER_BATCH_SUBJECT" flag:"batch-subject" usage:"Subject пакетных запросов заказов по списку ID (пусто – /api/orders/batch выключен)"`
+	BatchTimeout    time.Duration `json:"batch_timeout" env:"SHOW_BATCH_TIMEOUT" flag:"batch-timeout" usage:"Время ожидания ответа микросервиса «query» на пакетный запрос"`
+	RequestTimeout  time.Duration `json:"request_timeout" env:"SHOW_REQUEST_TIMEOUT" flag:"request-timeout" usage:"Время ожидания ответа микросервиса «query»"`
+	ReconnectWait   time.Duration `json:"nats_reconnect_wait" env:"NATS_RECONNECT_WAIT" flag:"reconnect-wait" usage:"Пауза между попытками переподключения к NATS"`
+	ReconnectBuf    int           `json:"nats_reconnect_buf" env:"NATS_RECONNECT_BUF" flag:"reconnect-buf" usage:"Размер буфера публикаций (байт) на время переподключения к NATS"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" env:"SHOW_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"Максимальное время остановки по сигналу SIGTERM (0 – без ограничения)"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Addr:            ":8080",
+		NATSURL:         "demo.nats.io",
+		OrderSubject:    "IDSend",
+		SearchSubject:   "OrderSearch",
+		BatchSubject:    "OrderBatch",
+		BatchTimeout:    15 * time.Second,
+		RequestTimeout:  5 * time.Second,
+		ReconnectWait:   2 * time.Second,
+		ReconnectBuf:    8 * 1024 * 1024,
+		ShutdownTimeout: 20 * time.Second,
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.RequestTimeout <= 0 {
+		return fmt.Errorf("request_timeout должен быть больше 0, получено %s", c.RequestTimeout)
+	}
+	if c.BatchTimeout <= 0 {
+		return fmt.Errorf("batch_timeout должен быть больше 0, получено %s", c.BatchTimeout)
+	}
+	return nil
+}
+
+func (c *Config) natsOptions(infoLog, errorLog *log.Logger) natsconn.Options {
+	hostname, _ := os.Hostname()
+	return natsconn.Options{
+		URL:              c.NATSURL,
+		Name:             "show" + "-" + hostname,
+		ReconnectWait:    c.ReconnectWait,
+		ReconnectBufSize: c.ReconnectBuf,
+		DrainTimeout:     c.ShutdownTimeout,
+		InfoLog:          infoLog,
+		ErrorLog:         errorLog,
+	}
+}
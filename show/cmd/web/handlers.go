@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"html/template"
 	"net/http"
 
@@ -9,22 +8,28 @@ import (
 )
 
 /*
-Для работы http-сервера, используем 2 хендлера:
+Для работы http-сервера, используем 2 хендлера (хендлер поиска заказов SearchOrders – в файле search.go):
 1) Home – отображает «стартовую» страницу при запросе по URL "/".
 Для UI в данном хендлере, используем срез строк – путь к html-страницам:
 	1.1) home.page.html – сама «стартовая» страница;
 	1.2) base.layout.html – шаблон страниц для всего сервера.
 2) ShowOrder – отображает страницу с найденнм заказом:
 	2.1) Считываем ID, введённый пользователем;
-	2.2) Публикуем его в Nats Streaming (отправляем микросервису «query» для поиска нужного заказа);
-	2.3) Обрабатываем html-страницу: serchbyid.page.html, для вывода информации о заказе;
-	2.4) Посредством Nats Streaming, получаем от микросервиса «query» ответ в виде объекта JSON;
-	2.5) Переводим декодируем объект JSON в объект типа models.OrderPost;
-	2.6) В случае если получили заполненный объект – выводим данные в виде таблицы.
-	2.7) В случае, если получили «пустой» объект –
-	выводим на экран информацию о неверно введённом ID, пользователем.
+	2.2) Обрабатываем html-страницу: serchbyid.page.html, для вывода информации о заказе;
+	2.3) Запрашиваем заказ у микросервиса «query» в режиме «запрос – ответ» (функция RequestOrder);
+	2.4) Если ответ не получен (истёк таймаут, нет соединения) – отвечаем ошибкой сервера;
+	2.5) В случае если получили ответ со статусом ok – выводим страницу заказа: сведения о заказе, оплату,
+	товары и итоги (оплата и товары выводятся, только если они есть).
+	2.6) В случае, если query ответил другим статусом (заказ не найден, некорректный ID, query перегружен,
+	ошибка query) – выводим страницу с сообщением (шаблон orderError) и соответствующим кодом ответа:
+	404, 400, 503 или 502 (функции statusCode и statusMessage, файл helpers.go).
 */
 
+type orderErrorPage struct {
+	OrderUID string
+	Message  string
+}
+
 func (app *Application) Home(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		app.NotFound(w)
@@ -53,10 +58,9 @@ func (app *Application) ShowOrder(w http.ResponseWriter, r *http.Request) {
 	searched, ok := r.URL.Query()["id"]
 	if !ok || len(searched[0]) < 1 {
 		app.NotFound(w)
-
+		return
 	}
 	orderId := searched[0]
-	app.PubishID(orderId)
 
 	files := []string{
 		"./ui/html/serchbyid.page.html",
@@ -68,27 +72,23 @@ func (app *Application) ShowOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order := app.getSearchedOrder()
-
-	showAtUI := models.OrderPost{}
-	_ = json.Unmarshal(order, &showAtUI)
-
-	if *&showAtUI.OrderUID != "" {
+	reply, err := app.RequestOrder(orderId)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
 
-		err = ts.ExecuteTemplate(w, "order", showAtUI)
-		if err != nil {
-			app.ServerError(w, err)
-		}
-	} else {
-		showAtUI.OrderUID = "Заказа с указаным ID не существует!"
-		showAtUI.Entry = "У несуществующего заказа - нет продавца"
-		showAtUI.CustomerID = "ID Клиента не указан"
-		showAtUI.TrackNumber = "Невозможно отследить несуществующий заказ"
-		showAtUI.DeliveryService = "Этот заказ никто не доставляет"
-		err = ts.ExecuteTemplate(w, "order", showAtUI)
+	if reply.Status != models.StatusOK {
+		writeStatus(w, statusCode(reply.Status))
+		err = ts.ExecuteTemplate(w, "orderError", orderErrorPage{OrderUID: orderId, Message: statusMessage(reply.Reply)})
 		if err != nil {
-			app.ServerError(w, err)
+			app.errorLog.Println(err)
 		}
+		return
+	}
 
+	err = ts.ExecuteTemplate(w, "order", reply.Order)
+	if err != nil {
+		app.ServerError(w, err)
 	}
 }
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"runtime/debug"
+
+	"my.service.show/pkg/models"
 )
 
 /*
@@ -12,6 +15,12 @@ import (
 2) ClientError - отправляет определенный код состояния и соответствующее описание пользователю.
 Используется, если есть проблема с пользовательским запросом;
 3) NotFound  - оболочка вокруг ClientError, которая отправляет пользователю ответ "404 Страница не найдена"
+4) statusCode – код ответа HTTP по статусу ответа микросервиса «query» (models.Reply): ok – 200, not_found – 404,
+invalid_id и invalid_request – 400, unavailable – 503, internal и неизвестный статус – 502 (ошибка на стороне query);
+5) statusMessage – сообщение пользователю по статусу ответа query. Для invalid_request – причина отказа из ответа,
+для остальных статусов – общее сообщение: подробности ошибок query пользователю не показываются;
+6) writeStatus – записывает код ответа; к ответу 503 добавляет заголовок Retry-After – запрос можно повторить;
+7) writeJSON – отправляет ответ HTTP API в формате JSON с указанным кодом состояния.
 */
 
 func (app *Application) ServerError(w http.ResponseWriter, err error) {
@@ -28,3 +37,50 @@ func (app *Application) ClientError(w http.ResponseWriter, status int) {
 func (app *Application) NotFound(w http.ResponseWriter) {
 	app.ClientError(w, http.StatusNotFound)
 }
+
+func statusCode(status string) int {
+	switch status {
+	case models.StatusOK:
+		return http.StatusOK
+	case models.StatusNotFound:
+		return http.StatusNotFound
+	case models.StatusInvalidID, models.StatusInvalidRequest:
+		return http.StatusBadRequest
+	case models.StatusUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func statusMessage(reply models.Reply) string {
+	switch reply.Status {
+	case models.StatusOK:
+		return ""
+	case models.StatusNotFound:
+		return "Заказа с указанным ID не существует"
+	case models.StatusInvalidID:
+		return "Некорректный ID заказа: ID не может быть пустым, содержать пробелы или быть длиннее 128 символов"
+	case models.StatusInvalidRequest:
+		return reply.Error
+	case models.StatusUnavailable:
+		return "Сервис перегружен, повторите запрос позже"
+	default:
+		return "Не удалось получить данные, повторите запрос позже"
+	}
+}
+
+func writeStatus(w http.ResponseWriter, code int) {
+	if code == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	w.WriteHeader(code)
+}
+
+func (app *Application) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writeStatus(w, status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		app.errorLog.Println(err)
+	}
+}
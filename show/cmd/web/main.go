@@ -1,57 +1,96 @@
 package main
 
 import (
-	"flag"
+	"context"
 	"log"
 	"net/http"
 	"os"
-	"sync"
 
 	_ "github.com/lib/pq"
+	"my.service.common/config"
+	"my.service.common/lifecycle"
+	"my.service.common/natsconn"
 )
 
 /*
 Основная часть программы:
-1) Структура Application – основная структура программы, управляющая информацией о работе программы и ошибках
+1) Структура Application – основная структура программы, управляющая информацией о работе программы и ошибках.
+Поле config – настройки микросервиса (структура Config, файл config.go), поле nc – общее долгоживущее соединение с NATS
+для запросов микросервису «query» (переподключается автоматически, состояние – nc.Health()).
 2) В функции main:
-	2.1) Устанавливаем счётчик WaitGroup;
-	2.2) Указываем адрес веб-сервера;
-	2.3) Задаём параметры для информировании о работе приложения и об ошибках в нём (infoLog, errorLog);
-	2.4) Получаем функциональность приложения в части информирования о работе программы и сбоях в ней, создавая объект структуры  Application.
-	2.5) Инициализируем http.Server, передавая:
-		2.5.1) Адрес веб-сервера из п. 2.2;
-		2.5.2) Лог ошибок из п. 2.3;
-		2.5.3) В качестве хендлера – функцию Routes, отвечающую за маршрутизацию запросов.
-	2.6) Подключаемся и обслуживаем созданный сервер.
+	2.1) Загружаем настройки (адрес веб-сервера, адрес NATS, subject и время ожидания запросов к «query»):
+	файл настроек, переменные окружения и флаги;
+	2.2) Задаём параметры для информировании о работе приложения и об ошибках в нём (infoLog, errorLog);
+	2.3) Соединяемся с NATS (natsconn.Connect) и получаем функциональность приложения в части информирования о работе программы и сбоях в ней, создавая объект структуры  Application.
+	2.4) Инициализируем http.Server, передавая:
+		2.4.1) Адрес веб-сервера из п. 2.1;
+		2.4.2) Лог ошибок из п. 2.2;
+		2.4.3) В качестве хендлера – функцию Routes, отвечающую за маршрутизацию запросов.
+	2.5) Подключаемся и обслуживаем созданный сервер.
+	2.6) При получении SIGINT/SIGTERM (lifecycle.SignalContext) вызываем http.Server.Shutdown: новые соединения
+	не принимаются, текущие запросы обрабатываются до конца, но не дольше ShutdownTimeout. Затем закрываем соединение с NATS.
 */
 
 type Application struct {
 	errorLog *log.Logger
 	infoLog  *log.Logger
+	config   Config
+	nc       *natsconn.Conn
 }
 
-var Wg sync.WaitGroup
-
 func main() {
-	Wg.Add(2)
-	addr := flag.String("addr", ":8080", "Сетевой адрес веб-сервера")
+
+	cfg := defaultConfig()
+	config.MustLoad(&cfg, os.Stdout)
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	nc, err := natsconn.Connect(cfg.natsOptions(infoLog, errorLog))
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	app := &Application{
 		errorLog: errorLog,
 		infoLog:  infoLog,
+		config:   cfg,
+		nc:       nc,
 	}
 
 	srv := &http.Server{
-		Addr:     *addr,
+		Addr:     cfg.Addr,
 		ErrorLog: errorLog,
 		Handler:  app.Routes(),
 	}
 
-	infoLog.Printf("Запуск сервера на %s", *addr)
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
+	lifecycle.Watchdog(ctx, cfg.ShutdownTimeout, func() {
+		errorLog.Fatalf("Остановка не завершена за %s", cfg.ShutdownTimeout)
+	})
+
+	serveErr := make(chan error, 1)
+	go func() {
+		infoLog.Printf("Запуск сервера на %s", cfg.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
 
-	err := srv.ListenAndServe()
-	errorLog.Fatal(err)
+	select {
+	case err := <-serveErr:
+		errorLog.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
+	infoLog.Println("Остановка: новые соединения не принимаются, обрабатываются текущие запросы.")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		errorLog.Println(err)
+	}
+	if err := nc.Drain(); err != nil {
+		errorLog.Println(err)
+	}
+	infoLog.Println("Сервер остановлен.")
 }
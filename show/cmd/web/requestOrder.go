@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"my.service.show/pkg/models"
+)
+
+/*
+Функция RequestOrder принимает в качестве аргумента строку - сам ID, указанный пользователем.
+Возвращает ответ query типа models.OrderReply (статус и заказ) и ошибку, если ответ не получен.
+Процесс работы функции:
+1) Проверяем общее соединение с NATS (поле nc структуры Application, создаётся в main): если идёт переподключение –
+сразу возвращаем ошибку, не дожидаясь RequestTimeout;
+2) Формируем запрос типа models.OrderRequest: ID заказа + уникальный идентификатор запроса (RequestID);
+3) Отправляем запрос в режиме «запрос – ответ» (nc.Request): ответ приходит в индивидуальный inbox,
+созданный только для этого запроса, поэтому одновременные запросы разных пользователей не смешиваются;
+4) Если за время RequestTimeout (настройки микросервиса) ответ не получен – возвращаем ошибку;
+5) Проверяем, что ответ – на этот запрос (функция checkReply), и возвращаем ответ. Статус ответа
+(не найден, некорректный ID, query перегружен, ошибка query) ошибкой не считается: ShowOrder выбирает по нему
+код ответа HTTP и сообщение пользователю. Ответ со статусом ok без заказа – ошибка.
+
+Функция checkReply проверяет, что RequestID ответа совпадает с RequestID запроса. Исключение – ответ invalid_request
+без RequestID: query не смог разобрать запрос и не знает его RequestID; такой ответ – ответ на этот запрос (400).
+Используется также RequestSearch и RequestBatch.
+
+Функция newRequestID генерирует случайный идентификатор запроса (корреляционный ID).
+*/
+
+func (app *Application) RequestOrder(ID string) (reply models.OrderReply, err error) {
+
+	if !app.nc.Healthy() {
+		return reply, fmt.Errorf("нет соединения с NATS (%s)", app.nc.Health().State)
+	}
+
+	request := models.OrderRequest{
+		RequestID: newRequestID(),
+		OrderUID:  ID,
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return reply, err
+	}
+
+	msg, err := app.nc.Request(app.config.OrderSubject, data, app.config.RequestTimeout)
+	if err != nil {
+		return reply, err
+	}
+
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		return reply, err
+	}
+
+	if err := checkReply(request.RequestID, reply.Reply); err != nil {
+		return reply, err
+	}
+	if reply.Status == models.StatusOK && reply.Order == nil {
+		return reply, fmt.Errorf("ответ на запрос %s без заказа", request.RequestID)
+	}
+	return reply, nil
+}
+
+func checkReply(requestID string, reply models.Reply) error {
+	if reply.RequestID == "" && reply.Status == models.StatusInvalidRequest {
+		return nil
+	}
+	if reply.RequestID != requestID {
+		return fmt.Errorf("получен ответ на чужой запрос: ожидался %s, получен %s", requestID, reply.RequestID)
+	}
+	return nil
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
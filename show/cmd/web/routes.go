@@ -8,7 +8,10 @@ import "net/http"
 «отправляет» пользователя на «стартовую страницу»;
 2) mux.HandleFunc("/order", app.ShowOrder) – при запросе по URL «http://localhost:8080/order?id=orderid»,
 «отправляет» пользователя на страницу с отображёнными сведениями о заказе, ID которого указал последний.
-3) fileServer := http.FileServer(http.Dir("./ui/static/"))
+3) mux.HandleFunc("/search", app.SearchOrders) – страница поиска заказов по клиенту, track-номеру, службе доставки,
+товару и периоду оплаты (файл search.go);
+4) mux.HandleFunc("/api/orders/batch", app.BatchOrders) – HTTP API пакетного запроса заказов по списку ID (файл batch.go);
+5) fileServer := http.FileServer(http.Dir("./ui/static/"))
 mux.Handle("/static/", http.StripPrefix("/static", fileServer)) – исключает доступ в к статичный файлам,
 находящимся на сервере, при использовании маршрутизации.
 */
@@ -17,6 +20,8 @@ func (app *Application) Routes() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", app.Home)
 	mux.HandleFunc("/order", app.ShowOrder)
+	mux.HandleFunc("/search", app.SearchOrders)
+	mux.HandleFunc("/api/orders/batch", app.BatchOrders)
 
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	mux.Handle("/static/", http.StripPrefix("/static", fileServer))
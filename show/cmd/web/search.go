@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"my.service.show/pkg/models"
+)
+
+/*
+Поиск заказов: форма поиска и список найденных заказов со ссылками на страницы заказов (маршрут /search).
+
+1) SearchOrders – хендлер страницы поиска:
+	1.1) Если фильтры не заданы – выводим пустую форму;
+	1.2) Разбираем форму (parseSearchForm); ошибка в форме – выводим форму с сообщением об ошибке;
+	1.3) Запрашиваем страницу результатов у микросервиса «query» (функция RequestSearch). Если поиск выключен
+	(errSearchDisabled) – выводим форму с сообщением и кодом 503 (writeStatus), как пакетный запрос (файл batch.go);
+	если ответ не получен – отвечаем ошибкой сервера; если статус ответа не ok – выводим форму с сообщением (statusMessage: причина отказа
+	для invalid_request, просьба повторить запрос для unavailable) и кодом ответа statusCode (файл helpers.go);
+	1.4) Выводим найденные заказы и ссылку на следующую страницу: те же параметры формы и курсор из ответа.
+Используем html-страницы search.page.html и base.layout.html.
+2) Функция parseSearchForm преобразует параметры формы в models.SearchRequest: даты периода оплаты (ГГГГ-ММ-ДД) –
+в Unix-время начала первого и конца последнего дня, артикул – в число.
+3) Функция RequestSearch отправляет запрос поиска микросервису «query» в режиме «запрос – ответ» так же,
+как RequestOrder (файл requestOrder.go), и возвращает ответ. Если поиск выключен (order_search_subject пуст) – ошибка errSearchDisabled.
+*/
+
+const searchDateLayout = "2006-01-02"
+
+var errSearchDisabled = errors.New("поиск заказов выключен (order_search_subject)")
+
+type searchPage struct {
+	Form     url.Values
+	Searched bool
+	Orders   []models.OrderSummary
+	NextURL  string
+	Error    string
+}
+
+func (app *Application) SearchOrders(w http.ResponseWriter, r *http.Request) {
+
+	files := []string{
+		"./ui/html/search.page.html",
+		"./ui/html/base.layout.html",
+	}
+
+	ts, err := template.ParseFiles(files...)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	form := r.URL.Query()
+	page := searchPage{Form: form}
+
+	request, filtered, err := parseSearchForm(form)
+	switch {
+	case err != nil:
+		page.Error = err.Error()
+	case filtered:
+		reply, err := app.RequestSearch(request)
+		if errors.Is(err, errSearchDisabled) {
+			page.Error = "Поиск заказов временно недоступен"
+			writeStatus(w, http.StatusServiceUnavailable)
+			break
+		}
+		if err != nil {
+			app.ServerError(w, err)
+			return
+		}
+		page.Searched = true
+		page.Orders, page.Error = reply.Orders, statusMessage(reply.Reply)
+		if reply.Status != models.StatusOK {
+			writeStatus(w, statusCode(reply.Status))
+		}
+		if reply.NextCursor != "" {
+			next := url.Values{}
+			for key, values := range form {
+				next[key] = values
+			}
+			next.Set("cursor", reply.NextCursor)
+			page.NextURL = "/search?" + next.Encode()
+		}
+	}
+
+	err = ts.Execute(w, page)
+	if err != nil {
+		app.ServerError(w, err)
+	}
+}
+
+func parseSearchForm(form url.Values) (request models.SearchRequest, filtered bool, err error) {
+
+	request = models.SearchRequest{
+		CustomerID:      strings.TrimSpace(form.Get("customer_id")),
+		TrackNumber:     strings.TrimSpace(form.Get("track_number")),
+		DeliveryService: strings.TrimSpace(form.Get("delivery_service")),
+		Brand:           strings.TrimSpace(form.Get("brand")),
+		Sort:            form.Get("sort"),
+		Cursor:          form.Get("cursor"),
+	}
+
+	if s := strings.TrimSpace(form.Get("nm_id")); s != "" {
+		if request.NmID, err = strconv.Atoi(s); err != nil || request.NmID <= 0 {
+			return request, false, fmt.Errorf("артикул (nm_id) должен быть положительным числом")
+		}
+	}
+	if s := form.Get("paid_from"); s != "" {
+		from, err := time.ParseInLocation(searchDateLayout, s, time.Local)
+		if err != nil {
+			return request, false, fmt.Errorf("неверная дата начала периода оплаты: %s", s)
+		}
+		request.PaidFrom = from.Unix()
+	}
+	if s := form.Get("paid_to"); s != "" {
+		to, err := time.ParseInLocation(searchDateLayout, s, time.Local)
+		if err != nil {
+			return request, false, fmt.Errorf("неверная дата конца периода оплаты: %s", s)
+		}
+		request.PaidTo = to.AddDate(0, 0, 1).Unix() - 1
+	}
+
+	filtered = request.CustomerID != "" || request.TrackNumber != "" || request.DeliveryService != "" ||
+		request.Brand != "" || request.NmID != 0 || request.PaidFrom != 0 || request.PaidTo != 0
+	return request, filtered, nil
+}
+
+func (app *Application) RequestSearch(request models.SearchRequest) (reply models.SearchReply, err error) {
+
+	if app.config.SearchSubject == "" {
+		return reply, errSearchDisabled
+	}
+	if !app.nc.Healthy() {
+		return reply, fmt.Errorf("нет соединения с NATS (%s)", app.nc.Health().State)
+	}
+
+	request.RequestID = newRequestID()
+	data, err := json.Marshal(request)
+	if err != nil {
+		return reply, err
+	}
+
+	msg, err := app.nc.Request(app.config.SearchSubject, data, app.config.RequestTimeout)
+	if err != nil {
+		return reply, err
+	}
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		return reply, err
+	}
+	if err := checkReply(request.RequestID, reply.Reply); err != nil {
+		return reply, err
+	}
+	return reply, nil
+}
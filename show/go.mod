@@ -1,8 +1,17 @@
 module my.service.show
 
-go 1.16
+go 1.18
 
 require (
 	github.com/lib/pq v1.10.2
-	github.com/nats-io/nats.go v1.11.0 // indirect
+	github.com/nats-io/nats.go v1.11.0
+	my.service.common v0.0.0
 )
+
+require (
+	github.com/nats-io/nkeys v0.3.0 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	golang.org/x/crypto v0.0.0-20210314154223-e6e6c4f2bb5b // indirect
+)
+
+replace my.service.common => ../common
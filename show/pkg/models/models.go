@@ -1,17 +1,148 @@
 package models
 
+import "time"
+
 /*
-Модель данных:
-OrderPost – структура, инкапсулирующая сведения о заказе для выдачи по запросу пользователя.
+Модели данных:
+1) OrderPost – структура, инкапсулирующая сведения о заказе для выдачи по запросу пользователя.
 ВАЖНО: благодаря внедрению ключевого параметра OrderUID в дальнейшем возможно идентифицировать заказ,
 набор товаров из него, а так же способ и порядок оплаты. Это упрощает идентификацию данных, ускоряет их обработку.
+Полные сведения о заказе от микросервиса «query»: оплата (Payment), товары (Items) и итоги по товарам
+(ItemsCount – количество, ItemsTotal – стоимость с учётом скидок); TotalPrice – стоимость товаров и доставки.
+2) OrderRequest – запрос заказа к микросервису «query»: ID заказа и уникальный идентификатор запроса (RequestID).
+3) OrderReply – ответ микросервиса «query»: конверт ответа (Reply) и найденный заказ (только при статусе StatusOK).
+Reply встроен во все ответы query (OrderReply, SearchReply, BatchReply): RequestID исходного запроса, статус
+(StatusOK, StatusNotFound, StatusInvalidID, StatusInvalidRequest, StatusUnavailable, StatusInternal) и сообщение
+об ошибке (Error). По статусу show выбирает код ответа HTTP и сообщение пользователю (файл cmd/web/helpers.go).
+4) Функция PaidAt – время оплаты (payment_dt – Unix-время в секундах) для отображения на странице заказа.
+5) SearchRequest – запрос поиска заказов к микросервису «query»: фильтры (пустые не передаются), сортировка
+(SortPaidDesc или SortPaidAsc), размер страницы и курсор следующей страницы (NextCursor предыдущего ответа).
+PaidFrom и PaidTo – границы периода оплаты включительно, Unix-время в секундах.
+6) SearchReply – ответ микросервиса «query»: найденные заказы (OrderSummary) и курсор следующей страницы
+(пустой – страниц больше нет).
+7) BatchRequest – пакетный запрос заказов по списку ID к микросервису «query», BatchReply – ответ: результат
+по каждому ID (BatchResult: статус BatchFound с заказом, BatchNotFound или BatchInvalidID – некорректный ID); если запрос не выполнен целиком –
+статус конверта и причина отказа (Error). Используются HTTP API /api/orders/batch как есть (JSON).
 */
 
+const (
+	SortPaidDesc = "paid_desc"
+	SortPaidAsc  = "paid_asc"
+
+	BatchFound     = "found"
+	BatchNotFound  = "not_found"
+	BatchInvalidID = "invalid_id"
+
+	StatusOK             = "ok"
+	StatusNotFound       = "not_found"
+	StatusInvalidID      = "invalid_id"
+	StatusInvalidRequest = "invalid_request"
+	StatusUnavailable    = "unavailable"
+	StatusInternal       = "internal"
+)
+
 type OrderPost struct {
-	OrderUID        string `json:"order_uid"`
-	Entry           string `json:"entry"`
-	TotalPrice      int    `json:"total_price"`
-	CustomerID      string `json:"customer_id"`
-	TrackNumber     string `json:"track_number"`
-	DeliveryService string `json:"delivery_service"`
+	OrderUID        string  `json:"order_uid"`
+	Entry           string  `json:"entry"`
+	TotalPrice      int     `json:"total_price"`
+	CustomerID      string  `json:"customer_id"`
+	TrackNumber     string  `json:"track_number"`
+	DeliveryService string  `json:"delivery_service"`
+	Payment         Payment `json:"payment"`
+	Items           []Item  `json:"items"`
+	ItemsCount      int     `json:"items_count"`
+	ItemsTotal      int     `json:"items_total"`
+}
+
+type Payment struct {
+	Transaction  string `json:"transaction"`
+	Currency     string `json:"currency"`
+	Provider     string `json:"provider"`
+	Amount       int    `json:"amount"`
+	PaymentDt    int    `json:"payment_dt"`
+	Bank         string `json:"bank"`
+	DeliveryCost int    `json:"delivery_cost"`
+}
+
+type Item struct {
+	ChrtID     int    `json:"chrt_id"`
+	Price      int    `json:"price"`
+	Rid        string `json:"rid"`
+	Name       string `json:"name"`
+	Sale       int    `json:"sale"`
+	Size       string `json:"size"`
+	TotalPrice int    `json:"total_price"`
+	NmID       int    `json:"nm_id"`
+	Brand      string `json:"brand"`
+}
+
+type OrderRequest struct {
+	RequestID string `json:"request_id"`
+	OrderUID  string `json:"order_uid"`
+}
+
+type Reply struct {
+	RequestID string `json:"request_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+type OrderReply struct {
+	Reply
+	Order *OrderPost `json:"order,omitempty"`
+}
+
+func (p Payment) PaidAt() time.Time {
+	return time.Unix(int64(p.PaymentDt), 0)
+}
+
+type SearchRequest struct {
+	RequestID       string `json:"request_id"`
+	CustomerID      string `json:"customer_id,omitempty"`
+	TrackNumber     string `json:"track_number,omitempty"`
+	DeliveryService string `json:"delivery_service,omitempty"`
+	Brand           string `json:"brand,omitempty"`
+	NmID            int    `json:"nm_id,omitempty"`
+	PaidFrom        int64  `json:"paid_from,omitempty"`
+	PaidTo          int64  `json:"paid_to,omitempty"`
+	Sort            string `json:"sort,omitempty"`
+	Limit           int    `json:"limit,omitempty"`
+	Cursor          string `json:"cursor,omitempty"`
+}
+
+type OrderSummary struct {
+	OrderUID        string    `json:"order_uid"`
+	CustomerID      string    `json:"customer_id"`
+	TrackNumber     string    `json:"track_number"`
+	DeliveryService string    `json:"delivery_service"`
+	PaymentDt       int       `json:"payment_dt"`
+	Amount          int       `json:"amount"`
+	Currency        string    `json:"currency"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type SearchReply struct {
+	Reply
+	Orders     []OrderSummary `json:"orders"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func (o OrderSummary) PaidAt() time.Time {
+	return time.Unix(int64(o.PaymentDt), 0)
+}
+
+type BatchRequest struct {
+	RequestID string   `json:"request_id,omitempty"`
+	OrderUIDs []string `json:"order_uids"`
+}
+
+type BatchResult struct {
+	OrderUID string     `json:"order_uid"`
+	Status   string     `json:"status"`
+	Order    *OrderPost `json:"order,omitempty"`
+}
+
+type BatchReply struct {
+	Reply
+	Results []BatchResult `json:"results"`
 }
@@ -38,6 +38,7 @@ CREATE TABLE order_get (
     delivery_service VARCHAR,
     shardkey VARCHAR,
     sm_id INTEGER,
+    created_at TIMESTAMPTZ DEFAULT now(),
     FOREIGN KEY (order_uid) REFERENCES payment (order_uid) ON DELETE CASCADE
 );
 
@@ -49,9 +50,55 @@ CREATE TABLE order_post (
     customer_id VARCHAR,
     track_number VARCHAR,
     delivery_service VARCHAR,
+    requested_count BIGINT DEFAULT 1,
+    requested_at TIMESTAMPTZ DEFAULT now(),
     FOREIGN KEY (order_uid) REFERENCES order_get (order_uid) ON DELETE CASCADE
 );
 
+// Скрипт таблицы dead_letter. Для сообщений, которые не удалось разобрать или сохранить в БД.
+CREATE TABLE dead_letter (
+    id BIGSERIAL PRIMARY KEY,
+    subject VARCHAR,
+    sequence BIGINT,
+    reason VARCHAR,
+    payload BYTEA,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    replayed_at TIMESTAMPTZ
+);
+
+// Скрипт таблицы order_version. История версий заказа: хэш содержимого (для поиска повторов) и сами данные.
+CREATE TABLE order_version (
+    order_uid VARCHAR,
+    version INTEGER,
+    payload_hash VARCHAR,
+    payload JSONB,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (order_uid, version)
+);
+
+// Версии для заказов, сохранённых до появления таблицы order_version. Хэш JSON заказа в БД не вычислить, поэтому он пустой:
+// повтор такого заказа save сравнивает с сохранёнными строками (selectorderget) и записывает хэш (adoptorderversion).
+INSERT INTO order_version (order_uid, version, payload_hash)
+SELECT order_uid, 1, '' FROM order_get
+ON CONFLICT DO NOTHING;
+
+// Колонки для прогрева кэша микросервиса query (время создания заказа, количество и время запросов) в существующих таблицах.
+ALTER TABLE order_get ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ DEFAULT now();
+ALTER TABLE order_post ADD COLUMN IF NOT EXISTS requested_count BIGINT DEFAULT 1;
+ALTER TABLE order_post ADD COLUMN IF NOT EXISTS requested_at TIMESTAMPTZ DEFAULT now();
+CREATE INDEX IF NOT EXISTS order_get_created_at ON order_get (created_at DESC);
+CREATE INDEX IF NOT EXISTS order_post_requested ON order_post (requested_count DESC, requested_at DESC);
+
+// Индекс для выборки товаров заказа (полные сведения о заказе в микросервисе query).
+CREATE INDEX IF NOT EXISTS items_order_uid ON items (order_uid);
+
+// Индексы для поиска заказов в микросервисе query (хранимая процедура searchorders).
+CREATE INDEX IF NOT EXISTS order_get_customer_id ON order_get (customer_id);
+CREATE INDEX IF NOT EXISTS order_get_track_number ON order_get (track_number);
+CREATE INDEX IF NOT EXISTS order_get_delivery_service ON order_get (delivery_service);
+CREATE INDEX IF NOT EXISTS items_brand ON items (brand);
+CREATE INDEX IF NOT EXISTS items_nmid ON items (nmID);
+CREATE INDEX IF NOT EXISTS payment_paid ON payment (payment_dt, order_uid);
 
 // Хранимая процедура для добавления нового значения в таблицу items
 CREATE OR REPLACE FUNCTION insertnewitem (
@@ -125,7 +172,256 @@ VALUES (
 (SELECT SUM (total_price) FROM items WHERE order_uid = orid)),
 (SELECT customer_id FROM order_get WHERE order_uid = orid), 
 (SELECT track_number FROM order_get WHERE order_uid = orid),
-(SELECT delivery_service FROM order_get WHERE order_uid = orid));
+(SELECT delivery_service FROM order_get WHERE order_uid = orid))
+ON CONFLICT (order_uid) DO UPDATE
+SET requested_count = order_post.requested_count + 1, requested_at = now();
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для пересчёта строки order_post после изменения заказа (событие order.changed микросервиса save).
+// Пересчитывает только существующую строку: заказ, который ещё не запрашивали, попадёт в order_post при первом запросе.
+// Количество запросов (requested_count) не меняется. Возвращает TRUE, если строка была.
+CREATE OR REPLACE FUNCTION refreshorderpost (orid varchar)
+RETURNS BOOLEAN AS $$
+BEGIN
+UPDATE order_post SET
+    entry = g.entry,
+    total_price = ((SELECT deliveryCost FROM payment WHERE order_uid = orid)
+        +
+        (SELECT SUM (total_price) FROM items WHERE order_uid = orid)),
+    customer_id = g.customer_id,
+    track_number = g.track_number,
+    delivery_service = g.delivery_service
+FROM order_get g
+WHERE order_post.order_uid = orid AND g.order_uid = orid;
+RETURN FOUND;
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для прогрева кэша микросервиса query: пакет заказов (lim строк, начиная с off) в порядке
+// recent – последние созданные, requested – самые запрашиваемые (при равенстве – последние запрошенные).
+// Итоговая стоимость считается так же, как в insertintoorderpost, поэтому заказ не обязан быть в order_post.
+// Заказы без товаров или стоимости доставки пропускаются: для них insertintoorderpost не даёт итоговой стоимости.
+CREATE OR REPLACE FUNCTION selectwarmuporders (mode varchar, lim integer, off integer)
+RETURNS TABLE (
+    order_uid varchar,
+    entry varchar,
+    total_price bigint,
+    customer_id varchar,
+    track_number varchar,
+    delivery_service varchar
+    ) AS $$
+BEGIN
+RETURN QUERY
+SELECT g.order_uid, g.entry,
+    (p.deliveryCost + (SELECT SUM (i.total_price) FROM items i WHERE i.order_uid = g.order_uid))::bigint,
+    g.customer_id, g.track_number, g.delivery_service
+FROM order_get g
+JOIN payment p ON p.order_uid = g.order_uid
+LEFT JOIN order_post op ON op.order_uid = g.order_uid
+WHERE p.deliveryCost IS NOT NULL AND EXISTS (SELECT 1 FROM items i WHERE i.order_uid = g.order_uid)
+ORDER BY
+    CASE WHEN mode = 'requested' THEN COALESCE (op.requested_count, 0) END DESC,
+    CASE WHEN mode = 'requested' THEN op.requested_at END DESC NULLS LAST,
+    g.created_at DESC NULLS LAST,
+    g.order_uid
+LIMIT lim OFFSET off;
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для полных сведений о заказах микросервиса query: строки payment заказов из массива orids.
+CREATE OR REPLACE FUNCTION selectorderpayments (orids varchar[])
+RETURNS TABLE (
+    order_uid varchar,
+    transaction varchar,
+    currency varchar,
+    provider varchar,
+    amount integer,
+    payment_dt integer,
+    bank varchar,
+    deliverycost integer
+    ) AS $$
+BEGIN
+RETURN QUERY
+SELECT p.order_uid, p.transaction, p.currency, p.provider, p.amount, p.payment_dt, p.bank, p.deliveryCost
+FROM payment p
+WHERE p.order_uid = ANY (orids);
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для полных сведений о заказах микросервиса query: строки items заказов из массива orids
+// в порядке добавления (ctid), как они пришли в сообщении заказа.
+CREATE OR REPLACE FUNCTION selectorderitems (orids varchar[])
+RETURNS TABLE (
+    order_uid varchar,
+    chrt_id integer,
+    price integer,
+    rid varchar,
+    name varchar,
+    sale integer,
+    size varchar,
+    total_price integer,
+    nmid integer,
+    brand varchar
+    ) AS $$
+BEGIN
+RETURN QUERY
+SELECT i.order_uid, i.chrt_id, i.price, i.rid, i.name, i.sale, i.size, i.total_price, i.nmID, i.brand
+FROM items i
+WHERE i.order_uid = ANY (orids)
+ORDER BY i.order_uid, i.ctid;
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для пакетного запроса заказов микросервиса query: строки для order_post заказов из массива orids
+// одним запросом (ANY), без записи в order_post – сверки запрашивают тысячи заказов, и requested_count
+// (порядок прогрева кэша) считал бы их как запросы пользователей. Итоговая стоимость считается так же,
+// как в insertintoorderpost; у заказа без товаров или стоимости доставки недостающая часть считается 0.
+CREATE OR REPLACE FUNCTION selectordersbyids (orids varchar[])
+RETURNS TABLE (
+    order_uid varchar,
+    entry varchar,
+    total_price bigint,
+    customer_id varchar,
+    track_number varchar,
+    delivery_service varchar
+    ) AS $$
+BEGIN
+RETURN QUERY
+SELECT g.order_uid, g.entry,
+    (COALESCE (p.deliveryCost, 0) + COALESCE ((SELECT SUM (i.total_price) FROM items i WHERE i.order_uid = g.order_uid), 0))::bigint,
+    g.customer_id, g.track_number, g.delivery_service
+FROM order_get g
+JOIN payment p ON p.order_uid = g.order_uid
+WHERE g.order_uid = ANY (orids);
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для поиска заказов микросервиса query. Пустой (NULL) параметр – фильтр не задан;
+// paid_from и paid_to – границы периода оплаты включительно. Сортировка sort: paid_desc или paid_asc – по payment_dt,
+// при равенстве – по order_uid. Следующая страница начинается после заказа (after_paid, after_uid) – курсор,
+// NULL – первая страница. Запрос собирается только из заданных фильтров (EXECUTE), поэтому план каждого
+// запроса использует индексы этих фильтров, а не общий план для всех сочетаний.
+CREATE OR REPLACE FUNCTION searchorders (
+    customer varchar,
+    track varchar,
+    service varchar,
+    brand_name varchar,
+    nm integer,
+    paid_from integer,
+    paid_to integer,
+    sort varchar,
+    after_paid integer,
+    after_uid varchar,
+    lim integer
+    )
+RETURNS TABLE (
+    order_uid varchar,
+    customer_id varchar,
+    track_number varchar,
+    delivery_service varchar,
+    payment_dt integer,
+    amount integer,
+    currency varchar,
+    created_at timestamptz
+    ) AS $$
+DECLARE
+    q text := 'SELECT g.order_uid, g.customer_id, g.track_number, g.delivery_service, p.payment_dt, p.amount, p.currency, g.created_at
+        FROM order_get g JOIN payment p ON p.order_uid = g.order_uid WHERE TRUE';
+    dir text := CASE WHEN sort = 'paid_asc' THEN 'ASC' ELSE 'DESC' END;
+BEGIN
+IF customer IS NOT NULL THEN q := q || ' AND g.customer_id = $1'; END IF;
+IF track IS NOT NULL THEN q := q || ' AND g.track_number = $2'; END IF;
+IF service IS NOT NULL THEN q := q || ' AND g.delivery_service = $3'; END IF;
+IF brand_name IS NOT NULL THEN
+    q := q || ' AND EXISTS (SELECT 1 FROM items i WHERE i.order_uid = g.order_uid AND i.brand = $4)';
+END IF;
+IF nm IS NOT NULL THEN
+    q := q || ' AND EXISTS (SELECT 1 FROM items i WHERE i.order_uid = g.order_uid AND i.nmID = $5)';
+END IF;
+IF paid_from IS NOT NULL THEN q := q || ' AND p.payment_dt >= $6'; END IF;
+IF paid_to IS NOT NULL THEN q := q || ' AND p.payment_dt <= $7'; END IF;
+IF after_uid IS NOT NULL THEN
+    q := q || CASE WHEN dir = 'ASC' THEN ' AND (p.payment_dt, p.order_uid) > ($8, $9)'
+        ELSE ' AND (p.payment_dt, p.order_uid) < ($8, $9)' END;
+END IF;
+q := q || format(' ORDER BY p.payment_dt %s, p.order_uid %s LIMIT $10', dir, dir);
+
+RETURN QUERY EXECUTE q USING customer, track, service, brand_name, nm, paid_from, paid_to, after_paid, after_uid, lim;
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для добавления новой версии заказа в таблицу order_version
+CREATE OR REPLACE FUNCTION insertorderversion (
+    orid varchar,
+    ver int,
+    hash varchar,
+    data jsonb
+    )
+RETURNS VOID AS $$
+BEGIN
+INSERT INTO order_version (order_uid, version, payload_hash, payload)
+VALUES (orid, ver, hash, data);
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для заказа, сохранённого до появления order_version: строка order_get для сравнения с повтором.
+CREATE OR REPLACE FUNCTION selectorderget (orid varchar)
+RETURNS TABLE (
+    entry varchar,
+    internal_signature varchar,
+    locale varchar,
+    customer_id varchar,
+    track_number varchar,
+    delivery_service varchar,
+    shardkey varchar,
+    sm_id integer
+    ) AS $$
+BEGIN
+RETURN QUERY
+SELECT o.entry, o.internal_signature, o.locale, o.customer_id, o.track_number, o.delivery_service, o.shardkey, o.sm_id
+FROM order_get o
+WHERE o.order_uid = orid;
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для записи хэша и содержимого в версию с пустым хэшем (заказ сохранён до появления order_version).
+CREATE OR REPLACE FUNCTION adoptorderversion (
+    orid varchar,
+    ver int,
+    hash varchar,
+    data jsonb
+    )
+RETURNS VOID AS $$
+BEGIN
+UPDATE order_version SET payload_hash = hash, payload = data
+WHERE order_uid = orid AND version = ver AND payload_hash = '';
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для удаления предыдущей версии заказа. Строки items, order_get и order_post удаляются каскадно.
+CREATE OR REPLACE FUNCTION deleteorder (orid varchar)
+RETURNS VOID AS $$
+BEGIN
+DELETE FROM payment WHERE order_uid = orid;
+END;
+$$ LANGUAGE plpgsql;
+
+// Хранимая процедура для добавления нового значения в таблицу dead_letter
+CREATE OR REPLACE FUNCTION insertdeadletter (
+    subject varchar,
+    sequence bigint,
+    reason varchar,
+    payload bytea
+    )
+RETURNS BIGINT AS $$
+DECLARE
+    new_id BIGINT;
+BEGIN
+INSERT INTO dead_letter (subject, sequence, reason, payload)
+VALUES (subject, sequence, reason, payload)
+RETURNING id INTO new_id;
+RETURN new_id;
 END;
 $$ LANGUAGE plpgsql;
 